@@ -0,0 +1,65 @@
+package kiroku
+
+import (
+	"time"
+
+	"github.com/hatchify/errors"
+)
+
+const (
+	// ErrBackpressure is returned when a transaction is refused as too many files are waiting to be exported
+	ErrBackpressure = errors.Error("transaction refused, backpressure limit reached while files are waiting to be exported")
+	// ErrInvalidBackpressurePolicy is returned when a BackpressurePolicy has negative values
+	ErrInvalidBackpressurePolicy = errors.Error("invalid backpressure policy, values cannot be negative")
+)
+
+// BackpressurePolicy limits the chunks and snapshots a Producer has waiting to be
+// exported. When a limit is reached, transactions (including batches) block until
+// exports catch up or Timeout elapses, and then return ErrBackpressure
+type BackpressurePolicy struct {
+	// MaxPendingFiles is the maximum number of files waiting to be exported
+	MaxPendingFiles int64 `toml:"max_pending_files" json:"maxPendingFiles"`
+	// MaxPendingBytes is the maximum total size of the files waiting to be exported
+	MaxPendingBytes int64 `toml:"max_pending_bytes" json:"maxPendingBytes"`
+	// Timeout is the amount of time a transaction will wait for exports to catch up
+	// (Default is to fail fast with ErrBackpressure)
+	Timeout time.Duration `toml:"timeout" json:"timeout"`
+}
+
+// IsEmpty will return whether or not the policy has any limits set
+func (b BackpressurePolicy) IsEmpty() bool {
+	return b.MaxPendingFiles == 0 && b.MaxPendingBytes == 0
+}
+
+// Validate ensures that the BackpressurePolicy values are valid
+func (b BackpressurePolicy) Validate() (err error) {
+	if b.MaxPendingFiles < 0 || b.MaxPendingBytes < 0 || b.Timeout < 0 {
+		return ErrInvalidBackpressurePolicy
+	}
+
+	return
+}
+
+// isExceeded will return whether or not the pending files have reached a limit
+func (b BackpressurePolicy) isExceeded(bp Backpressure) bool {
+	switch {
+	case b.MaxPendingFiles > 0 && bp.PendingFiles >= b.MaxPendingFiles:
+		return true
+	case b.MaxPendingBytes > 0 && bp.PendingBytes >= b.MaxPendingBytes:
+		return true
+	default:
+		return false
+	}
+}
+
+// Backpressure describes the files a Producer has waiting to be exported when a
+// BackpressurePolicy limit is reached
+type Backpressure struct {
+	// PendingFiles is the number of files waiting to be exported
+	PendingFiles int64 `json:"pendingFiles"`
+	// PendingBytes is the total size of the files waiting to be exported
+	PendingBytes int64 `json:"pendingBytes"`
+	// Blocking is whether or not the transaction is waiting for exports to catch
+	// up, rather than failing fast
+	Blocking bool `json:"blocking"`
+}
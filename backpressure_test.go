@@ -0,0 +1,220 @@
+package kiroku
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hatchify/errors"
+)
+
+func TestBackpressurePolicy_Validate(t *testing.T) {
+	type testcase struct {
+		name    string
+		policy  BackpressurePolicy
+		wantErr error
+	}
+
+	tests := []testcase{
+		{
+			name: "empty",
+		},
+		{
+			name:   "limits",
+			policy: BackpressurePolicy{MaxPendingFiles: 10, MaxPendingBytes: 1024, Timeout: time.Second},
+		},
+		{
+			name:    "negative files",
+			policy:  BackpressurePolicy{MaxPendingFiles: -1},
+			wantErr: ErrInvalidBackpressurePolicy,
+		},
+		{
+			name:    "negative bytes",
+			policy:  BackpressurePolicy{MaxPendingBytes: -1},
+			wantErr: ErrInvalidBackpressurePolicy,
+		},
+		{
+			name:    "negative timeout",
+			policy:  BackpressurePolicy{MaxPendingFiles: 1, Timeout: -1},
+			wantErr: ErrInvalidBackpressurePolicy,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.policy.Validate(); err != tt.wantErr {
+				t.Fatalf("invalid error, expected <%v> and received <%v>", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestProducer_backpressure(t *testing.T) {
+	type testcase struct {
+		name   string
+		policy BackpressurePolicy
+		// Number of transactions to write while exports are failing
+		fill int
+		// Whether or not exports resume while the final transaction is waiting
+		resume  bool
+		wantErr error
+	}
+
+	tests := []testcase{
+		{
+			name:    "fail fast",
+			policy:  BackpressurePolicy{MaxPendingFiles: 2},
+			fill:    2,
+			wantErr: ErrBackpressure,
+		},
+		{
+			name:    "byte limit",
+			policy:  BackpressurePolicy{MaxPendingBytes: 1},
+			fill:    1,
+			wantErr: ErrBackpressure,
+		},
+		{
+			name:    "timeout",
+			policy:  BackpressurePolicy{MaxPendingFiles: 2, Timeout: time.Millisecond * 50},
+			fill:    2,
+			wantErr: ErrBackpressure,
+		},
+		{
+			name:   "blocking",
+			policy: BackpressurePolicy{MaxPendingFiles: 2, Timeout: time.Second * 5},
+			fill:   2,
+			resume: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := MakeOptions("./testing", "test")
+			opts.ErrorDelay = time.Millisecond * 10
+			opts.Backpressure = tt.policy
+			if err := os.MkdirAll(opts.Dir, 0744); err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(opts.Dir)
+
+			events := make(chan Backpressure, 1)
+			opts.OnBackpressure = func(bp Backpressure) { events <- bp }
+
+			var exporting int32
+			src := newMockSource(
+				func(ctx context.Context, prefix, filename string, r io.Reader) (string, error) {
+					if atomic.LoadInt32(&exporting) == 0 {
+						return "", io.ErrUnexpectedEOF
+					}
+
+					return filename, nil
+				},
+				nil, nil, nil, nil, nil,
+			)
+
+			p, err := NewProducer(opts, src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.Close()
+
+			write := func() error {
+				return p.Transaction(func(txn *Transaction) error {
+					return txn.Write([]byte("hello world"))
+				})
+			}
+
+			// Fill the queue while exports are failing
+			for i := 0; i < tt.fill; i++ {
+				if err = write(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if tt.resume {
+				time.AfterFunc(time.Millisecond*50, func() { atomic.StoreInt32(&exporting, 1) })
+			}
+
+			if err = write(); err != tt.wantErr {
+				t.Fatalf("invalid error, expected <%v> and received <%v>", tt.wantErr, err)
+			}
+
+			bp := <-events
+			if bp.Blocking != (tt.policy.Timeout > 0) {
+				t.Fatalf("invalid blocking value, expected %v and received %v", tt.policy.Timeout > 0, bp.Blocking)
+			}
+
+			var stats ProducerStats
+			if stats, err = p.Stats(); err != nil {
+				t.Fatal(err)
+			}
+
+			if stats.Backpressured != 1 {
+				t.Fatalf("invalid backpressured count, expected %d and received %d", 1, stats.Backpressured)
+			}
+		})
+	}
+}
+
+func TestProducer_backpressure_close(t *testing.T) {
+	opts := MakeOptions("./testing", "test")
+	opts.ErrorDelay = time.Millisecond * 10
+	opts.Backpressure = BackpressurePolicy{MaxPendingFiles: 1, Timeout: time.Second * 10}
+	opts.AvoidExportOnClose = true
+	if err := os.MkdirAll(opts.Dir, 0744); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(opts.Dir)
+
+	events := make(chan Backpressure, 1)
+	opts.OnBackpressure = func(bp Backpressure) { events <- bp }
+
+	src := newMockSource(
+		func(ctx context.Context, prefix, filename string, r io.Reader) (string, error) {
+			return "", io.ErrUnexpectedEOF
+		},
+		nil, nil, nil, nil, nil,
+	)
+
+	p, err := NewProducer(opts, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	write := func() error {
+		return p.Transaction(func(txn *Transaction) error {
+			return txn.Write([]byte("hello world"))
+		})
+	}
+
+	// Fill the queue while exports are failing
+	if err = write(); err != nil {
+		t.Fatal(err)
+	}
+
+	blocked := make(chan error, 1)
+	go func() { blocked <- write() }()
+	// Wait for the transaction to block on backpressure
+	<-events
+
+	closed := make(chan error, 1)
+	go func() { closed <- p.Close() }()
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second * 2):
+		t.Fatal("timed out waiting for Close while a transaction is blocked on backpressure")
+	}
+
+	select {
+	case err = <-blocked:
+		if err != errors.ErrIsClosed {
+			t.Fatalf("invalid error, expected <%v> and received <%v>", errors.ErrIsClosed, err)
+		}
+	case <-time.After(time.Second * 2):
+		t.Fatal("timed out waiting for the blocked transaction to return")
+	}
+}
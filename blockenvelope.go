@@ -0,0 +1,140 @@
+package kiroku
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/hatchify/errors"
+)
+
+const (
+	// ErrEmptyKey is returned when a BlockEnvelope is written without a key
+	ErrEmptyKey = errors.Error("invalid key, cannot be empty")
+	// ErrInvalidOpcode is returned when a BlockEnvelope is written with a reserved opcode
+	ErrInvalidOpcode = errors.Error("invalid opcode, must be OpPut, OpDelete or a custom opcode (OpCustom and above)")
+	// ErrInvalidBlockEnvelope is returned when a BlockEnvelope cannot be decoded
+	ErrInvalidBlockEnvelope = errors.Error("invalid block envelope")
+)
+
+const (
+	// OpRaw denotes a raw block which was not written as a BlockEnvelope
+	OpRaw Opcode = iota
+	// OpPut denotes that the value was written to the key
+	OpPut
+	// OpDelete denotes that the key was deleted
+	OpDelete
+)
+
+// OpCustom is the first opcode available for application-defined operations
+// Note: Opcodes between OpDelete and OpCustom are reserved
+const OpCustom Opcode = 128
+
+const (
+	// blockKindRaw denotes a raw block
+	blockKindRaw blockKind = iota
+	// blockKindEnvelope denotes an encoded BlockEnvelope
+	blockKindEnvelope
+)
+
+// Opcode represents the operation of a BlockEnvelope
+type Opcode uint8
+
+// Validate ensures the Opcode can be written
+func (o Opcode) Validate() (err error) {
+	switch {
+	case o == OpPut, o == OpDelete, o >= OpCustom:
+		return
+	default:
+		return ErrInvalidOpcode
+	}
+}
+
+func (o Opcode) String() string {
+	switch {
+	case o == OpRaw:
+		return "raw"
+	case o == OpPut:
+		return "put"
+	case o == OpDelete:
+		return "delete"
+	case o >= OpCustom:
+		return fmt.Sprintf("custom(%d)", uint8(o))
+	default:
+		return fmt.Sprintf("reserved(%d)", uint8(o))
+	}
+}
+
+// BlockEnvelope is a structured block containing an operation on a key
+type BlockEnvelope struct {
+	Op    Opcode
+	Key   []byte
+	Value []byte
+}
+
+// Validate ensures the BlockEnvelope can be written
+func (b *BlockEnvelope) Validate() (err error) {
+	if err = b.Op.Validate(); err != nil {
+		return
+	}
+
+	if len(b.Key) == 0 {
+		return ErrEmptyKey
+	}
+
+	return
+}
+
+func (b *BlockEnvelope) appendBytes(bs []byte) []byte {
+	bs = append(bs, uint8(b.Op))
+	bs = binary.AppendUvarint(bs, uint64(len(b.Key)))
+	bs = append(bs, b.Key...)
+	return append(bs, b.Value...)
+}
+
+func (b *BlockEnvelope) parse(bs []byte) (err error) {
+	if len(bs) == 0 {
+		return ErrInvalidBlockEnvelope
+	}
+
+	b.Op = Opcode(bs[0])
+	keyLength, n := binary.Uvarint(bs[1:])
+	if n <= 0 || keyLength > uint64(len(bs)-1-n) {
+		return ErrInvalidBlockEnvelope
+	}
+
+	start := 1 + n
+	end := start + int(keyLength)
+	b.Key = bs[start:end]
+	b.Value = bs[end:]
+	return
+}
+
+// ForEachEnvelope will iterate through the blocks within the reader as BlockEnvelopes
+// Note: Raw blocks are provided with an Op of OpRaw and the block as the Value
+func (r *Reader) ForEachEnvelope(seek int64, fn func(BlockEnvelope) error) (err error) {
+	return r.forEach(seek, func(_ Position, kind blockKind, b Block) (err error) {
+		var e BlockEnvelope
+		switch kind {
+		case blockKindEnvelope:
+			if err = e.parse(b); err != nil {
+				return
+			}
+		default:
+			e.Value = b
+		}
+
+		return fn(e)
+	})
+}
+
+// blockKind is the leading byte of each block of chunks with FlagBlockKinds set
+type blockKind uint8
+
+func (b blockKind) Validate() (err error) {
+	switch b {
+	case blockKindRaw, blockKindEnvelope:
+		return
+	default:
+		return ErrInvalidBlockKind
+	}
+}
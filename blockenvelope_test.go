@@ -0,0 +1,162 @@
+package kiroku
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestBlockEnvelope_parse(t *testing.T) {
+	type testcase struct {
+		name     string
+		envelope BlockEnvelope
+	}
+
+	tests := []testcase{
+		{name: "put", envelope: BlockEnvelope{Op: OpPut, Key: []byte("foo"), Value: []byte("bar")}},
+		{name: "delete", envelope: BlockEnvelope{Op: OpDelete, Key: []byte("foo"), Value: []byte{}}},
+		{name: "custom", envelope: BlockEnvelope{Op: OpCustom + 1, Key: bytes.Repeat([]byte("k"), 300), Value: []byte("bar")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got BlockEnvelope
+			if err := got.parse(tt.envelope.appendBytes(nil)); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, tt.envelope) {
+				t.Fatalf("BlockEnvelope.parse() = %+v, want %+v", got, tt.envelope)
+			}
+		})
+	}
+}
+
+func TestBlockEnvelope_parse_invalid(t *testing.T) {
+	type testcase struct {
+		name string
+		bs   []byte
+	}
+
+	tests := []testcase{
+		{name: "empty"},
+		{name: "missing key length", bs: []byte{uint8(OpPut)}},
+		{name: "key length exceeds block", bs: []byte{uint8(OpPut), 4, 'f', 'o', 'o'}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var e BlockEnvelope
+			if err := e.parse(tt.bs); err != ErrInvalidBlockEnvelope {
+				t.Fatalf("BlockEnvelope.parse() error = %v, wantErr %v", err, ErrInvalidBlockEnvelope)
+			}
+		})
+	}
+}
+
+func TestBlockEnvelope_Validate(t *testing.T) {
+	type testcase struct {
+		name     string
+		envelope BlockEnvelope
+		wantErr  error
+	}
+
+	tests := []testcase{
+		{name: "put", envelope: BlockEnvelope{Op: OpPut, Key: []byte("foo")}},
+		{name: "custom", envelope: BlockEnvelope{Op: OpCustom, Key: []byte("foo")}},
+		{name: "raw", envelope: BlockEnvelope{Op: OpRaw, Key: []byte("foo")}, wantErr: ErrInvalidOpcode},
+		{name: "reserved", envelope: BlockEnvelope{Op: OpCustom - 1, Key: []byte("foo")}, wantErr: ErrInvalidOpcode},
+		{name: "empty key", envelope: BlockEnvelope{Op: OpDelete}, wantErr: ErrEmptyKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.envelope.Validate(); err != tt.wantErr {
+				t.Fatalf("BlockEnvelope.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReader_ForEachEnvelope(t *testing.T) {
+	l := NewMemoryLedger("test")
+	if err := l.Transaction(func(txn *Transaction) (err error) {
+		if err = txn.Put([]byte("user_1"), []byte("joe")); err != nil {
+			return
+		}
+
+		if err = txn.Write([]byte("raw")); err != nil {
+			return
+		}
+
+		if err = txn.Delete([]byte("user_2")); err != nil {
+			return
+		}
+
+		return txn.WriteEnvelope(BlockEnvelope{Op: OpCustom, Key: []byte("counter"), Value: []byte{1}})
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var got, gotRaw []string
+	c := NewMemoryConsumer(l, func(typ Type, r *Reader) (err error) {
+		if err = r.ForEachEnvelope(0, func(e BlockEnvelope) error {
+			got = append(got, fmt.Sprintf("%s %s %v", e.Op, e.Key, e.Value))
+			return nil
+		}); err != nil {
+			return
+		}
+
+		return r.ForEach(0, func(b Block) error {
+			gotRaw = append(gotRaw, string(b))
+			return nil
+		})
+	})
+
+	if err := c.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"put user_1 [106 111 101]",
+		"raw  [114 97 119]",
+		"delete user_2 []",
+		"custom(128) counter [1]",
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("invalid envelopes, expected %v and received %v", want, got)
+	}
+
+	// Raw blocks are provided as-is, envelopes are provided in their encoded form
+	if len(gotRaw) != 4 || gotRaw[1] != "raw" {
+		t.Fatalf("invalid raw blocks, received %q", gotRaw)
+	}
+}
+
+func TestReader_ForEachEnvelope_withoutBlockKinds(t *testing.T) {
+	r := NewReader(bytes.NewReader(newTestChunk(Block("foo"), Block("bar"))))
+	var got []BlockEnvelope
+	if err := r.ForEachEnvelope(0, func(e BlockEnvelope) error {
+		got = append(got, e)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []BlockEnvelope{{Value: []byte("foo")}, {Value: []byte("bar")}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("invalid envelopes, expected %+v and received %+v", want, got)
+	}
+}
+
+func TestReader_ForEach_invalidBlockKind(t *testing.T) {
+	bs := newTestChunkWithFlags(FlagBlockChecksums|FlagBlockKinds, Block{9, 'f', 'o', 'o'})
+	r := NewReader(bytes.NewReader(bs))
+	err := r.ForEach(0, func(b Block) error { return nil })
+	if !errors.Is(err, ErrInvalidBlockKind) {
+		t.Fatalf("invalid error, expected %v and received %v", ErrInvalidBlockKind, err)
+	}
+}
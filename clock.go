@@ -0,0 +1,24 @@
+package kiroku
+
+import "time"
+
+var _ clock = realClock{}
+
+// clock provides the current time and timers, it allows time to be faked for testing purposes
+type clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is a clock backed by the time package
+type realClock struct{}
+
+// Now will return the current time
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// After will return a channel which receives the current time once the duration has elapsed
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
@@ -0,0 +1,240 @@
+package kiroku
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/hatchify/errors"
+	"github.com/mojura/enkodo"
+)
+
+const (
+	// ErrNilCodec is returned when a typed value is written or read without a Codec
+	ErrNilCodec = errors.Error("invalid codec, cannot be nil")
+	// ErrInvalidCodecID is returned when a Codec has a reserved ID
+	ErrInvalidCodecID = errors.Error("invalid codec ID, cannot be zero")
+	// ErrCodecMismatch is returned when a chunk was not written with the expected Codec
+	ErrCodecMismatch = errors.Error("chunk codec does not match the expected codec")
+	// ErrNotEnkodoEncodee is returned when the Enkodo codec marshals a value which does not implement enkodo.Encodee
+	ErrNotEnkodoEncodee = errors.Error("value does not implement enkodo.Encodee")
+	// ErrNotEnkodoDecodee is returned when the Enkodo codec unmarshals a value which does not implement enkodo.Decodee
+	ErrNotEnkodoDecodee = errors.Error("value does not implement enkodo.Decodee")
+)
+
+const (
+	// CodecNone denotes that blocks are raw bytes which were not written with a Codec
+	CodecNone CodecID = iota
+	// CodecEnkodo denotes that blocks are encoded with enkodo
+	CodecEnkodo
+	// CodecJSON denotes that blocks are encoded with encoding/json
+	CodecJSON
+	// CodecGob denotes that blocks are encoded with encoding/gob
+	CodecGob
+)
+
+var (
+	// EnkodoCodec encodes values which implement enkodo.Encodee and enkodo.Decodee
+	EnkodoCodec Codec = enkodoCodec{}
+	// JSONCodec encodes values with encoding/json
+	JSONCodec Codec = jsonCodec{}
+	// GobCodec encodes values with encoding/gob
+	GobCodec Codec = gobCodec{}
+)
+
+// CodecID identifies the Codec used to encode the blocks of a chunk
+// Note: IDs 1 through 127 are reserved for Kiroku codecs
+type CodecID uint8
+
+func (c CodecID) String() string {
+	switch c {
+	case CodecNone:
+		return "none"
+	case CodecEnkodo:
+		return "enkodo"
+	case CodecJSON:
+		return "json"
+	case CodecGob:
+		return "gob"
+	default:
+		return fmt.Sprintf("custom(%d)", uint8(c))
+	}
+}
+
+// Codec encodes and decodes typed values to and from blocks
+type Codec interface {
+	// ID is recorded within the chunk header, so chunks written with another Codec can be rejected
+	ID() CodecID
+	Marshal(v any) ([]byte, error)
+	Unmarshal(bs []byte, v any) error
+}
+
+// ValueWriter is a block writer which has an associated Codec
+// Note: Implemented by Transaction and Snapshot
+type ValueWriter interface {
+	Write(value []byte) error
+	Codec() Codec
+}
+
+// WriteValue will encode the provided value with the Codec of the writer and write it as a block
+// Note: T may be a value or a pointer type, values are passed to the Codec by reference
+func WriteValue[T any](w ValueWriter, v T) (err error) {
+	c := w.Codec()
+	if c == nil {
+		return ErrNilCodec
+	}
+
+	// Pointer values are passed as-is so pointer receivers are still implemented
+	var target any = &v
+	if isPointerType[T]() {
+		target = v
+	}
+
+	var bs []byte
+	if bs, err = c.Marshal(target); err != nil {
+		return fmt.Errorf("error encoding value: %w", err)
+	}
+
+	return w.Write(bs)
+}
+
+// ForEachValue will decode each block of the Reader with the provided Codec
+// Note: ErrCodecMismatch is returned when the chunk was written with another Codec.
+// BlockEnvelope blocks are not encoded with the Codec and are skipped, use
+// ForEachEnvelope to read them. When T is a pointer type, each value is newly allocated
+func ForEachValue[T any](r *Reader, c Codec, seek int64, fn func(T) error) (err error) {
+	if c == nil {
+		return ErrNilCodec
+	}
+
+	var h Header
+	if h, err = r.Header(); err != nil {
+		return
+	}
+
+	if h.Codec != c.ID() {
+		return fmt.Errorf("%w: expected <%s> and received <%s>", ErrCodecMismatch, c.ID(), h.Codec)
+	}
+
+	return r.forEach(seek, func(_ Position, kind blockKind, b Block) (err error) {
+		if kind == blockKindEnvelope {
+			return
+		}
+
+		var v T
+		var target any = &v
+		if isPointerType[T]() {
+			// Pointer values are allocated so they can be decoded into directly
+			v = reflect.New(reflect.TypeOf(v).Elem()).Interface().(T)
+			target = v
+		}
+
+		if err = c.Unmarshal(b, target); err != nil {
+			return fmt.Errorf("error decoding value: %w", err)
+		}
+
+		return fn(v)
+	})
+}
+
+// TypedUpdateFunc is called with each decoded value of a chunk or snapshot
+type TypedUpdateFunc[T any] func(Type, T) error
+
+// NewTypedUpdateFunc will initialize an UpdateFunc which decodes each block with the provided Codec
+func NewTypedUpdateFunc[T any](c Codec, fn TypedUpdateFunc[T]) UpdateFunc {
+	return func(t Type, r *Reader) (err error) {
+		return ForEachValue(r, c, 0, func(v T) error {
+			return fn(t, v)
+		})
+	}
+}
+
+// NewTypedConsumer will initialize a new Consumer which decodes each block with Options.Codec
+func NewTypedConsumer[T any](opts Options, src Source, onUpdate TypedUpdateFunc[T]) (c *Consumer, err error) {
+	return NewTypedConsumerWithContext(context.Background(), opts, src, onUpdate)
+}
+
+// NewTypedConsumerWithContext will initialize a new typed Consumer instance with a provided context.Context
+func NewTypedConsumerWithContext[T any](ctx context.Context, opts Options, src Source, onUpdate TypedUpdateFunc[T]) (c *Consumer, err error) {
+	if opts.Codec == nil {
+		return nil, ErrNilCodec
+	}
+
+	return NewConsumerWithContext(ctx, opts, src, NewTypedUpdateFunc(opts.Codec, onUpdate))
+}
+
+func validateCodec(c Codec) (err error) {
+	if c == nil {
+		return
+	}
+
+	if c.ID() == CodecNone {
+		return ErrInvalidCodecID
+	}
+
+	return
+}
+
+func isPointerType[T any]() bool {
+	return reflect.TypeOf((*T)(nil)).Elem().Kind() == reflect.Pointer
+}
+
+type enkodoCodec struct{}
+
+func (enkodoCodec) ID() CodecID {
+	return CodecEnkodo
+}
+
+func (enkodoCodec) Marshal(v any) (bs []byte, err error) {
+	e, ok := v.(enkodo.Encodee)
+	if !ok {
+		return nil, ErrNotEnkodoEncodee
+	}
+
+	return enkodo.Marshal(e)
+}
+
+func (enkodoCodec) Unmarshal(bs []byte, v any) (err error) {
+	d, ok := v.(enkodo.Decodee)
+	if !ok {
+		return ErrNotEnkodoDecodee
+	}
+
+	return enkodo.Unmarshal(bs, d)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ID() CodecID {
+	return CodecJSON
+}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(bs []byte, v any) error {
+	return json.Unmarshal(bs, v)
+}
+
+type gobCodec struct{}
+
+func (gobCodec) ID() CodecID {
+	return CodecGob
+}
+
+func (gobCodec) Marshal(v any) (bs []byte, err error) {
+	buf := bytes.NewBuffer(nil)
+	if err = gob.NewEncoder(buf).Encode(v); err != nil {
+		return
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(bs []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(bs)).Decode(v)
+}
@@ -0,0 +1,397 @@
+package kiroku
+
+import (
+	"context"
+	stderrors "errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mojura/enkodo"
+)
+
+func TestCodec_roundTrip(t *testing.T) {
+	type testcase struct {
+		name  string
+		codec Codec
+	}
+
+	tests := []testcase{
+		{name: "enkodo", codec: EnkodoCodec},
+		{name: "json", codec: JSONCodec},
+		{name: "gob", codec: GobCodec},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewMemoryLedgerWithCodec("test", tt.codec)
+			want := []testUser{{Name: "joe", Age: 32}, {Name: "jane", Age: 29}}
+			if err := l.Transaction(func(txn *Transaction) (err error) {
+				for _, u := range want {
+					if err = WriteValue(txn, u); err != nil {
+						return
+					}
+				}
+
+				return
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			var got []testUser
+			c := NewMemoryConsumer(l, NewTypedUpdateFunc(tt.codec, func(typ Type, u testUser) error {
+				got = append(got, u)
+				return nil
+			}))
+
+			if err := c.Sync(); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("invalid values, expected %v and received %v", want, got)
+			}
+		})
+	}
+}
+
+func TestForEachValue(t *testing.T) {
+	type testcase struct {
+		name       string
+		writeCodec Codec
+		readCodec  Codec
+		wantErr    error
+	}
+
+	tests := []testcase{
+		{
+			name:       "matching codec",
+			writeCodec: JSONCodec,
+			readCodec:  JSONCodec,
+		},
+		{
+			name:       "mismatched codec",
+			writeCodec: JSONCodec,
+			readCodec:  GobCodec,
+			wantErr:    ErrCodecMismatch,
+		},
+		{
+			name:      "raw blocks",
+			readCodec: JSONCodec,
+			wantErr:   ErrCodecMismatch,
+		},
+		{
+			name:       "nil codec",
+			writeCodec: JSONCodec,
+			wantErr:    ErrNilCodec,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewMemoryLedgerWithCodec("test", tt.writeCodec)
+			if err := l.Transaction(func(txn *Transaction) error {
+				return txn.Write([]byte(`{"name":"joe"}`))
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			c := NewMemoryConsumer(l, func(typ Type, r *Reader) error {
+				return ForEachValue(r, tt.readCodec, 0, func(u testUser) error { return nil })
+			})
+
+			err := c.Sync()
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Fatal(err)
+			case tt.wantErr != nil && (err == nil || !strings.Contains(err.Error(), tt.wantErr.Error())):
+				t.Fatalf("invalid error, expected %v and received %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestForEachValue_codecMismatch(t *testing.T) {
+	l := NewMemoryLedgerWithCodec("test", JSONCodec)
+	if err := l.Transaction(func(txn *Transaction) error {
+		return WriteValue(txn, testUser{Name: "joe"})
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var err error
+	c := NewMemoryConsumer(l, func(typ Type, r *Reader) error {
+		err = ForEachValue(r, GobCodec, 0, func(u testUser) error { return nil })
+		return nil
+	})
+
+	if serr := c.Sync(); serr != nil {
+		t.Fatal(serr)
+	}
+
+	if !stderrors.Is(err, ErrCodecMismatch) {
+		t.Fatalf("invalid error, expected %v and received %v", ErrCodecMismatch, err)
+	}
+
+	if class := ClassifyError(err); class != ErrorPermanent {
+		t.Fatalf("invalid error class, expected %v and received %v", ErrorPermanent, class)
+	}
+}
+
+func TestForEachValue_envelopes(t *testing.T) {
+	l := NewMemoryLedgerWithCodec("test", JSONCodec)
+	want := []testUser{{Name: "joe", Age: 32}, {Name: "jane", Age: 29}}
+	if err := l.Transaction(func(txn *Transaction) (err error) {
+		if err = WriteValue(txn, want[0]); err != nil {
+			return
+		}
+
+		if err = txn.Put([]byte("foo"), []byte("bar")); err != nil {
+			return
+		}
+
+		if err = txn.Delete([]byte("foo")); err != nil {
+			return
+		}
+
+		return WriteValue(txn, want[1])
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []testUser
+	c := NewMemoryConsumer(l, NewTypedUpdateFunc(JSONCodec, func(typ Type, u testUser) error {
+		got = append(got, u)
+		return nil
+	}))
+
+	if err := c.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("invalid values, expected %v and received %v", want, got)
+	}
+}
+
+func TestWriteValue(t *testing.T) {
+	type testcase struct {
+		name    string
+		codec   Codec
+		value   any
+		wantErr error
+	}
+
+	tests := []testcase{
+		{
+			name:  "basic",
+			codec: JSONCodec,
+			value: testUser{Name: "joe"},
+		},
+		{
+			name:    "nil codec",
+			value:   testUser{Name: "joe"},
+			wantErr: ErrNilCodec,
+		},
+		{
+			name:    "not enkodo encodee",
+			codec:   EnkodoCodec,
+			value:   "joe",
+			wantErr: ErrNotEnkodoEncodee,
+		},
+		{
+			name:  "enkodo value",
+			codec: EnkodoCodec,
+			value: testUser{Name: "joe"},
+		},
+		{
+			name:  "enkodo pointer",
+			codec: EnkodoCodec,
+			value: &testUser{Name: "joe"},
+		},
+		{
+			name:  "json pointer",
+			codec: JSONCodec,
+			value: &testUser{Name: "joe"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewMemoryLedgerWithCodec("test", tt.codec)
+			err := l.Transaction(func(txn *Transaction) error {
+				switch v := tt.value.(type) {
+				case string:
+					return WriteValue(txn, v)
+				case *testUser:
+					return WriteValue(txn, v)
+				default:
+					return WriteValue(txn, v.(testUser))
+				}
+			})
+
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Fatal(err)
+			case tt.wantErr != nil && !stderrors.Is(err, tt.wantErr):
+				t.Fatalf("invalid error, expected %v and received %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestForEachValue_pointer(t *testing.T) {
+	type testcase struct {
+		name  string
+		codec Codec
+	}
+
+	tests := []testcase{
+		{name: "enkodo", codec: EnkodoCodec},
+		{name: "json", codec: JSONCodec},
+		{name: "gob", codec: GobCodec},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewMemoryLedgerWithCodec("test", tt.codec)
+			want := []*testUser{{Name: "joe", Age: 32}, {Name: "jane", Age: 29}}
+			if err := l.Transaction(func(txn *Transaction) (err error) {
+				for _, u := range want {
+					if err = WriteValue(txn, u); err != nil {
+						return
+					}
+				}
+
+				return
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			var got []*testUser
+			c := NewMemoryConsumer(l, NewTypedUpdateFunc(tt.codec, func(typ Type, u *testUser) error {
+				got = append(got, u)
+				return nil
+			}))
+
+			if err := c.Sync(); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("invalid values, expected %v and received %v", want, got)
+			}
+
+			if got[0] == got[1] {
+				t.Fatal("invalid values, expected each value to be newly allocated")
+			}
+		})
+	}
+}
+
+func TestNewTypedConsumer(t *testing.T) {
+	var err error
+	if err = os.MkdirAll("./testing_source", 0744); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll("./testing_source")
+
+	var src Source
+	if src, err = NewIOSource("./testing_source"); err != nil {
+		t.Fatal(err)
+	}
+
+	popts := MakeOptions("./testing_producer", "test")
+	popts.Codec = GobCodec
+	if err = os.MkdirAll(popts.Dir, 0744); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(popts.Dir)
+
+	var p *Producer
+	if p, err = NewProducer(popts, src); err != nil {
+		t.Fatal(err)
+	}
+
+	want := testUser{Name: "joe", Age: 32}
+	if err = p.Transaction(func(txn *Transaction) error {
+		return WriteValue(txn, want)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	copts := MakeOptions("./testing_consumer", "test")
+	if err = os.MkdirAll(copts.Dir, 0744); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(copts.Dir)
+
+	onUpdate := func(typ Type, u testUser) error { return nil }
+	if _, err = NewTypedConsumer(copts, src, onUpdate); err != ErrNilCodec {
+		t.Fatalf("invalid error, expected %v and received %v", ErrNilCodec, err)
+	}
+
+	gotCh := make(chan testUser, 1)
+	copts.Codec = GobCodec
+	var c *Consumer
+	if c, err = NewTypedConsumerWithContext(context.Background(), copts, src, func(typ Type, u testUser) error {
+		gotCh <- u
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if got := <-gotCh; got != want {
+		t.Fatalf("invalid value, expected %v and received %v", want, got)
+	}
+}
+
+func TestOptions_Validate_codec(t *testing.T) {
+	opts := MakeOptions("./testing", "test")
+	opts.Codec = mockCodec{}
+	if err := opts.Validate(); err != ErrInvalidCodecID {
+		t.Fatalf("invalid error, expected %v and received %v", ErrInvalidCodecID, err)
+	}
+}
+
+type testUser struct {
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+func (u *testUser) MarshalEnkodo(enc *enkodo.Encoder) (err error) {
+	if err = enc.String(u.Name); err != nil {
+		return
+	}
+
+	return enc.Int(u.Age)
+}
+
+func (u *testUser) UnmarshalEnkodo(dec *enkodo.Decoder) (err error) {
+	if u.Name, err = dec.String(); err != nil {
+		return
+	}
+
+	u.Age, err = dec.Int()
+	return
+}
+
+type mockCodec struct{}
+
+func (mockCodec) ID() CodecID {
+	return CodecNone
+}
+
+func (mockCodec) Marshal(v any) ([]byte, error) {
+	return nil, nil
+}
+
+func (mockCodec) Unmarshal(bs []byte, v any) error {
+	return nil
+}
@@ -0,0 +1,247 @@
+package kiroku
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/hatchify/errors"
+	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/zstd"
+)
+
+// ErrBlockTooLarge is returned when a compressed block exceeds the maximum block size
+const ErrBlockTooLarge = errors.Error("block exceeds the maximum block size")
+
+// maxBlockSize is the maximum decompressed size of a compressed block, the output of
+// decompression is bounded so a corrupted or malicious chunk cannot exhaust memory
+const maxBlockSize = 256 << 20
+
+const (
+	// CompressionNone will store blocks uncompressed
+	CompressionNone Compression = iota
+	// CompressionGzip will compress blocks using gzip
+	CompressionGzip
+	// CompressionSnappy will compress blocks using snappy
+	CompressionSnappy
+	// CompressionZstd will compress blocks using zstd
+	CompressionZstd
+)
+
+var (
+	gzipWriters = sync.Pool{
+		New: func() interface{} {
+			return gzip.NewWriter(nil)
+		},
+	}
+
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+	zstdErr     error
+)
+
+func parseCompression(str string) (c Compression, err error) {
+	switch str {
+	case "", "none":
+		c = CompressionNone
+	case "gzip":
+		c = CompressionGzip
+	case "snappy":
+		c = CompressionSnappy
+	case "zstd":
+		c = CompressionZstd
+	default:
+		err = fmt.Errorf("compression of <%s> is not supported", str)
+	}
+
+	return
+}
+
+// Compression represents a block compression codec
+type Compression uint8
+
+// Validate ensures the Compression is supported
+func (c Compression) Validate() (err error) {
+	switch c {
+	case CompressionNone:
+	case CompressionGzip:
+	case CompressionSnappy:
+	case CompressionZstd:
+
+	default:
+		return fmt.Errorf("invalid compression, <%d> is not supported", uint8(c))
+	}
+
+	return
+}
+
+func (c Compression) String() (out string) {
+	switch c {
+	case CompressionNone:
+		return "none"
+	case CompressionGzip:
+		return "gzip"
+	case CompressionSnappy:
+		return "snappy"
+	case CompressionZstd:
+		return "zstd"
+
+	default:
+		return "INVALID"
+	}
+}
+
+// MarshalText is a encoding.TextMarshaler helper func
+func (c Compression) MarshalText() (bs []byte, err error) {
+	if err = c.Validate(); err != nil {
+		return
+	}
+
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText is a encoding.TextUnmarshaler helper func
+func (c *Compression) UnmarshalText(bs []byte) (err error) {
+	var val Compression
+	if val, err = parseCompression(string(bs)); err != nil {
+		return
+	}
+
+	*c = val
+	return
+}
+
+// compress will compress the provided value
+// Note: The returned slice may use the backing array of dst
+func (c Compression) compress(dst, value []byte) (out []byte, err error) {
+	switch c {
+	case CompressionNone:
+		return value, nil
+	case CompressionGzip:
+		return gzipCompress(dst, value)
+	case CompressionSnappy:
+		return s2.EncodeSnappy(dst[:cap(dst)], value), nil
+	case CompressionZstd:
+		if err = initZstd(); err != nil {
+			return
+		}
+
+		return zstdEncoder.EncodeAll(value, dst[:0]), nil
+
+	default:
+		return nil, c.Validate()
+	}
+}
+
+// decompress will decompress the provided value into a newly allocated slice
+// Note: ErrBlockTooLarge is returned when the decompressed value exceeds the limit
+func (c Compression) decompress(value []byte, limit int) (out []byte, err error) {
+	switch c {
+	case CompressionNone:
+		return value, nil
+	case CompressionGzip:
+		return gzipDecompress(value, limit)
+	case CompressionSnappy:
+		return snappyDecompress(value, limit)
+	case CompressionZstd:
+		return zstdDecompress(value, limit)
+
+	default:
+		return nil, c.Validate()
+	}
+}
+
+func gzipCompress(dst, value []byte) (out []byte, err error) {
+	buf := bytes.NewBuffer(dst[:0])
+	gw := gzipWriters.Get().(*gzip.Writer)
+	defer gzipWriters.Put(gw)
+	gw.Reset(buf)
+
+	if _, err = gw.Write(value); err != nil {
+		return
+	}
+
+	if err = gw.Close(); err != nil {
+		return
+	}
+
+	return buf.Bytes(), nil
+}
+
+func gzipDecompress(value []byte, limit int) (out []byte, err error) {
+	var gr *gzip.Reader
+	if gr, err = gzip.NewReader(bytes.NewReader(value)); err != nil {
+		return
+	}
+	defer gr.Close()
+
+	// Read one byte past the limit to determine if the limit has been exceeded
+	if out, err = io.ReadAll(io.LimitReader(gr, int64(limit)+1)); err != nil {
+		return
+	}
+
+	if len(out) > limit {
+		return nil, ErrBlockTooLarge
+	}
+
+	return
+}
+
+func snappyDecompress(value []byte, limit int) (out []byte, err error) {
+	var n int
+	if n, err = s2.DecodedLen(value); err != nil {
+		return
+	}
+
+	// Check the declared length before it's allocated
+	if n > limit {
+		return nil, ErrBlockTooLarge
+	}
+
+	return s2.Decode(nil, value)
+}
+
+func zstdDecompress(value []byte, limit int) (out []byte, err error) {
+	if err = initZstd(); err != nil {
+		return
+	}
+
+	// Check the declared frame size before it's allocated
+	var h zstd.Header
+	if err = h.Decode(value); err != nil {
+		return
+	}
+
+	if h.HasFCS && h.FrameContentSize > uint64(limit) {
+		return nil, ErrBlockTooLarge
+	}
+
+	// Note: Frames without a declared size are bounded by the decoder's max memory
+	if out, err = zstdDecoder.DecodeAll(value, nil); err == zstd.ErrDecoderSizeExceeded {
+		return nil, ErrBlockTooLarge
+	} else if err != nil {
+		return
+	}
+
+	if len(out) > limit {
+		return nil, ErrBlockTooLarge
+	}
+
+	return
+}
+
+func initZstd() error {
+	zstdOnce.Do(func() {
+		// Note: EncodeAll and DecodeAll are safe for concurrent use
+		if zstdEncoder, zstdErr = zstd.NewWriter(nil); zstdErr != nil {
+			return
+		}
+
+		zstdDecoder, zstdErr = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(maxBlockSize))
+	})
+
+	return zstdErr
+}
@@ -0,0 +1,121 @@
+package kiroku
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestCompression_compress_decompress(t *testing.T) {
+	type testcase struct {
+		name string
+		c    Compression
+	}
+
+	tests := []testcase{
+		{name: "none", c: CompressionNone},
+		{name: "gzip", c: CompressionGzip},
+		{name: "snappy", c: CompressionSnappy},
+		{name: "zstd", c: CompressionZstd},
+	}
+
+	value := bytes.Repeat([]byte("hello world! "), 1024)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compressed, err := tt.c.compress(nil, value)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.c != CompressionNone && len(compressed) >= len(value) {
+				t.Fatalf("expected compressed value to be smaller than %d and received %d", len(value), len(compressed))
+			}
+
+			var got []byte
+			if got, err = tt.c.decompress(compressed, maxBlockSize); err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(got, value) {
+				t.Fatalf("invalid decompressed value")
+			}
+		})
+	}
+}
+
+func TestCompression_decompress_limit(t *testing.T) {
+	type testcase struct {
+		name    string
+		c       Compression
+		limit   int
+		wantErr error
+	}
+
+	value := bytes.Repeat([]byte{0}, 1024)
+	tests := []testcase{
+		{name: "gzip", c: CompressionGzip, limit: len(value)},
+		{name: "gzip exceeded", c: CompressionGzip, limit: len(value) - 1, wantErr: ErrBlockTooLarge},
+		{name: "snappy", c: CompressionSnappy, limit: len(value)},
+		{name: "snappy exceeded", c: CompressionSnappy, limit: len(value) - 1, wantErr: ErrBlockTooLarge},
+		{name: "zstd", c: CompressionZstd, limit: len(value)},
+		{name: "zstd exceeded", c: CompressionZstd, limit: len(value) - 1, wantErr: ErrBlockTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compressed, err := tt.c.compress(nil, value)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []byte
+			if got, err = tt.c.decompress(compressed, tt.limit); err != tt.wantErr {
+				t.Fatalf("invalid error, expected %v and received %v", tt.wantErr, err)
+			}
+
+			if tt.wantErr == nil && !bytes.Equal(got, value) {
+				t.Fatalf("invalid decompressed value")
+			}
+		})
+	}
+}
+
+func TestCompression_Validate(t *testing.T) {
+	if err := CompressionZstd.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := Compression(99).Validate(); err == nil {
+		t.Fatal("expected error and received nil")
+	}
+}
+
+func TestCompression_UnmarshalText(t *testing.T) {
+	type testcase struct {
+		name    string
+		json    string
+		want    Compression
+		wantErr bool
+	}
+
+	tests := []testcase{
+		{name: "empty", json: `{"compression":""}`, want: CompressionNone},
+		{name: "gzip", json: `{"compression":"gzip"}`, want: CompressionGzip},
+		{name: "snappy", json: `{"compression":"snappy"}`, want: CompressionSnappy},
+		{name: "zstd", json: `{"compression":"zstd"}`, want: CompressionZstd},
+		{name: "invalid", json: `{"compression":"lz4"}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var o Options
+			if err := json.Unmarshal([]byte(tt.json), &o); (err != nil) != tt.wantErr {
+				t.Fatalf("json.Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if o.Compression != tt.want {
+				t.Fatalf("invalid compression, expected %v and received %v", tt.want, o.Compression)
+			}
+		})
+	}
+}
@@ -5,12 +5,11 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"io/fs"
-	"log"
 	"os"
 	"path"
-	"path/filepath"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/hatchify/errors"
 )
@@ -26,6 +25,8 @@ const (
 	ErrQueueFull = errors.Error("cannot download more, queue full")
 	// ErrEmptyList is returned when a list is empty
 	ErrEmptyList = errors.Error("list is empty, please retrieve a new list")
+	// ErrFreeSpaceUnsupported is returned when ConsumerMinFreeBytes is set on a platform which cannot determine free disk space
+	ErrFreeSpaceUnsupported = errors.Error("determining free disk space is only supported on unix platforms")
 )
 
 // NewConsumer will initialize a new Consumer instance
@@ -41,8 +42,8 @@ func NewConsumerWithContext(ctx context.Context, opts Options, src Source, onUpd
 	}
 
 	if err = c.getLatestSnapshot(); err != nil {
+		c.log.error("Consumer.NewConsumerWithContext(): error getting latest snapshot", LogKeyError, err)
 		err = fmt.Errorf("Consumer.NewConsumerWithContext(): error getting latest snapshot: %v", err)
-		c.opts.OnError(err)
 		return
 	}
 
@@ -68,8 +69,8 @@ func NewOneShotConsumerWithContext(ctx context.Context, opts Options, src Source
 	}
 
 	if err = c.getLatestSnapshot(); err != nil {
+		c.log.error("Consumer.NewOneShotConsumerWithContext(): error getting latest snapshot", LogKeyError, err)
 		err = fmt.Errorf("Consumer.NewOneShotConsumerWithContext(): error getting latest snapshot: %v", err)
-		c.opts.OnError(err)
 		return
 	}
 
@@ -88,6 +89,9 @@ func newConsumer(ctx context.Context, opts Options, src Source, onUpdate UpdateF
 		return
 	}
 
+	// Record errors for Stats
+	opts.Logger = withErrorStats(&c.errs, opts.Logger)
+
 	if c.m, err = newMappedMeta(opts); err != nil {
 		return
 	}
@@ -100,6 +104,8 @@ func newConsumer(ctx context.Context, opts Options, src Source, onUpdate UpdateF
 		if meta.LastDownloadedTimestamp > 0 {
 			meta.LastProcessedTimestamp = meta.LastDownloadedTimestamp
 			meta.LastProcessedType = meta.LastDownloadedType
+			meta.LastProcessedProducerID = meta.LastDownloadedProducerID
+			meta.LastProcessedSequence = meta.LastDownloadedSequence
 		}
 
 		// Ensure the last processed timestamp is not less than the range start
@@ -115,13 +121,35 @@ func newConsumer(ctx context.Context, opts Options, src Source, onUpdate UpdateF
 
 	c.ctx, c.close = context.WithCancel(ctx)
 	c.opts = opts
+	c.log = newLogger(opts.Logger, opts.FullName())
 	c.src = src
 	c.onUpdate = onUpdate
+	if opts.ConsumerLookback > 0 {
+		if c.j, err = newJournal(opts.Dir, opts.FullName()); err != nil {
+			return
+		}
+	}
+
+	c.seqs = newSequenceTracker()
+	// Resume sequence tracking from the last processed file
+	meta := c.m.Get()
+	c.seqs.Observe(meta.lastProcessed(c.opts.FullName()))
+	c.q = newQuarantine(c.opts, c.log)
+	c.pr = newProgress(c.ctx, c.opts, c.src, c.log)
+	c.w = newWatcher(c.ctx, c.opts, c.onChunk, TypeChunk, TypeSnapshot)
 	if c.queueLength, err = c.getQueueLength(); err != nil {
 		return
 	}
 
-	c.w = newWatcher(c.ctx, c.opts, c.onChunk, TypeChunk, TypeSnapshot)
+	if opts.ConsumerMinFreeBytes > 0 {
+		// Ensure free space can be determined for the directory
+		if _, err = getFreeSpace(opts.Dir); err != nil {
+			return
+		}
+	}
+
+	c.n = newNotifier()
+	c.subscribe()
 	ref = &c
 	return
 }
@@ -135,19 +163,49 @@ type Consumer struct {
 	m *mappedMeta
 
 	w *watcher
+	n *notifier
+	// Note: This field is nil when the QuarantinePolicy is empty
+	q *quarantine
+	// Note: This field is nil when ConsumerID is not set
+	pr *progress
 
 	// Queue length is only used when capacity is set
 	queueLength int64
+	// Size of the files currently being downloaded, this is only used when
+	// ConsumerByteLimit or ConsumerMinFreeBytes are set
+	pendingBytes int64
+	// Serializes the reservation of the next file, this is only used when
+	// ConsumerByteLimit or ConsumerMinFreeBytes are set
+	rmux sync.Mutex
 
 	// List of filenames to download from, when this is empty - more can be replenished
 	// Note: This is only accessed during mappedMeta.Update, this func is what protects
 	// thread safety for filenames
 	f filenames
+	// Filename the next list will begin after, this is only used when ConsumerLookback is set
+	// Note: This is only accessed during mappedMeta.Update
+	cursor string
+	// Note: This field is nil when ConsumerLookback is not set
+	j *journal
 
 	opts     Options
+	log      logger
 	src      Source
 	onUpdate UpdateFunc
 
+	// Stats counters
+	errs       errorStats
+	downloaded counter
+	processed  counter
+	// Timestamp of the newest file seen within the Source
+	newest int64
+	// Number of sequence gaps and missing files detected
+	sequenceGaps int64
+	missingFiles int64
+	quarantined  int64
+
+	seqs *sequenceTracker
+
 	swg sync.WaitGroup
 }
 
@@ -162,6 +220,81 @@ func (c *Consumer) Meta() (meta Meta, err error) {
 	return
 }
 
+// Stats will return the current ConsumerStats
+func (c *Consumer) Stats() (stats ConsumerStats, err error) {
+	if isClosed(c.ctx) {
+		err = errors.ErrIsClosed
+		return
+	}
+
+	var n int
+	if n, err = c.w.Len(); err != nil {
+		err = fmt.Errorf("error counting queued files: %v", err)
+		return
+	}
+
+	stats.QueueDepth = int64(n)
+	if stats.QueueBytes, err = c.getQueueBytes(); err != nil {
+		err = fmt.Errorf("error getting queued bytes: %v", err)
+		return
+	}
+
+	switch stats.FreeBytes, err = getFreeSpace(c.opts.Dir); err {
+	case nil:
+	case ErrFreeSpaceUnsupported:
+		err = nil
+	default:
+		return
+	}
+
+	meta := c.m.Get()
+	stats.FilesDownloaded, stats.BytesDownloaded = c.downloaded.get()
+	stats.FilesProcessed, stats.BytesProcessed = c.processed.get()
+	stats.LastProcessedTimestamp = meta.LastProcessedTimestamp
+	stats.NewestSourceTimestamp = atomic.LoadInt64(&c.newest)
+	if stats.NewestSourceTimestamp < stats.LastProcessedTimestamp {
+		// Newest seen file is behind our last processed file, use the last processed timestamp
+		stats.NewestSourceTimestamp = stats.LastProcessedTimestamp
+	}
+
+	stats.Lag = time.Duration(stats.NewestSourceTimestamp - stats.LastProcessedTimestamp)
+	stats.ErrorCount, stats.LastError, stats.LastErrorAt = c.errs.get()
+	stats.RetryCount = c.w.Retries()
+	stats.WatcherState = c.w.State()
+	stats.SequenceGaps = atomic.LoadInt64(&c.sequenceGaps)
+	stats.MissingFiles = atomic.LoadInt64(&c.missingFiles)
+	stats.Quarantined = atomic.LoadInt64(&c.quarantined)
+	return
+}
+
+// Quarantined will return the files which have been quarantined after repeatedly
+// failing to apply
+func (c *Consumer) Quarantined() (files []QuarantinedFile, err error) {
+	if isClosed(c.ctx) {
+		err = errors.ErrIsClosed
+		return
+	}
+
+	return c.q.List()
+}
+
+// Reinject will move a quarantined file back into the queue so it is applied again
+// Note: Files are applied in order, the file will be applied before any newer queued files.
+// The file resumes from the block following the blocks applied before it was quarantined
+func (c *Consumer) Reinject(filename Filename) (err error) {
+	if isClosed(c.ctx) {
+		err = errors.ErrIsClosed
+		return
+	}
+
+	if err = c.q.Reinject(filename); err != nil {
+		return
+	}
+
+	c.w.trigger(filename)
+	return
+}
+
 // Close will close the selected instance of Kiroku
 func (c *Consumer) Close() (err error) {
 	if isClosed(c.ctx) {
@@ -175,14 +308,24 @@ func (c *Consumer) Close() (err error) {
 		return
 	}
 
-	return c.m.Close()
+	var errs errors.ErrorList
+	// Export the progress of the files processed since the last export
+	errs.Push(c.pr.Flush(context.Background()))
+	errs.Push(c.j.Close())
+	errs.Push(c.m.Close())
+	return errs.Err()
 }
 
 func (c *Consumer) scan(endOnEOF bool) {
 	var err error
 	defer c.swg.Done()
 
-	var hasError bool
+	var (
+		hasError bool
+		// Number of consecutive failed attempts
+		attempt int
+	)
+
 	resume := func() {}
 	if c.opts.OnResume != nil {
 		resume = func() {
@@ -196,7 +339,15 @@ func (c *Consumer) scan(endOnEOF bool) {
 	}
 
 	for err == nil && !isClosed(c.ctx) {
+		// Notifications received while syncing will wake the following wait
+		gen := c.n.Generation()
 		err = c.sync()
+		if err != nil && !isScanSignal(err) {
+			attempt++
+		} else {
+			attempt = 0
+		}
+
 		switch err {
 		case nil:
 			resume()
@@ -205,32 +356,67 @@ func (c *Consumer) scan(endOnEOF bool) {
 				return
 			}
 
-			if c.opts.Debugging {
-				fmt.Println("End of results found, sleeping")
-			}
+			c.log.debug("end of results found, sleeping", "delay", c.opts.EndOfResultsDelay)
 
 			resume()
-			err = sleep(c.ctx, c.opts.EndOfResultsDelay)
+			err = c.n.Wait(c.ctx, gen, c.opts.EndOfResultsDelay)
 		case ErrQueueFull:
-			if c.opts.Debugging {
-				fmt.Println("Queue full, sleeping")
-			}
+			c.log.debug("queue full, sleeping", "delay", c.opts.EndOfResultsDelay)
 
 			resume()
 			err = sleep(c.ctx, c.opts.EndOfResultsDelay)
 		case ErrEmptyList:
-			if c.opts.Debugging {
-				fmt.Println("Empty list, retrying")
-			}
+			c.log.debug("empty list, retrying")
 
 			resume()
 
 		default:
-			err = fmt.Errorf("Consumer.scan(): error updating: %v", err)
-			c.opts.OnError(err)
+			if e, ok := c.opts.Retry.escalation(Filename{}, int64(attempt), err); ok {
+				c.log.error("Consumer.scan(): error escalated, halting", LogKeyAttempt, attempt, "permanent", e.Permanent, LogKeyError, err)
+				if c.opts.OnEscalate != nil {
+					c.opts.OnEscalate(e)
+				}
+
+				return
+			}
+
+			delay := c.opts.Retry.delay(int64(attempt), c.opts.ErrorDelay, randomJitter)
+			c.log.error("Consumer.scan(): error updating", LogKeyAttempt, attempt, LogKeyError, err, "delay", delay)
 			hasError = true
-			err = sleep(c.ctx, c.opts.ErrorDelay)
+			err = sleep(c.ctx, delay)
+		}
+	}
+}
+
+// subscribe will wake scanners when the Source notifies of new files
+// Note: Scanners poll every EndOfResultsDelay when the Source is not a Watcher
+func (c *Consumer) subscribe() {
+	w, ok := c.src.(Watcher)
+	if !ok {
+		return
+	}
+
+	filenames, err := w.Subscribe(c.ctx, c.opts.FullName())
+	if err != nil {
+		c.log.warn("error subscribing to source, falling back to polling", LogKeyError, err)
+		return
+	}
+
+	go func() {
+		for filename := range filenames {
+			c.log.debug("source notification received", LogKeyFilename, filename)
+			c.n.Notify()
 		}
+	}()
+}
+
+// isScanSignal returns whether or not an error returned by sync is a signal rather than a failure
+func isScanSignal(err error) bool {
+	switch err {
+	case io.EOF, ErrQueueFull, ErrEmptyList:
+		return true
+	default:
+		return false
 	}
 }
 
@@ -253,41 +439,159 @@ func (c *Consumer) isWithinCapcity() (ok bool, err error) {
 		}
 	}
 
-	ok = c.queueLength < c.opts.ConsumerFileLimit
+	if ok = c.queueLength < c.opts.ConsumerFileLimit; ok {
+		// Ensure the queued bytes have not already reached the byte limits
+		if ok, err = c.isWithinByteCapacity(0); err != nil {
+			return
+		}
+	}
+
 	c.queueLength++
 
-	if c.opts.Debugging {
-		fmt.Printf("Consumer[%s].isWithinCapacity(): Length %d / Limit %d / Ok %v\n", c.opts.FullName(), c.queueLength, c.opts.ConsumerFileLimit, ok)
-	}
+	c.log.debug("Consumer.isWithinCapacity()", "length", c.queueLength, "limit", c.opts.ConsumerFileLimit, "ok", ok)
 
 	return
 }
 
-func (c *Consumer) getQueueLength() (n int64, err error) {
-	err = filepath.Walk(c.opts.Dir, func(path string, info fs.FileInfo, ierr error) (err error) {
-		switch {
-		case ierr == nil:
-		case os.IsNotExist(ierr):
-			return nil
-		default:
-			log.Printf("error opening iterating file: %v\n", ierr)
-			return nil
+// isWithinByteCapacity will return whether or not a file of the provided size can be downloaded
+// within ConsumerByteLimit and ConsumerMinFreeBytes
+func (c *Consumer) isWithinByteCapacity(size int64) (ok bool, err error) {
+	if c.opts.ConsumerByteLimit > 0 {
+		var queued int64
+		if queued, err = c.getQueueBytes(); err != nil {
+			return
 		}
 
-		var fn Filename
-		base := filepath.Base(path)
-		if fn, err = ParseFilename(base); err != nil {
-			return nil
+		// Note: A file is always permitted when the queue is empty, so files
+		// larger than the limit can be downloaded
+		if queued > 0 && queued+size > c.opts.ConsumerByteLimit {
+			c.log.debug("Consumer.isWithinByteCapacity(): byte limit reached", "queued", queued, "size", size, "limit", c.opts.ConsumerByteLimit)
+			return false, nil
 		}
+	}
 
-		if fn.Name != c.opts.FullName() {
-			return nil
+	if c.opts.ConsumerMinFreeBytes > 0 {
+		var free int64
+		if free, err = getFreeSpace(c.opts.Dir); err != nil {
+			return
 		}
 
-		n++
-		return nil
-	})
+		if free-size < c.opts.ConsumerMinFreeBytes {
+			c.log.debug("Consumer.isWithinByteCapacity(): free space threshold reached", "free", free, "size", size, "minimum", c.opts.ConsumerMinFreeBytes)
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// hasByteLimits will return whether or not ConsumerByteLimit or ConsumerMinFreeBytes are set
+func (c *Consumer) hasByteLimits() bool {
+	return c.opts.ConsumerByteLimit > 0 || c.opts.ConsumerMinFreeBytes > 0
+}
+
+// lockReservations will serialize reservations when byte limits are set, the returned
+// func will unlock
+func (c *Consumer) lockReservations() (unlock func()) {
+	if !c.hasByteLimits() {
+		return func() {}
+	}
+
+	c.rmux.Lock()
+	return c.rmux.Unlock
+}
+
+// reserveNext will reserve the size of the next file within the byte limits, ErrQueueFull
+// is returned when the file cannot be downloaded
+// Note: The file is returned to the list so it's taken once reserved, reservations must be
+// serialized by lockReservations so the file is not taken by another scanner
+func (c *Consumer) reserveNext() (size int64, err error) {
+	if !c.hasByteLimits() {
+		return
+	}
+
+	var filename string
+	if err = c.m.Update(func(meta Meta) (out Meta, err error) {
+		if filename, err = c.getNextFilename(meta); err != nil {
+			return
+		}
+
+		c.f.Unshift(filename)
+		out = meta
+		return
+	}); err != nil || len(filename) == 0 {
+		return
+	}
+
+	// Note: The Source is called without the Meta locked, so applying chunks is not blocked
+	return c.reserveBytes(filename)
+}
+
+// reserveBytes will reserve the size of a file within the byte limits, ErrQueueFull is
+// returned when the file cannot be downloaded
+// Note: The returned size must be released once the download has completed
+func (c *Consumer) reserveBytes(filename string) (size int64, err error) {
+	if !c.hasByteLimits() {
+		return
+	}
+
+	if size, err = c.getSize(filename); err != nil {
+		err = fmt.Errorf("error getting size of <%s>: %v", filename, err)
+		return
+	}
+
+	var ok bool
+	if ok, err = c.isWithinByteCapacity(size); err != nil {
+		return 0, err
+	} else if !ok {
+		return 0, ErrQueueFull
+	}
+
+	atomic.AddInt64(&c.pendingBytes, size)
+	return
+}
+
+// getSize will return the size of a file within the Source
+// Note: Sizer is used when implemented, as GetInfo may hash the file
+func (c *Consumer) getSize(filename string) (size int64, err error) {
+	if s, ok := c.src.(Sizer); ok {
+		return s.GetSize(c.ctx, c.opts.FullName(), filename)
+	}
+
+	var info Info
+	if info, err = c.src.GetInfo(c.ctx, c.opts.FullName(), filename); err != nil {
+		return
+	}
+
+	return info.Size, nil
+}
+
+// getQueueBytes will return the size of the files waiting to be processed, including
+// the files currently being downloaded
+func (c *Consumer) getQueueBytes() (n int64, err error) {
+	switch n, err = c.w.Size(); {
+	case err == nil:
+	case os.IsNotExist(err):
+		n, err = 0, nil
+	default:
+		return
+	}
+
+	n += atomic.LoadInt64(&c.pendingBytes)
+	return
+}
+
+func (c *Consumer) getQueueLength() (n int64, err error) {
+	var length int
+	switch length, err = c.w.Len(); {
+	case err == nil:
+	case os.IsNotExist(err):
+		return 0, nil
+	default:
+		return
+	}
 
+	n = int64(length)
 	return
 }
 
@@ -298,21 +602,24 @@ func (c *Consumer) getNextFilename(meta Meta) (filename string, err error) {
 	}
 
 	// Our filelist is empty, so we need to repopulate it.
-	// Determine the filename of our last processed file by using the last processed timestamp and type
-	lastFile := makeFilename(c.opts.FullName(), meta.LastProcessedTimestamp, meta.LastProcessedType)
+	lastFile := c.getListStart(meta)
 
 	var filenames []string
 	// Get next batch of filenames starting from immediately after the last file we processed
-	filenames, err = c.src.GetNextList(c.ctx, c.opts.FullName(), lastFile.String(), c.opts.ConsumerGetNextListSize)
+	filenames, err = c.src.GetNextList(c.ctx, c.opts.FullName(), lastFile, c.opts.ConsumerGetNextListSize)
 	switch err {
 	case nil:
+		c.setNewest(filenames)
 		c.f.Append(filenames)
 		if filename, ok = c.f.Shift(); !ok {
 			err = ErrEmptyList
+			return
 		}
 
+		c.cursor = filenames[len(filenames)-1]
 		return
 	case io.EOF:
+		c.onEndOfList(meta)
 		return
 
 	default:
@@ -321,6 +628,46 @@ func (c *Consumer) getNextFilename(meta Meta) (filename string, err error) {
 	}
 }
 
+// getListStart will return the filename the next list will begin after
+func (c *Consumer) getListStart(meta Meta) string {
+	if c.opts.ConsumerLookback <= 0 || len(c.cursor) == 0 {
+		// Determine the filename of our last processed file by using the last processed timestamp and type
+		return meta.lastProcessed(c.opts.FullName()).String()
+	}
+
+	return c.cursor
+}
+
+// onEndOfList will rewind the list cursor to the beginning of the lookback window
+// so files which became visible out of order are discovered by the next list
+func (c *Consumer) onEndOfList(meta Meta) {
+	if c.opts.ConsumerLookback <= 0 {
+		return
+	}
+
+	windowStart := meta.LastProcessedTimestamp - int64(c.opts.ConsumerLookback)
+	c.cursor = makeFilename(c.opts.FullName(), windowStart-1, TypeInvalid).String()
+	if err := c.j.Prune(windowStart); err != nil {
+		c.log.warn("error pruning journal", LogKeyError, err)
+	}
+}
+
+// setNewest will update the newest seen Source timestamp from a list of filenames
+func (c *Consumer) setNewest(filenames []string) {
+	if len(filenames) == 0 {
+		return
+	}
+
+	parsed, err := ParseFilename(filenames[len(filenames)-1])
+	if err != nil {
+		return
+	}
+
+	if parsed.CreatedAt > atomic.LoadInt64(&c.newest) {
+		atomic.StoreInt64(&c.newest, parsed.CreatedAt)
+	}
+}
+
 func (c *Consumer) getNext() (err error) {
 	var ok bool
 	if ok, err = c.isWithinCapcity(); err != nil {
@@ -329,13 +676,28 @@ func (c *Consumer) getNext() (err error) {
 		return ErrQueueFull
 	}
 
-	var filename string
-	if err = c.m.Update(func(meta Meta) (out Meta, err error) {
+	var (
+		filename string
+		parsed   Filename
+		gap      SequenceGap
+		hasGap   bool
+		skip     bool
+		size     int64
+	)
+
+	// Reserve the size of the next file before the Meta is locked
+	// Note: Reservations are serialized so the reserved file is the next file taken
+	unlock := c.lockReservations()
+	if size, err = c.reserveNext(); err != nil {
+		unlock()
+		return
+	}
+
+	err = c.m.Update(func(meta Meta) (out Meta, err error) {
 		if filename, err = c.getNextFilename(meta); err != nil {
 			return
 		}
 
-		var parsed Filename
 		if parsed, err = ParseFilename(filename); err != nil {
 			return
 		}
@@ -351,23 +713,68 @@ func (c *Consumer) getNext() (err error) {
 			return
 		}
 
-		// Set last processed
-		meta.LastProcessedTimestamp = parsed.CreatedAt
-		meta.LastProcessedType = parsed.Filetype
+		out = meta
+		if skip = !c.shouldQueue(meta, parsed); skip {
+			return
+		}
+
+		if parsed.Filetype == TypeSnapshot && parsed.CreatedAt > meta.LastSnapshotTimestamp {
+			meta.LastSnapshotTimestamp = parsed.CreatedAt
+		}
+
+		// Check for missing sequence numbers
+		// Note: This is called while the Meta is locked so files are observed in order
+		gap, hasGap = c.seqs.Observe(parsed)
+		if filename > meta.lastProcessed(c.opts.FullName()).String() {
+			// Set last processed
+			meta.setProcessed(parsed)
+		} else {
+			c.log.info("late arrival found within lookback window", LogKeyFilename, filename, LogKeyType, parsed.Filetype)
+		}
+
 		out = meta
 		return
-	}); err != nil {
+	})
+	unlock()
+	if err != nil || skip {
+		// Release the reserved bytes, the file will not be downloaded
+		atomic.AddInt64(&c.pendingBytes, -size)
 		return
 	}
 
-	if err = c.download(filename); err != nil {
-		err = fmt.Errorf("error downloading <%s>: %v", filename, err)
+	if hasGap {
+		c.onSequenceGap(gap)
+	}
+
+	err = c.download(filename)
+	// Release the reserved bytes, downloaded files are included within the watcher size
+	atomic.AddInt64(&c.pendingBytes, -size)
+	if err != nil {
+		// Release the file so it can be retried within the lookback window
+		c.j.Cancel(parsed)
+		err = c.opts.Retry.annotate(fmt.Sprintf("error downloading <%s>", filename), err)
 		return
 	}
 
 	return
 }
 
+// shouldQueue will return whether or not a listed file should be queued for download
+// Note: Files are always queued when ConsumerLookback is not set
+func (c *Consumer) shouldQueue(meta Meta, filename Filename) bool {
+	if c.j == nil {
+		return true
+	}
+
+	if filename.CreatedAt < meta.LastSnapshotTimestamp {
+		// File has been superseded by a downloaded snapshot
+		return false
+	}
+
+	// Ensure the file has not already been downloaded
+	return c.j.Reserve(filename)
+}
+
 func (c *Consumer) isWithinRange(filename Filename) (inRange bool, err error) {
 	if c.opts.RangeEnd.IsZero() {
 		return true, nil
@@ -426,14 +833,17 @@ func (c *Consumer) getLatestSnapshot() (err error) {
 	}
 
 	if err = c.m.Update(func(m Meta) (out Meta, err error) {
-		m.LastProcessedTimestamp = filename.CreatedAt
-		m.LastProcessedType = filename.Filetype
+		m.setProcessed(filename)
+		m.LastSnapshotTimestamp = filename.CreatedAt
 		out = m
 		return
 	}); err != nil {
 		return
 	}
 
+	// Files preceding the snapshot are not downloaded, begin sequence tracking from the snapshot
+	c.seqs.Observe(filename)
+
 	if err = c.download(latestSnapshot); err != nil {
 		return fmt.Errorf("error downloading initial snapshot <%s>: %v", latestSnapshot, err)
 	}
@@ -443,7 +853,7 @@ func (c *Consumer) getLatestSnapshot() (err error) {
 
 func (c *Consumer) getLatestSnapshotFilename() (filename string, err error) {
 	snapshotFilename := getSnapshotName(c.opts.FullName())
-	err = c.src.Get(c.ctx, "_latestSnapshots", snapshotFilename, func(r io.Reader) (err error) {
+	err = c.src.Get(c.ctx, LatestSnapshotsPrefix, snapshotFilename, func(r io.Reader) (err error) {
 		buf := bytes.NewBuffer(nil)
 		_, err = io.Copy(buf, r)
 		switch err {
@@ -460,10 +870,19 @@ func (c *Consumer) getLatestSnapshotFilename() (filename string, err error) {
 }
 
 func (c *Consumer) download(filename string) (err error) {
+	var fnm Filename
+	if fnm, err = ParseFilename(filename); err != nil {
+		err = fmt.Errorf("error parsing filename <%s>: %v", filename, err)
+		return
+	}
+
 	var tmpFilepath string
-	c.opts.OnLog(fmt.Sprintf("downloading <%s>", filename))
-	defer c.opts.OnLog(fmt.Sprintf("downloaded <%s>", filename))
-	if tmpFilepath, err = c.downloadTemp(filename); err != nil {
+	c.log.info("downloading", LogKeyFilename, filename, LogKeyType, fnm.Filetype)
+	defer c.log.info("downloaded", LogKeyFilename, filename, LogKeyType, fnm.Filetype)
+	ctx, end := beginPhase(c.ctx, c.opts.Observer, PhaseDownload, c.opts.FullName(), fnm)
+	tmpFilepath, err = c.downloadTemp(ctx, filename)
+	end(getFileSize(tmpFilepath), err)
+	if err != nil {
 		return
 	}
 	// Always ensure temporary file is deleted after this function is over
@@ -477,18 +896,17 @@ func (c *Consumer) download(filename string) (err error) {
 		return
 	}
 
-	var fnm Filename
-	if fnm, err = ParseFilename(filename); err != nil {
-		err = fmt.Errorf("error parsing filename <%s>: %v", filename, err)
+	c.m.SetDownloaded(fnm)
+	if err = c.j.Commit(fnm); err != nil {
 		return
 	}
 
-	c.m.SetDownloaded(fnm.CreatedAt, fnm.Filetype)
-	c.w.trigger()
+	c.downloaded.add(getFileSize(filepath))
+	c.w.trigger(fnm)
 	return
 }
 
-func (c *Consumer) downloadTemp(filename string) (tmpFilepath string, err error) {
+func (c *Consumer) downloadTemp(ctx context.Context, filename string) (tmpFilepath string, err error) {
 	var tmp *os.File
 	tmpFilepath = path.Join(c.opts.Dir, "_downloading."+filename)
 	if tmp, err = createFile(tmpFilepath); err != nil {
@@ -497,8 +915,24 @@ func (c *Consumer) downloadTemp(filename string) (tmpFilepath string, err error)
 	}
 	defer tmp.Close()
 
-	if err = c.src.Import(c.ctx, c.opts.FullName(), filename, tmp); err != nil {
-		err = fmt.Errorf("error downloading from source: %v", err)
+	if c.opts.KeyProvider == nil {
+		if err = c.src.Import(ctx, c.opts.FullName(), filename, tmp); err != nil {
+			err = fmt.Errorf("error downloading from source: %v", err)
+		}
+
+		return
+	}
+
+	// Decrypt the chunk as it's downloaded
+	// Note: Errors are wrapped so decryption errors are classified as permanent
+	d := newDecrypter(ctx, c.opts.KeyProvider, tmp, c.opts.AllowUnencrypted)
+	if err = c.src.Import(ctx, c.opts.FullName(), filename, d); err != nil {
+		err = fmt.Errorf("error downloading from source: %w", err)
+		return
+	}
+
+	if err = d.Close(); err != nil {
+		err = fmt.Errorf("error decrypting <%s>: %w", filename, err)
 		return
 	}
 
@@ -506,19 +940,122 @@ func (c *Consumer) downloadTemp(filename string) (tmpFilepath string, err error)
 }
 
 func (c *Consumer) onChunk(filename Filename) (err error) {
+	meta := c.m.Get()
 	// Process chunk
 	filepath := path.Join(c.opts.Dir, filename.String())
-	if err = Read(filepath, func(r *Reader) (err error) {
+	size := getFileSize(filepath)
+	var (
+		token uint64
+		stale bool
+	)
+
+	_, end := beginPhase(c.ctx, c.opts.Observer, PhaseApply, c.opts.FullName(), filename)
+	err = Read(filepath, func(r *Reader) (err error) {
+		// Ensure the chunk was not written under a stale lease
+		if token, stale, err = checkFencingToken(meta, r); err != nil || stale {
+			return
+		}
+
+		// Resume from the block following the last applied block
+		// Note: This ensures blocks are not re-applied when a chunk is retried or re-injected
+		r.start = c.getStartIndex(meta, filename)
+		if c.opts.BlockCheckpoints {
+			r.onApplied = func(pos Position) {
+				c.m.SetApplied(filename, pos.Index)
+			}
+		}
+
 		return c.onUpdate(filename.Filetype, r)
-	}); err != nil {
-		err = fmt.Errorf("error encountered while processing: %v", err)
-		return
+	})
+
+	if stale {
+		// Chunk was fenced off, the Observer is notified with ErrStaleFencingToken
+		end(0, ErrStaleFencingToken)
+	} else {
+		end(size, err)
+	}
+
+	if err != nil {
+		// Note: The error is annotated so permanent errors from the UpdateFunc are not retried
+		err = c.opts.Retry.annotate("error encountered while processing", err)
+		return c.onApplyError(filename, err)
+	}
+
+	if stale {
+		// Note: The chunk is skipped and removed, so a Producer which lost it's lease cannot halt the Consumer
+		c.log.warn("skipping chunk written under a stale lease", LogKeyFilename, filename.String(), LogKeyType, filename.Filetype, "token", token, "lastToken", meta.LastFencingToken)
+	} else {
+		c.m.SetFencingToken(token)
 	}
 
-	if err = os.Remove(filepath); err != nil {
+	// Register progress, which is exported once the progress interval has elapsed
+	c.pr.Set(filename)
+
+	_, end = beginPhase(c.ctx, c.opts.Observer, PhaseRemove, c.opts.FullName(), filename)
+	err = os.Remove(filepath)
+	end(0, err)
+	if err != nil {
 		err = fmt.Errorf("error encountered while removing processed file <%s>: %v", filename, err)
 		return
 	}
 
+	if err = c.q.OnApplied(filename); err != nil {
+		// Note: The file has been applied, the error is logged rather than retried
+		c.log.warn("error removing quarantine record", LogKeyFilename, filename.String(), LogKeyError, err)
+		err = nil
+	}
+
+	c.processed.add(size)
 	return
 }
+
+// getStartIndex will return the index of the first block of the file which has not been applied
+// Note: Files are applied from the first block when BlockCheckpoints is not set
+func (c *Consumer) getStartIndex(meta Meta, filename Filename) (start int64) {
+	if !c.opts.BlockCheckpoints {
+		return
+	}
+
+	start = meta.nextBlockIndex(filename)
+	if applied := c.q.AppliedBlocks(filename); applied > start {
+		// File was re-injected after being quarantined, the checkpoint has moved on to later files
+		start = applied
+	}
+
+	return
+}
+
+// checkFencingToken will return the fencing token of the chunk, and whether or not the chunk
+// was written under an older lease than a previously applied chunk
+// Note: Chunks without a fencing token (E.g. written without a LeasePolicy) are not checked
+func checkFencingToken(meta Meta, r *Reader) (token uint64, stale bool, err error) {
+	var h Header
+	if h, err = r.Header(); err != nil {
+		return
+	}
+
+	token = h.FencingToken
+	stale = token > 0 && token < meta.LastFencingToken
+	return
+}
+
+// onApplyError will quarantine the file when it has repeatedly failed to apply
+func (c *Consumer) onApplyError(filename Filename, cause error) (err error) {
+	var quarantined bool
+	applied := c.getStartIndex(c.m.Get(), filename)
+	if quarantined, err = c.q.OnFailure(filename, applied, cause); quarantined {
+		atomic.AddInt64(&c.quarantined, 1)
+	}
+
+	return
+}
+
+// onSequenceGap will report files which were not seen by the Consumer
+func (c *Consumer) onSequenceGap(gap SequenceGap) {
+	atomic.AddInt64(&c.sequenceGaps, 1)
+	atomic.AddInt64(&c.missingFiles, int64(gap.Missing()))
+	c.log.warn("sequence gap detected", LogKeyFilename, gap.Filename, LogKeyProducerID, gap.ProducerID, "expected", gap.Expected, "received", gap.Received)
+	if c.opts.OnSequenceGap != nil {
+		c.opts.OnSequenceGap(gap)
+	}
+}
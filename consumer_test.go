@@ -1,13 +1,16 @@
 package kiroku
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"path"
 	"reflect"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -1021,7 +1024,7 @@ func TestConsumer_onChunk(t *testing.T) {
 			}
 
 			if !tt.fields.missingFile {
-				w, err := newWriter(tt.fields.opts.Dir, tt.args.filename)
+				w, err := newWriter(tt.fields.opts.Dir, tt.args.filename, CompressionNone, nil, 0)
 				if err != nil {
 					t.Errorf("Consumer.onChunk(): error initializing new writer: %v", err)
 					return
@@ -1046,6 +1049,211 @@ func TestConsumer_onChunk(t *testing.T) {
 	}
 }
 
+func TestConsumer_onChunk_resume(t *testing.T) {
+	opts := MakeOptions(t.TempDir(), "test")
+	opts.BlockCheckpoints = true
+
+	var (
+		applied []string
+		failAt  = int64(2)
+	)
+
+	onUpdate := func(typ Type, r *Reader) (err error) {
+		return r.ForEachWithPosition(0, func(pos Position, b Block) (err error) {
+			if pos.Index == failAt {
+				return errors.Error("apply fail")
+			}
+
+			applied = append(applied, string(b))
+			return
+		})
+	}
+
+	// Note: The Consumer is initialized without a running watcher, so the chunk is only processed by onChunk
+	c := newTestConsumer(t, opts, onUpdate)
+	filename := makeFilename("test", time.Now().UnixNano(), TypeChunk)
+	w, err := newWriter(opts.Dir, filename, CompressionNone, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blocks := []string{"0", "1", "2", "3"}
+	for _, b := range blocks {
+		if err = w.Write(Block(b)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Fail while applying the third block
+	if err = c.onChunk(filename); err == nil {
+		t.Fatal("expected error and received nil")
+	}
+
+	meta := c.m.Get()
+	if meta.LastAppliedTimestamp != filename.CreatedAt || meta.LastAppliedBlockIndex != 1 {
+		t.Fatalf("invalid checkpoint, expected block 1 of <%d> and received block %d of <%d>", filename.CreatedAt, meta.LastAppliedBlockIndex, meta.LastAppliedTimestamp)
+	}
+
+	// Retry without failure, only the remaining blocks should be applied
+	failAt = -1
+	if err = c.onChunk(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(applied, blocks) {
+		t.Fatalf("invalid applied blocks, expected %v and received %v", blocks, applied)
+	}
+
+	if meta = c.m.Get(); meta.LastAppliedBlockIndex != 3 {
+		t.Fatalf("invalid checkpoint, expected block 3 and received block %d", meta.LastAppliedBlockIndex)
+	}
+}
+
+func TestConsumer_onChunk_retry(t *testing.T) {
+	var (
+		applied []string
+		failAt  = int64(2)
+	)
+
+	// UpdateFunc applies the chunk atomically, blocks are only committed once every block succeeds
+	c := newTestConsumer(t, MakeOptions(t.TempDir(), "test"), func(typ Type, r *Reader) (err error) {
+		var pending []string
+		if err = r.ForEachWithPosition(0, func(pos Position, b Block) (err error) {
+			if pos.Index == failAt {
+				return errors.Error("apply fail")
+			}
+
+			pending = append(pending, string(b))
+			return
+		}); err != nil {
+			return
+		}
+
+		applied = append(applied, pending...)
+		return
+	})
+
+	filename := makeFilename("test", time.Now().UnixNano(), TypeChunk)
+	w, err := newWriter(c.opts.Dir, filename, CompressionNone, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blocks := []string{"0", "1", "2", "3"}
+	for _, b := range blocks {
+		if err = w.Write(Block(b)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Fail while applying the third block, the first blocks are rolled back
+	if err = c.onChunk(filename); err == nil {
+		t.Fatal("expected error and received nil")
+	}
+
+	// Retry without failure, every block should be applied
+	failAt = -1
+	if err = c.onChunk(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(applied, blocks) {
+		t.Fatalf("invalid applied blocks, expected %v and received %v", blocks, applied)
+	}
+}
+
+func TestConsumer_onChunk_fencingToken(t *testing.T) {
+	type testcase struct {
+		name        string
+		token       uint64
+		wantApplied bool
+	}
+
+	// Note: Test cases are applied in order against the same Consumer
+	tests := []testcase{
+		{
+			name:        "first lease",
+			token:       2,
+			wantApplied: true,
+		},
+		{
+			name:  "stale lease",
+			token: 1,
+		},
+		{
+			name:        "without lease",
+			token:       0,
+			wantApplied: true,
+		},
+		{
+			name:        "same lease",
+			token:       2,
+			wantApplied: true,
+		},
+		{
+			name:        "newer lease",
+			token:       3,
+			wantApplied: true,
+		},
+	}
+
+	var applied []string
+	c := newTestConsumer(t, MakeOptions(t.TempDir(), "test"), func(typ Type, r *Reader) error {
+		return r.ForEach(0, func(b Block) error {
+			applied = append(applied, string(b))
+			return nil
+		})
+	})
+
+	var want []string
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := makeFilename("test", int64(i+1), TypeChunk)
+			w, err := newWriter(c.opts.Dir, filename, CompressionNone, nil, tt.token)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err = w.Write(Block(tt.name)); err != nil {
+				t.Fatal(err)
+			}
+
+			if err = w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			// Stale chunks are skipped rather than halting the Consumer
+			if err = c.onChunk(filename); err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.wantApplied {
+				want = append(want, tt.name)
+			}
+
+			if !reflect.DeepEqual(applied, want) {
+				t.Fatalf("invalid applied blocks, expected %v and received %v", want, applied)
+			}
+
+			if _, err = os.Stat(path.Join(c.opts.Dir, filename.String())); !os.IsNotExist(err) {
+				t.Fatalf("expected chunk to be removed, received <%v>", err)
+			}
+		})
+	}
+
+	if meta := c.m.Get(); meta.LastFencingToken != 3 {
+		t.Fatalf("invalid fencing token, expected %d and received %d", 3, meta.LastFencingToken)
+	}
+}
+
 func TestConsumer_download(t *testing.T) {
 	type args struct {
 		filename string
@@ -1526,6 +1734,7 @@ func TestConsumer_scan(t *testing.T) {
 				c.Close()
 			}
 
+			c.swg.Add(1)
 			c.scan(false)
 		})
 	}
@@ -1693,6 +1902,48 @@ func TestConsumer_isWithinCapcity(t *testing.T) {
 			wantOk:  true,
 			wantErr: false,
 		},
+		{
+			name: "within byte limit",
+			fields: fields{
+				opts: Options{
+					Name:              "foo",
+					ConsumerFileLimit: 3,
+					ConsumerByteLimit: 10,
+				},
+			},
+			prepare: func(opts *Options, dir string) (err error) {
+				return os.WriteFile(path.Join(dir, "foo.12345.chunk.kir"), []byte("hello"), 0744)
+			},
+			wantOk:  true,
+			wantErr: false,
+		},
+		{
+			name: "byte limit reached",
+			fields: fields{
+				opts: Options{
+					Name:              "foo",
+					ConsumerFileLimit: 3,
+					ConsumerByteLimit: 10,
+				},
+			},
+			prepare: func(opts *Options, dir string) (err error) {
+				return os.WriteFile(path.Join(dir, "foo.12345.chunk.kir"), []byte("hello world"), 0744)
+			},
+			wantOk:  false,
+			wantErr: false,
+		},
+		{
+			name: "free space threshold reached",
+			fields: fields{
+				opts: Options{
+					Name:                 "foo",
+					ConsumerFileLimit:    3,
+					ConsumerMinFreeBytes: math.MaxInt64,
+				},
+			},
+			wantOk:  false,
+			wantErr: false,
+		},
 		{
 			name: "error walking",
 			fields: fields{
@@ -1727,8 +1978,15 @@ func TestConsumer_isWithinCapcity(t *testing.T) {
 				}
 			}
 
+			w := &watcher{
+				opts: opts,
+				ts:   []Type{TypeChunk, TypeSnapshot},
+				idx:  newFileIndex(),
+			}
+
 			c := &Consumer{
 				opts:        opts,
+				w:           w,
 				queueLength: tt.fields.queueLength,
 			}
 
@@ -1743,3 +2001,345 @@ func TestConsumer_isWithinCapcity(t *testing.T) {
 		})
 	}
 }
+
+func TestConsumer_sequenceGap(t *testing.T) {
+	var err error
+	if err = os.MkdirAll("./testing_source", 0744); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll("./testing_source")
+
+	var src *IOSource
+	if src, err = NewIOSource("./testing_source"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	// Sequence 3 is missing
+	for i, seq := range []uint64{1, 2, 4, 5} {
+		filename := makeSequencedFilename("test", 1000+int64(i), 255, seq, TypeChunk)
+		bs := newTestChunk(Block("hello world"))
+		if _, err = src.Export(ctx, "test", filename.String(), bytes.NewReader(bs)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts := MakeOptions("./testing", "test")
+	opts.EndOfResultsDelay = time.Millisecond * 10
+	if err = os.MkdirAll(opts.Dir, 0744); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(opts.Dir)
+
+	gaps := make(chan SequenceGap, 1)
+	opts.OnSequenceGap = func(gap SequenceGap) {
+		gaps <- gap
+	}
+
+	var c *Consumer
+	if c, err = NewConsumer(opts, src, func(typ Type, r *Reader) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var stats ConsumerStats
+	waitFor(t, func() bool {
+		if stats, err = c.Stats(); err != nil {
+			t.Fatal(err)
+		}
+
+		return stats.FilesProcessed == 4
+	})
+
+	if stats.SequenceGaps != 1 || stats.MissingFiles != 1 {
+		t.Fatalf("invalid sequence stats, expected 1 gap with 1 missing file and received %d gaps with %d missing files", stats.SequenceGaps, stats.MissingFiles)
+	}
+
+	gap := <-gaps
+	if gap.ProducerID != 255 || gap.Expected != 3 || gap.Received != 4 {
+		t.Fatalf("invalid gap, expected producer <%v> from 3 to 4 and received %+v", ProducerID(255), gap)
+	}
+
+	// Ensure the last processed file is resumed with it's producer ID and sequence
+	meta := c.m.Get()
+	if last := meta.lastProcessed(opts.FullName()); last.ProducerID != 255 || last.Sequence != 5 {
+		t.Fatalf("invalid last processed file, expected sequence 5 and received %+v", last)
+	}
+}
+
+func TestConsumer_lookback(t *testing.T) {
+	var err error
+	if err = os.MkdirAll("./testing_source", 0744); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll("./testing_source")
+
+	var src *IOSource
+	if src, err = NewIOSource("./testing_source"); err != nil {
+		t.Fatal(err)
+	}
+
+	export := func(createdAt int64) {
+		filename := makeFilename("test", createdAt, TypeChunk)
+		bs := newTestChunk(Block(filename.String()))
+		if _, err = src.Export(context.Background(), "test", filename.String(), bytes.NewReader(bs)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	export(1000)
+	export(1002)
+
+	opts := MakeOptions("./testing", "test")
+	opts.EndOfResultsDelay = time.Millisecond * 10
+	opts.ConsumerLookback = time.Second
+	if err = os.MkdirAll(opts.Dir, 0744); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(opts.Dir)
+
+	var (
+		mux     sync.Mutex
+		applied []string
+	)
+
+	getApplied := func() []string {
+		mux.Lock()
+		defer mux.Unlock()
+		return append([]string{}, applied...)
+	}
+
+	var c *Consumer
+	if c, err = NewConsumer(opts, src, func(typ Type, r *Reader) error {
+		return r.ForEach(0, func(b Block) error {
+			mux.Lock()
+			defer mux.Unlock()
+			applied = append(applied, string(b))
+			return nil
+		})
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	waitFor(t, func() bool { return len(getApplied()) == 2 })
+
+	// Chunk becomes visible after a newer chunk was processed
+	export(1001)
+	waitFor(t, func() bool { return len(getApplied()) == 3 })
+
+	// Allow for additional passes of the lookback window
+	time.Sleep(time.Millisecond * 50)
+	want := []string{"test.1000.chunk.kir", "test.1002.chunk.kir", "test.1001.chunk.kir"}
+	if got := getApplied(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("invalid applied chunks, expected %v and received %v", want, got)
+	}
+
+	var meta Meta
+	if meta, err = c.Meta(); err != nil {
+		t.Fatal(err)
+	}
+
+	if meta.LastProcessedTimestamp != 1002 {
+		t.Fatalf("invalid last processed timestamp, expected %d and received %d", 1002, meta.LastProcessedTimestamp)
+	}
+}
+
+func TestConsumer_reserveBytes(t *testing.T) {
+	type testcase struct {
+		name string
+		opts Options
+		// Size of the file within the local queue
+		queued int
+		// Size of the file within the Source
+		size int
+
+		wantSize int64
+		wantErr  error
+	}
+
+	tests := []testcase{
+		{
+			name:     "no limits",
+			opts:     Options{Name: "foo"},
+			queued:   10,
+			size:     20,
+			wantSize: 0,
+		},
+		{
+			name:     "within byte limit",
+			opts:     Options{Name: "foo", ConsumerByteLimit: 30},
+			queued:   10,
+			size:     20,
+			wantSize: 20,
+		},
+		{
+			name:    "exceeds byte limit",
+			opts:    Options{Name: "foo", ConsumerByteLimit: 25},
+			queued:  10,
+			size:    20,
+			wantErr: ErrQueueFull,
+		},
+		{
+			name:     "exceeds byte limit with empty queue",
+			opts:     Options{Name: "foo", ConsumerByteLimit: 10},
+			size:     20,
+			wantSize: 20,
+		},
+		{
+			name:    "exceeds free space threshold",
+			opts:    Options{Name: "foo", ConsumerMinFreeBytes: math.MaxInt64},
+			size:    20,
+			wantErr: ErrQueueFull,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.MkdirAll("./testing_source", 0744); err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll("./testing_source")
+
+			if err := os.Mkdir("./testing", 0744); err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll("./testing")
+
+			src, err := NewIOSource("./testing_source")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			source := makeFilename("foo", 2, TypeChunk).String()
+			if _, err = src.Export(context.Background(), "foo", source, bytes.NewReader(make([]byte, tt.size))); err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.queued > 0 {
+				queued := makeFilename("foo", 1, TypeChunk).String()
+				if err = os.WriteFile(path.Join("./testing", queued), make([]byte, tt.queued), 0744); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			opts := tt.opts
+			opts.Dir = "./testing"
+			c := &Consumer{
+				ctx:  context.Background(),
+				opts: opts,
+				src:  src,
+				w:    &watcher{opts: opts, ts: []Type{TypeChunk, TypeSnapshot}, idx: newFileIndex()},
+			}
+
+			size, err := c.reserveBytes(source)
+			if err != tt.wantErr {
+				t.Fatalf("invalid error, expected <%v> and received <%v>", tt.wantErr, err)
+			}
+
+			if size != tt.wantSize {
+				t.Fatalf("invalid size, expected %d and received %d", tt.wantSize, size)
+			}
+
+			var queued int64
+			if queued, err = c.getQueueBytes(); err != nil {
+				t.Fatal(err)
+			}
+
+			if want := int64(tt.queued) + tt.wantSize; queued != want {
+				t.Fatalf("invalid queued bytes, expected %d and received %d", want, queued)
+			}
+		})
+	}
+}
+
+func TestConsumer_reserveNext(t *testing.T) {
+	opts := MakeOptions(t.TempDir(), "foo")
+	opts.ConsumerByteLimit = 100
+	filename := makeFilename("foo", 1, TypeChunk).String()
+
+	sizing := make(chan struct{})
+	release := make(chan struct{})
+	src := &mockSizer{
+		mockSource: newMockSource(
+			nil, nil, nil, nil,
+			func(ctx context.Context, prefix, lastFilename string, maxKeys int64) ([]string, error) {
+				return []string{filename}, nil
+			},
+			func(ctx context.Context, prefix, filename string) (Info, error) {
+				t.Error("GetInfo called for a Sizer")
+				return Info{}, io.EOF
+			},
+		),
+		getSizeFn: func(ctx context.Context, prefix, filename string) (int64, error) {
+			close(sizing)
+			<-release
+			return 20, nil
+		},
+	}
+
+	c := newTestConsumer(t, opts, nil)
+	c.src = src
+	c.w = &watcher{opts: opts, ts: []Type{TypeChunk, TypeSnapshot}, idx: newFileIndex()}
+
+	type result struct {
+		size int64
+		err  error
+	}
+
+	reserved := make(chan result, 1)
+	go func() {
+		size, err := c.reserveNext()
+		reserved <- result{size, err}
+	}()
+
+	<-sizing
+	// Applying chunks must not be blocked while the size is being determined
+	applied := make(chan struct{})
+	go func() {
+		c.m.SetApplied(makeFilename("foo", 0, TypeChunk), 1)
+		close(applied)
+	}()
+
+	select {
+	case <-applied:
+	case <-time.After(time.Second * 2):
+		t.Fatal("timed out waiting for the Meta while reserving bytes")
+	}
+
+	close(release)
+	r := <-reserved
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+
+	if r.size != 20 || atomic.LoadInt64(&c.pendingBytes) != 20 {
+		t.Fatalf("invalid reserved size, expected %d and received %d (pending %d)", 20, r.size, c.pendingBytes)
+	}
+
+	// The reserved file remains the next file to be taken
+	if next, ok := c.f.Shift(); !ok || next != filename {
+		t.Fatalf("invalid next filename, expected <%s> and received <%s>", filename, next)
+	}
+}
+
+// newTestConsumer will initialize a Consumer without a running watcher, so files can
+// be processed directly within the provided Dir
+func newTestConsumer(t *testing.T, opts Options, onUpdate UpdateFunc) *Consumer {
+	var (
+		c   Consumer
+		err error
+	)
+
+	if c.m, err = newMappedMeta(opts); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = c.m.Close() })
+
+	c.ctx = context.Background()
+	c.opts = opts
+	c.log = newLogger(opts.Logger, opts.FullName())
+	c.onUpdate = onUpdate
+	c.q = newQuarantine(opts, c.log)
+	return &c
+}
@@ -0,0 +1,52 @@
+package kiroku
+
+import (
+	"fmt"
+
+	"github.com/hatchify/errors"
+)
+
+const (
+	// ErrInvalidHeader is returned when a chunk header cannot be parsed
+	ErrInvalidHeader = errors.Error("invalid chunk header")
+	// ErrUnsupportedVersion is returned when a chunk was written with a newer format version
+	ErrUnsupportedVersion = errors.Error("unsupported chunk format version")
+	// ErrChecksumMismatch is returned when a block does not match it's checksum
+	ErrChecksumMismatch = errors.Error("block checksum mismatch")
+	// ErrTruncatedChunk is returned when a chunk ends before it's trailer
+	ErrTruncatedChunk = errors.Error("chunk is truncated")
+	// ErrInvalidTrailer is returned when a chunk trailer does not match the blocks read
+	ErrInvalidTrailer = errors.Error("invalid chunk trailer")
+	// ErrInvalidIndex is returned when a chunk block index does not match it's checksum or blocks
+	ErrInvalidIndex = errors.Error("invalid chunk block index")
+	// ErrInvalidBlockKind is returned when a block has an unknown kind
+	ErrInvalidBlockKind = errors.Error("invalid block kind")
+)
+
+func newCorruptionError(offset, blockIndex int64, err error) *CorruptionError {
+	var c CorruptionError
+	c.Offset = offset
+	c.BlockIndex = blockIndex
+	c.Err = err
+	return &c
+}
+
+// CorruptionError is returned when a chunk fails integrity verification
+type CorruptionError struct {
+	// Offset is the byte offset at which the corruption was encountered
+	Offset int64
+	// BlockIndex is the index of the block being read when the corruption was encountered
+	BlockIndex int64
+	// Err is the underlying corruption error (E.g. ErrChecksumMismatch)
+	Err error
+}
+
+// Error will return the error message
+func (c *CorruptionError) Error() string {
+	return fmt.Sprintf("chunk corrupted at offset %d (block %d): %v", c.Offset, c.BlockIndex, c.Err)
+}
+
+// Unwrap will return the underlying corruption error
+func (c *CorruptionError) Unwrap() error {
+	return c.Err
+}
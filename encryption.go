@@ -0,0 +1,402 @@
+package kiroku
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/hatchify/errors"
+)
+
+const (
+	// ErrEncryptedChunk is returned when an encrypted chunk is read without being decrypted
+	ErrEncryptedChunk = errors.Error("chunk is encrypted, a KeyProvider is required")
+	// ErrDecryptionFailed is returned when an encrypted chunk cannot be authenticated
+	ErrDecryptionFailed = errors.Error("error decrypting chunk, authentication failed")
+	// ErrUnencryptedChunk is returned when a chunk which is not encrypted is downloaded with a KeyProvider
+	ErrUnencryptedChunk = errors.Error("chunk is not encrypted, unencrypted chunks are not allowed")
+)
+
+const (
+	// dataKeySize is the size of the per-chunk data key (AES-256)
+	dataKeySize = 32
+	// noncePrefixSize is the size of the random nonce prefix for each chunk
+	noncePrefixSize = 7
+	// encryptionSegmentSize is the size of each plaintext segment
+	encryptionSegmentSize = 64 * 1024
+	// encryptionTagSize is the size of the GCM authentication tag appended to each segment
+	encryptionTagSize = 16
+)
+
+// envelope contains the encryption details which follow the header of an encrypted chunk
+//
+// Encrypted chunks are laid out as:
+//   - Header (with FlagEncrypted set)
+//   - Key ID (uint16 length prefixed)
+//   - Wrapped data key (uint16 length prefixed)
+//   - Nonce prefix
+//   - Segments of AES-GCM sealed plaintext
+//
+// Each segment is sealed with a nonce of the nonce prefix, the segment counter and
+// a final segment marker. The header and envelope bytes are used as additional data.
+type envelope struct {
+	keyID       string
+	wrappedKey  []byte
+	noncePrefix [noncePrefixSize]byte
+}
+
+func (e *envelope) bytes() (bs []byte) {
+	bs = newHeader(FlagEncrypted, CompressionNone).Bytes()
+	bs = binary.BigEndian.AppendUint16(bs, uint16(len(e.keyID)))
+	bs = append(bs, e.keyID...)
+	bs = binary.BigEndian.AppendUint16(bs, uint16(len(e.wrappedKey)))
+	bs = append(bs, e.wrappedKey...)
+	return append(bs, e.noncePrefix[:]...)
+}
+
+// parse will parse an envelope from the provided bytes (beginning after the header)
+// Note: ok will be false if there are not enough bytes to parse the envelope
+func (e *envelope) parse(bs []byte) (n int, ok bool) {
+	var keyID, wrappedKey []byte
+	if keyID, bs, ok = readUint16Prefixed(bs); !ok {
+		return
+	}
+
+	if wrappedKey, bs, ok = readUint16Prefixed(bs); !ok {
+		return
+	}
+
+	if len(bs) < noncePrefixSize {
+		return 0, false
+	}
+
+	e.keyID = string(keyID)
+	e.wrappedKey = append([]byte(nil), wrappedKey...)
+	copy(e.noncePrefix[:], bs)
+	n = 2 + len(keyID) + 2 + len(wrappedKey) + noncePrefixSize
+	return
+}
+
+func (e *envelope) readFrom(r io.Reader) (err error) {
+	var lengths [2]byte
+	var keyID, wrappedKey []byte
+	if keyID, err = readUint16PrefixedFrom(r, lengths[:]); err != nil {
+		return
+	}
+
+	if wrappedKey, err = readUint16PrefixedFrom(r, lengths[:]); err != nil {
+		return
+	}
+
+	if _, err = io.ReadFull(r, e.noncePrefix[:]); err != nil {
+		return
+	}
+
+	e.keyID = string(keyID)
+	e.wrappedKey = wrappedKey
+	return
+}
+
+func newEncrypter(ctx context.Context, kp KeyProvider, src io.Reader) (ep *encrypter, err error) {
+	var e encrypter
+	dataKey := make([]byte, dataKeySize)
+	if _, err = rand.Read(dataKey); err != nil {
+		return
+	}
+
+	var env envelope
+	if env.keyID, env.wrappedKey, err = kp.WrapKey(ctx, dataKey); err != nil {
+		err = fmt.Errorf("error wrapping data key: %v", err)
+		return
+	}
+
+	if len(env.keyID) > math.MaxUint16 || len(env.wrappedKey) > math.MaxUint16 {
+		err = fmt.Errorf("invalid key provider output, key ID and wrapped key must be less than %d bytes", math.MaxUint16)
+		return
+	}
+
+	if _, err = rand.Read(env.noncePrefix[:]); err != nil {
+		return
+	}
+
+	if e.aead, err = newAEAD(dataKey); err != nil {
+		return
+	}
+
+	e.src = bufio.NewReader(src)
+	e.noncePrefix = env.noncePrefix
+	e.aad = env.bytes()
+	e.plain = make([]byte, encryptionSegmentSize)
+	// Envelope is the first output of the encrypter
+	e.out = e.aad
+	ep = &e
+	return
+}
+
+// encrypter is an io.Reader which encrypts the contents of the source reader
+type encrypter struct {
+	src *bufio.Reader
+
+	aead        cipher.AEAD
+	noncePrefix [noncePrefixSize]byte
+	aad         []byte
+	counter     uint32
+
+	plain  []byte
+	sealed []byte
+	out    []byte
+
+	done bool
+}
+
+// Read will read encrypted bytes
+func (e *encrypter) Read(bs []byte) (n int, err error) {
+	for len(e.out) == 0 {
+		if e.done {
+			return 0, io.EOF
+		}
+
+		if err = e.seal(); err != nil {
+			return
+		}
+	}
+
+	n = copy(bs, e.out)
+	e.out = e.out[n:]
+	return
+}
+
+func (e *encrypter) seal() (err error) {
+	var n int
+	n, err = io.ReadFull(e.src, e.plain)
+	switch err {
+	case nil:
+	case io.EOF, io.ErrUnexpectedEOF:
+		err = nil
+	default:
+		return
+	}
+
+	last := n < len(e.plain)
+	if !last {
+		// Segment is full, peek to determine if this is the final segment
+		if _, err = e.src.Peek(1); err == io.EOF {
+			last = true
+		} else if err != nil {
+			return
+		}
+
+		err = nil
+	}
+
+	if e.counter == math.MaxUint32 {
+		return fmt.Errorf("error encrypting, maximum segment count of %d exceeded", uint32(math.MaxUint32))
+	}
+
+	e.sealed = e.aead.Seal(e.sealed[:0], segmentNonce(e.noncePrefix, e.counter, last), e.plain[:n], e.aad)
+	e.out = e.sealed
+	e.counter++
+	e.done = last
+	return
+}
+
+func newDecrypter(ctx context.Context, kp KeyProvider, dst io.Writer, allowUnencrypted bool) *decrypter {
+	var d decrypter
+	d.ctx = ctx
+	d.kp = kp
+	d.dst = dst
+	d.allowUnencrypted = allowUnencrypted
+	return &d
+}
+
+// decrypter is an io.WriteCloser which decrypts encrypted chunks written to it
+// Note: Chunks which are not encrypted are rejected with ErrUnencryptedChunk, unless
+// unencrypted chunks are allowed, in which case they are written to the destination as-is
+type decrypter struct {
+	ctx context.Context
+	kp  KeyProvider
+	dst io.Writer
+
+	// allowUnencrypted determines whether or not unencrypted chunks are passed through
+	allowUnencrypted bool
+
+	buf []byte
+
+	// passthrough is set when the written chunk is not encrypted
+	passthrough bool
+
+	aead        cipher.AEAD
+	noncePrefix [noncePrefixSize]byte
+	aad         []byte
+	counter     uint32
+
+	plain []byte
+}
+
+// Write will write encrypted bytes
+func (d *decrypter) Write(bs []byte) (n int, err error) {
+	if d.passthrough {
+		return d.dst.Write(bs)
+	}
+
+	d.buf = append(d.buf, bs...)
+	if d.aead == nil {
+		var ok bool
+		if ok, err = d.init(); err != nil || !ok {
+			return len(bs), err
+		}
+	}
+
+	if d.passthrough {
+		if _, err = d.dst.Write(d.buf); err != nil {
+			return
+		}
+
+		d.buf = nil
+		return len(bs), nil
+	}
+
+	// Always retain at least one sealed segment, as the final segment is only known once closed
+	sealedSize := encryptionSegmentSize + encryptionTagSize
+	var consumed int
+	for len(d.buf)-consumed > sealedSize {
+		if err = d.open(d.buf[consumed:consumed+sealedSize], false); err != nil {
+			return
+		}
+
+		consumed += sealedSize
+	}
+
+	d.buf = append(d.buf[:0], d.buf[consumed:]...)
+	return len(bs), nil
+}
+
+// Close will decrypt the final segment
+func (d *decrypter) Close() (err error) {
+	switch {
+	case d.passthrough:
+		return
+	case d.aead == nil && d.isEncrypted():
+		// Encrypted header was written without a complete envelope
+		return newCorruptionError(0, 0, ErrTruncatedChunk)
+	case d.aead == nil && !d.allowUnencrypted:
+		return ErrUnencryptedChunk
+	case d.aead == nil:
+		// Chunk is too small to be encrypted, write as-is
+		d.passthrough = true
+		_, err = d.dst.Write(d.buf)
+		return
+	case len(d.buf) < encryptionTagSize:
+		return newCorruptionError(0, 0, ErrTruncatedChunk)
+	default:
+		return d.open(d.buf, true)
+	}
+}
+
+// init will attempt to parse the header and envelope of the chunk
+func (d *decrypter) init() (ok bool, err error) {
+	switch {
+	case len(d.buf) < headerSize && isMagicPrefix(d.buf):
+		// Not enough bytes to determine if the chunk is encrypted
+		return false, nil
+	case !d.isEncrypted() && !d.allowUnencrypted:
+		// Reject the chunk, so the encryption of a chunk cannot be stripped
+		return false, ErrUnencryptedChunk
+	case !d.isEncrypted():
+		d.passthrough = true
+		return true, nil
+	}
+
+	var env envelope
+	var n int
+	if n, ok = env.parse(d.buf[headerSize:]); !ok {
+		return
+	}
+
+	var dataKey []byte
+	if dataKey, err = d.kp.UnwrapKey(d.ctx, env.keyID, env.wrappedKey); err != nil {
+		return
+	}
+
+	if d.aead, err = newAEAD(dataKey); err != nil {
+		return
+	}
+
+	d.noncePrefix = env.noncePrefix
+	d.aad = append([]byte(nil), d.buf[:headerSize+n]...)
+	d.buf = append(d.buf[:0], d.buf[headerSize+n:]...)
+	return true, nil
+}
+
+func (d *decrypter) isEncrypted() bool {
+	if len(d.buf) < headerSize {
+		return false
+	}
+
+	var h Header
+	if ok, err := h.readFrom(bytes.NewReader(d.buf)); !ok || err != nil {
+		return false
+	}
+
+	return h.Flags.Has(FlagEncrypted)
+}
+
+func (d *decrypter) open(sealed []byte, last bool) (err error) {
+	if d.plain, err = d.aead.Open(d.plain[:0], segmentNonce(d.noncePrefix, d.counter, last), sealed, d.aad); err != nil {
+		return ErrDecryptionFailed
+	}
+
+	d.counter++
+	_, err = d.dst.Write(d.plain)
+	return
+}
+
+func segmentNonce(prefix [noncePrefixSize]byte, counter uint32, last bool) []byte {
+	nonce := make([]byte, 0, 12)
+	nonce = append(nonce, prefix[:]...)
+	nonce = binary.BigEndian.AppendUint32(nonce, counter)
+	if last {
+		return append(nonce, 1)
+	}
+
+	return append(nonce, 0)
+}
+
+func readUint16Prefixed(bs []byte) (value, remaining []byte, ok bool) {
+	if len(bs) < 2 {
+		return
+	}
+
+	length := int(binary.BigEndian.Uint16(bs))
+	bs = bs[2:]
+	if len(bs) < length {
+		return
+	}
+
+	return bs[:length], bs[length:], true
+}
+
+func readUint16PrefixedFrom(r io.Reader, buf []byte) (value []byte, err error) {
+	if _, err = io.ReadFull(r, buf[:2]); err != nil {
+		return
+	}
+
+	value = make([]byte, binary.BigEndian.Uint16(buf))
+	_, err = io.ReadFull(r, value)
+	return
+}
+
+// isMagicPrefix will return whether or not the provided bytes could be the beginning of a versioned chunk
+func isMagicPrefix(bs []byte) bool {
+	if len(bs) > len(fileMagic) {
+		bs = bs[:len(fileMagic)]
+	}
+
+	return bytes.HasPrefix(fileMagic[:], bs)
+}
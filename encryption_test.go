@@ -0,0 +1,349 @@
+package kiroku
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync/atomic"
+	"testing"
+	"testing/iotest"
+)
+
+func TestEncryption_round_trip(t *testing.T) {
+	type testcase struct {
+		name string
+		size int
+	}
+
+	tests := []testcase{
+		{name: "empty", size: 0},
+		{name: "small", size: 32},
+		{name: "single segment", size: encryptionSegmentSize},
+		{name: "segment boundary", size: encryptionSegmentSize * 2},
+		{name: "multiple segments", size: encryptionSegmentSize*3 + 17},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, err := NewKeyring("key_0", newTestKey(0))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			value := newTestPlaintext(tt.size)
+			encrypted := encryptTestValue(t, k, value)
+			if tt.size > 0 && bytes.Contains(encrypted, value) {
+				t.Fatal("encrypted value contains plaintext")
+			}
+
+			var got []byte
+			if got, err = decryptTestValue(k, encrypted, 1000); err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(got, value) {
+				t.Fatalf("invalid decrypted value, expected %d bytes and received %d", len(value), len(got))
+			}
+		})
+	}
+}
+
+func TestEncryption_corruption(t *testing.T) {
+	type testcase struct {
+		name    string
+		modify  func(bs []byte) []byte
+		wantErr error
+	}
+
+	tests := []testcase{
+		{
+			name: "tampered segment",
+			modify: func(bs []byte) []byte {
+				bs[len(bs)-encryptionSegmentSize] ^= 0xff
+				return bs
+			},
+			wantErr: ErrDecryptionFailed,
+		},
+		{
+			name: "tampered envelope",
+			modify: func(bs []byte) []byte {
+				// Last byte of the nonce prefix
+				bs[headerSize+2+len("key_0")+2+60+noncePrefixSize-1] ^= 0xff
+				return bs
+			},
+			wantErr: ErrDecryptionFailed,
+		},
+		{
+			name: "truncated at segment boundary",
+			modify: func(bs []byte) []byte {
+				return bs[:len(bs)-(encryptionSegmentSize/2+encryptionTagSize)]
+			},
+			wantErr: ErrDecryptionFailed,
+		},
+		{
+			name: "stripped encryption flag",
+			modify: func(bs []byte) []byte {
+				bs[5] &^= byte(FlagEncrypted)
+				return bs
+			},
+			wantErr: ErrUnencryptedChunk,
+		},
+		{
+			name: "truncated envelope",
+			modify: func(bs []byte) []byte {
+				return bs[:headerSize+4]
+			},
+			wantErr: ErrTruncatedChunk,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, err := NewKeyring("key_0", newTestKey(0))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			encrypted := encryptTestValue(t, k, newTestPlaintext(encryptionSegmentSize*2+encryptionSegmentSize/2))
+			if _, err = decryptTestValue(k, tt.modify(encrypted), 4096); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("invalid error, expected <%v> and received <%v>", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestEncryption_passthrough(t *testing.T) {
+	type testcase struct {
+		name  string
+		value []byte
+	}
+
+	tests := []testcase{
+		{name: "chunk", value: newTestChunk([]byte("hello world"))},
+		{name: "legacy", value: []byte("hello world")},
+		{name: "magic only", value: fileMagic[:2]},
+		{name: "empty", value: []byte{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, err := NewKeyring("key_0", newTestKey(0))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []byte
+			if got, err = decryptTestValueWithOptions(k, tt.value, 1, true); err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(got, tt.value) {
+				t.Fatalf("invalid value, expected %x and received %x", tt.value, got)
+			}
+
+			// Unencrypted values are rejected unless they are allowed
+			if _, err = decryptTestValue(k, tt.value, 1); err != ErrUnencryptedChunk {
+				t.Fatalf("invalid error, expected <%v> and received <%v>", ErrUnencryptedChunk, err)
+			}
+		})
+	}
+}
+
+func TestEncryption_rotation(t *testing.T) {
+	k, err := NewKeyring("key_0", newTestKey(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value := newTestChunk([]byte("hello world"))
+	old := encryptTestValue(t, k, value)
+	if err = k.Rotate("key_1", newTestKey(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	current := encryptTestValue(t, k, value)
+	for _, encrypted := range [][]byte{old, current} {
+		var got []byte
+		if got, err = decryptTestValue(k, encrypted, 1000); err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(got, value) {
+			t.Fatal("invalid decrypted value")
+		}
+	}
+
+	// Chunks encrypted with the previous key cannot be read once the key is unavailable
+	var latest *Keyring
+	if latest, err = NewKeyring("key_1", newTestKey(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = decryptTestValue(latest, old, 1000); err != ErrUnknownKeyID {
+		t.Fatalf("invalid error, expected <%v> and received <%v>", ErrUnknownKeyID, err)
+	}
+}
+
+func TestReader_Header_encrypted(t *testing.T) {
+	k, err := NewKeyring("key_0", newTestKey(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encrypted := encryptTestValue(t, k, newTestChunk([]byte("hello world")))
+	r := NewReader(bytes.NewReader(encrypted))
+
+	var h Header
+	if h, err = r.Header(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !h.Flags.Has(FlagEncrypted) || h.KeyID != "key_0" {
+		t.Fatalf("invalid header, received %+v", h)
+	}
+
+	if err = r.ForEach(0, func(b Block) error { return nil }); err != ErrEncryptedChunk {
+		t.Fatalf("invalid error, expected <%v> and received <%v>", ErrEncryptedChunk, err)
+	}
+}
+
+func TestEncryption_producer_consumer(t *testing.T) {
+	var err error
+	if err = os.MkdirAll("./testing_source", 0744); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll("./testing_source")
+
+	var src Source
+	if src, err = NewIOSource("./testing_source"); err != nil {
+		t.Fatal(err)
+	}
+
+	var k *Keyring
+	if k, err = NewKeyring("key_0", newTestKey(0)); err != nil {
+		t.Fatal(err)
+	}
+
+	producerOpts := MakeOptions("./testing_producer", "test")
+	producerOpts.KeyProvider = k
+	if err = os.MkdirAll(producerOpts.Dir, 0744); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(producerOpts.Dir)
+
+	var p *Producer
+	if p, err = NewProducer(producerOpts, src); err != nil {
+		t.Fatal(err)
+	}
+
+	value := []byte("hello world")
+	if err = p.Transaction(func(txn *Transaction) (err error) {
+		return txn.Write(value)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var filename string
+	if filename, err = src.GetNext(context.Background(), producerOpts.FullName(), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// Ensure the exported chunk is encrypted
+	if err = src.Get(context.Background(), producerOpts.FullName(), filename, func(r io.Reader) (err error) {
+		var h Header
+		if _, err = h.readFrom(r); err != nil {
+			return
+		}
+
+		if !h.Flags.Has(FlagEncrypted) {
+			t.Errorf("expected exported chunk to be encrypted, received flags %08b", h.Flags)
+		}
+
+		return
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	consumerOpts := MakeOptions("./testing_consumer", "test")
+	consumerOpts.KeyProvider = k
+	if err = os.MkdirAll(consumerOpts.Dir, 0744); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(consumerOpts.Dir)
+
+	var count int64
+	if err = NewOneShotConsumer(consumerOpts, src, func(typ Type, r *Reader) (err error) {
+		return r.ForEach(0, func(b Block) (err error) {
+			if !bytes.Equal(b, value) {
+				t.Errorf("invalid block, expected <%s> and received <%s>", value, b)
+			}
+
+			atomic.AddInt64(&count, 1)
+			return
+		})
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 1 {
+		t.Fatalf("invalid count, expected 1 and received %d", count)
+	}
+}
+
+func encryptTestValue(t *testing.T, kp KeyProvider, value []byte) (encrypted []byte) {
+	e, err := newEncrypter(context.Background(), kp, iotest.HalfReader(bytes.NewReader(value)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if encrypted, err = io.ReadAll(e); err != nil {
+		t.Fatal(err)
+	}
+
+	return
+}
+
+// decryptTestValue will decrypt the provided value, writing it in parts of the provided size
+func decryptTestValue(kp KeyProvider, encrypted []byte, partSize int) (decrypted []byte, err error) {
+	return decryptTestValueWithOptions(kp, encrypted, partSize, false)
+}
+
+// decryptTestValueWithOptions will decrypt the provided value, optionally allowing unencrypted values
+func decryptTestValueWithOptions(kp KeyProvider, encrypted []byte, partSize int, allowUnencrypted bool) (decrypted []byte, err error) {
+	var buf bytes.Buffer
+	d := newDecrypter(context.Background(), kp, &buf, allowUnencrypted)
+	for len(encrypted) > 0 {
+		n := partSize
+		if n > len(encrypted) {
+			n = len(encrypted)
+		}
+
+		if _, err = d.Write(encrypted[:n]); err != nil {
+			return
+		}
+
+		encrypted = encrypted[n:]
+	}
+
+	if err = d.Close(); err != nil {
+		return
+	}
+
+	decrypted = buf.Bytes()
+	return
+}
+
+func newTestPlaintext(size int) (bs []byte) {
+	bs = make([]byte, size)
+	for i := range bs {
+		bs[i] = byte(i % 251)
+	}
+
+	return
+}
@@ -0,0 +1,92 @@
+package kiroku
+
+import (
+	"sort"
+	"sync"
+)
+
+func newFileIndex() *fileIndex {
+	var f fileIndex
+	f.files = map[string]indexedFile{}
+	return &f
+}
+
+// fileIndex is a sorted set of local files, files are sorted by filename so
+// they are provided in the same order as a directory listing
+type fileIndex struct {
+	mux sync.Mutex
+
+	// Sorted filenames
+	keys []string
+	// Files by filename
+	files map[string]indexedFile
+	// Total size of the files within the index
+	size int64
+}
+
+type indexedFile struct {
+	filename Filename
+	size     int64
+}
+
+// Add will add a file (and it's size) to the index, false is returned if the file already exists
+func (f *fileIndex) Add(filename Filename, size int64) (ok bool) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	key := filename.String()
+	if _, ok = f.files[key]; ok {
+		return false
+	}
+
+	f.files[key] = indexedFile{filename: filename, size: size}
+	f.size += size
+	// Insert the key at it's sorted position
+	index := sort.SearchStrings(f.keys, key)
+	f.keys = append(f.keys, "")
+	copy(f.keys[index+1:], f.keys[index:])
+	f.keys[index] = key
+	return true
+}
+
+// Remove will remove a file from the index
+func (f *fileIndex) Remove(filename Filename) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	key := filename.String()
+	file, ok := f.files[key]
+	if !ok {
+		return
+	}
+
+	delete(f.files, key)
+	f.size -= file.size
+	index := sort.SearchStrings(f.keys, key)
+	f.keys = append(f.keys[:index], f.keys[index+1:]...)
+}
+
+// First will return the first file within the index
+func (f *fileIndex) First() (filename Filename, ok bool) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if len(f.keys) == 0 {
+		return
+	}
+
+	filename = f.files[f.keys[0]].filename
+	ok = true
+	return
+}
+
+// Len will return the number of files within the index
+func (f *fileIndex) Len() int {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	return len(f.keys)
+}
+
+// Size will return the total size of the files within the index
+func (f *fileIndex) Size() int64 {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	return f.size
+}
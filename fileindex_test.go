@@ -0,0 +1,102 @@
+package kiroku
+
+import "testing"
+
+func Test_fileIndex(t *testing.T) {
+	type testcase struct {
+		name    string
+		add     []Filename
+		remove  []Filename
+		want    Filename
+		wantOk  bool
+		wantLen int
+		// Each file is added with a size of 10 bytes
+		wantSize int64
+	}
+
+	tests := []testcase{
+		{
+			name: "empty",
+		},
+		{
+			name: "sorted",
+			add: []Filename{
+				makeFilename("test", 3, TypeChunk),
+				makeFilename("test", 1, TypeChunk),
+				makeFilename("test", 2, TypeChunk),
+			},
+			want:     makeFilename("test", 1, TypeChunk),
+			wantOk:   true,
+			wantLen:  3,
+			wantSize: 30,
+		},
+		{
+			name: "duplicate",
+			add: []Filename{
+				makeFilename("test", 1, TypeChunk),
+				makeFilename("test", 1, TypeChunk),
+			},
+			want:     makeFilename("test", 1, TypeChunk),
+			wantOk:   true,
+			wantLen:  1,
+			wantSize: 10,
+		},
+		{
+			name: "removed",
+			add: []Filename{
+				makeFilename("test", 1, TypeChunk),
+				makeFilename("test", 2, TypeChunk),
+			},
+			remove: []Filename{
+				makeFilename("test", 1, TypeChunk),
+				// Removing a file which does not exist is a no-op
+				makeFilename("test", 5, TypeChunk),
+			},
+			want:     makeFilename("test", 2, TypeChunk),
+			wantOk:   true,
+			wantLen:  1,
+			wantSize: 10,
+		},
+		{
+			name: "sequenced",
+			add: []Filename{
+				makeSequencedFilename("test", 1, 1, 10, TypeChunk),
+				makeSequencedFilename("test", 1, 1, 9, TypeChunk),
+			},
+			want:     makeSequencedFilename("test", 1, 1, 9, TypeChunk),
+			wantOk:   true,
+			wantLen:  2,
+			wantSize: 20,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idx := newFileIndex()
+			for _, f := range tt.add {
+				idx.Add(f, 10)
+			}
+
+			for _, f := range tt.remove {
+				idx.Remove(f)
+			}
+
+			got, ok := idx.First()
+			if ok != tt.wantOk {
+				t.Fatalf("invalid ok, expected %v and received %v", tt.wantOk, ok)
+			}
+
+			if got != tt.want {
+				t.Fatalf("invalid filename, expected <%v> and received <%v>", tt.want, got)
+			}
+
+			if n := idx.Len(); n != tt.wantLen {
+				t.Fatalf("invalid length, expected %d and received %d", tt.wantLen, n)
+			}
+
+			if size := idx.Size(); size != tt.wantSize {
+				t.Fatalf("invalid size, expected %d and received %d", tt.wantSize, size)
+			}
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package kiroku
 
 import (
+	"crypto/rand"
+	"encoding/binary"
 	"fmt"
 	"strconv"
 	"strings"
@@ -13,10 +15,34 @@ func makeFilename(name string, createdAt int64, filetype Type) (f Filename) {
 	return
 }
 
+func makeSequencedFilename(name string, createdAt int64, id ProducerID, sequence uint64, filetype Type) (f Filename) {
+	f = makeFilename(name, createdAt, filetype)
+	f.ProducerID = id
+	f.Sequence = sequence
+	return
+}
+
+// ParseFilename will parse a chunk or snapshot filename
+// Note: Both sequenced (name.createdAt.producerID.sequence.type.kir) and legacy
+// (name.createdAt.type.kir) filenames are supported
 func ParseFilename(filename string) (parsed Filename, err error) {
 	spl := strings.Split(filename, ".")
-	if len(spl) != 4 {
-		err = fmt.Errorf("invalid number of filename parts, expected 4 and received %d", len(spl))
+	switch len(spl) {
+	case 4:
+	case 6:
+		if parsed.ProducerID, err = parseProducerID(spl[2]); err != nil {
+			return
+		}
+
+		if parsed.Sequence, err = parseSequence(spl[3]); err != nil {
+			return
+		}
+
+		// Remove the producer ID and sequence so the remaining parts match the legacy layout
+		spl = append(spl[:2], spl[4:]...)
+
+	default:
+		err = fmt.Errorf("invalid number of filename parts, expected 4 or 6 and received %d", len(spl))
 		return
 	}
 
@@ -41,14 +67,76 @@ type Filename struct {
 	Name      string
 	CreatedAt int64
 	Filetype  Type
+
+	// ProducerID is the ID of the Producer which wrote the file
+	// Note: This is empty for legacy filenames
+	ProducerID ProducerID
+	// Sequence is the per-Producer sequence number of the file
+	// Note: This is zero for legacy filenames
+	Sequence uint64
 }
 
 func (f Filename) String() string {
-	return fmt.Sprintf("%s.%d.%s.kir", f.Name, f.CreatedAt, f.Filetype)
+	if f.ProducerID == 0 {
+		return fmt.Sprintf("%s.%d.%s.kir", f.Name, f.CreatedAt, f.Filetype)
+	}
+
+	return fmt.Sprintf("%s.%d.%s.%016x.%s.kir", f.Name, f.CreatedAt, f.ProducerID, f.Sequence, f.Filetype)
 }
 
 func (f Filename) toMeta() (m Meta) {
-	m.LastProcessedTimestamp = f.CreatedAt
-	m.LastProcessedType = f.Filetype
+	m.setProcessed(f)
 	return
 }
+
+func newProducerID() (id ProducerID, err error) {
+	var bs [8]byte
+	for id == 0 {
+		if _, err = rand.Read(bs[:]); err != nil {
+			err = fmt.Errorf("error generating producer ID: %v", err)
+			return
+		}
+
+		id = ProducerID(binary.BigEndian.Uint64(bs[:]))
+	}
+
+	return
+}
+
+func parseProducerID(str string) (id ProducerID, err error) {
+	var n uint64
+	if n, err = parseHex(str); err != nil {
+		err = fmt.Errorf("invalid producer ID <%s>: %v", str, err)
+		return
+	}
+
+	id = ProducerID(n)
+	return
+}
+
+func parseSequence(str string) (sequence uint64, err error) {
+	if sequence, err = parseHex(str); err != nil {
+		err = fmt.Errorf("invalid sequence <%s>: %v", str, err)
+		return
+	}
+
+	return
+}
+
+func parseHex(str string) (n uint64, err error) {
+	// Values are fixed width so filenames sort in sequence order
+	if len(str) != 16 {
+		err = fmt.Errorf("expected 16 characters and received %d", len(str))
+		return
+	}
+
+	return strconv.ParseUint(str, 16, 64)
+}
+
+// ProducerID identifies the Producer which wrote a chunk or snapshot
+type ProducerID uint64
+
+// String will return the fixed width hex representation of the ID
+func (p ProducerID) String() string {
+	return fmt.Sprintf("%016x", uint64(p))
+}
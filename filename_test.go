@@ -30,6 +30,38 @@ func Test_ParseFilename(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "sequenced",
+			args: args{
+				filename: "test.12345.00000000000000ff.0000000000000002.chunk.kir",
+			},
+			wantParsed: Filename{
+				Name:       "test",
+				CreatedAt:  12345,
+				Filetype:   TypeChunk,
+				ProducerID: 255,
+				Sequence:   2,
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid producer ID",
+			args: args{
+				filename: "test.12345.ff.0000000000000002.chunk.kir",
+			},
+			wantParsed: Filename{},
+			wantErr:    true,
+		},
+		{
+			name: "invalid sequence",
+			args: args{
+				filename: "test.12345.00000000000000ff.foo.chunk.kir",
+			},
+			wantParsed: Filename{
+				ProducerID: 255,
+			},
+			wantErr: true,
+		},
 		{
 			name: "not enough parts",
 			args: args{
@@ -88,9 +120,11 @@ func Test_ParseFilename(t *testing.T) {
 
 func TestFilename_String(t *testing.T) {
 	type fields struct {
-		Name      string
-		CreatedAt int64
-		Filetype  Type
+		Name       string
+		CreatedAt  int64
+		Filetype   Type
+		ProducerID ProducerID
+		Sequence   uint64
 	}
 
 	type testcase struct {
@@ -109,11 +143,22 @@ func TestFilename_String(t *testing.T) {
 			},
 			want: "test.12345.snapshot.kir",
 		},
+		{
+			name: "sequenced",
+			fields: fields{
+				Name:       "test",
+				CreatedAt:  12345,
+				Filetype:   TypeChunk,
+				ProducerID: 255,
+				Sequence:   2,
+			},
+			want: "test.12345.00000000000000ff.0000000000000002.chunk.kir",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			f := makeFilename(tt.fields.Name, tt.fields.CreatedAt, tt.fields.Filetype)
+			f := makeSequencedFilename(tt.fields.Name, tt.fields.CreatedAt, tt.fields.ProducerID, tt.fields.Sequence, tt.fields.Filetype)
 			if got := f.String(); got != tt.want {
 				t.Errorf("Filename.String() = %v, want %v", got, tt.want)
 			}
@@ -155,3 +200,12 @@ func TestFilename_toMeta(t *testing.T) {
 		})
 	}
 }
+
+func TestFilename_sort(t *testing.T) {
+	// Filenames created within the same nanosecond must sort by sequence
+	first := makeSequencedFilename("test", 12345, 255, 9, TypeChunk)
+	second := makeSequencedFilename("test", 12345, 255, 10, TypeChunk)
+	if first.String() >= second.String() {
+		t.Fatalf("invalid sort order, expected <%s> to sort before <%s>", first, second)
+	}
+}
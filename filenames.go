@@ -26,3 +26,9 @@ func (f *filenames) Append(filenames []string) {
 	defer f.mux.Unlock()
 	f.s = append(f.s, filenames...)
 }
+
+func (f *filenames) Unshift(filename string) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.s = append([]string{filename}, f.s...)
+}
@@ -0,0 +1,204 @@
+package kiroku
+
+import (
+	"bufio"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+)
+
+func appendFrame(bs []byte, value []byte, flags HeaderFlag) []byte {
+	bs = binary.AppendUvarint(bs, uint64(len(value)))
+	bs = append(bs, value...)
+	if !flags.Has(FlagBlockChecksums) {
+		return bs
+	}
+
+	return binary.BigEndian.AppendUint32(bs, crc32.Checksum(value, castagnoli))
+}
+
+// appendTrailer will append the end of blocks marker, the block index (when FlagBlockIndex is set) and the trailer
+// Note: The block index is a sequence of big-endian block offsets followed by a CRC32C of the offsets
+func appendTrailer(bs []byte, index []byte, blockCount int64, flags HeaderFlag) []byte {
+	// Blocks cannot be empty, so a zero length frame marks the end of the blocks
+	bs = binary.AppendUvarint(bs, 0)
+	if flags.Has(FlagBlockIndex) {
+		bs = append(bs, index...)
+		bs = binary.BigEndian.AppendUint32(bs, crc32.Checksum(index, castagnoli))
+	}
+
+	start := len(bs)
+	bs = binary.BigEndian.AppendUint64(bs, uint64(blockCount))
+	return binary.BigEndian.AppendUint32(bs, crc32.Checksum(bs[start:], castagnoli))
+}
+
+func newFrameReader(r io.Reader, h Header, pos Position, size int64) *frameReader {
+	var f frameReader
+	f.r = bufio.NewReader(r)
+	f.flags = h.Flags
+	f.compression = h.Compression
+	f.offset = pos.Offset
+	f.index = pos.Index
+	f.size = size
+	return &f
+}
+
+// frameReader reads the block frames of a versioned chunk
+type frameReader struct {
+	r *bufio.Reader
+
+	flags       HeaderFlag
+	compression Compression
+
+	// Offset of the next frame
+	offset int64
+	// Index of the next block
+	index int64
+	// Total size of the chunk
+	size int64
+}
+
+// position will return the position of the next block
+func (f *frameReader) position() Position {
+	return Position{Offset: f.offset, Index: f.index}
+}
+
+// next will return the next block within the chunk
+// Note: io.EOF is returned once the trailer has been reached and verified
+func (f *frameReader) next() (kind blockKind, b Block, err error) {
+	var length uint64
+	if length, err = binary.ReadUvarint(f.r); err != nil {
+		return 0, nil, f.corruption(handleFrameError(err))
+	}
+
+	if length == 0 {
+		return 0, nil, f.readIndexAndTrailer()
+	}
+
+	lengthSize := int64(uvarintSize(length))
+	frameSize := lengthSize + int64(length)
+	if f.flags.Has(FlagBlockChecksums) {
+		frameSize += 4
+	}
+
+	if f.offset+frameSize > f.size {
+		// Frame length exceeds the remaining bytes, avoid allocating an invalid length
+		return 0, nil, f.corruption(ErrTruncatedChunk)
+	}
+
+	b = make(Block, length)
+	if _, err = io.ReadFull(f.r, b); err != nil {
+		return 0, nil, f.corruption(handleFrameError(err))
+	}
+
+	if f.flags.Has(FlagBlockChecksums) {
+		var checksum [4]byte
+		if _, err = io.ReadFull(f.r, checksum[:]); err != nil {
+			return 0, nil, f.corruption(handleFrameError(err))
+		}
+
+		if binary.BigEndian.Uint32(checksum[:]) != crc32.Checksum(b, castagnoli) {
+			return 0, nil, f.corruption(ErrChecksumMismatch)
+		}
+	}
+
+	if f.flags.Has(FlagCompressed) {
+		if b, err = f.compression.decompress(b, maxBlockSize); err != nil {
+			// Note: A block which cannot be decompressed will not succeed when retried
+			return 0, nil, f.corruption(err)
+		}
+	}
+
+	if f.flags.Has(FlagBlockKinds) {
+		// Separate the block kind from the block
+		if len(b) < 2 {
+			return 0, nil, f.corruption(ErrInvalidBlockKind)
+		}
+
+		if kind = blockKind(b[0]); kind.Validate() != nil {
+			return 0, nil, f.corruption(ErrInvalidBlockKind)
+		}
+
+		b = b[1:]
+	}
+
+	f.offset += frameSize
+	f.index++
+	return
+}
+
+func (f *frameReader) readIndexAndTrailer() (err error) {
+	// Move past the end of blocks marker
+	f.offset++
+	if !f.flags.Has(FlagBlockIndex) {
+		return f.readTrailer()
+	}
+
+	indexSize := f.index * indexEntrySize
+	if f.offset+indexSize+4+trailerSize > f.size {
+		return f.corruption(ErrTruncatedChunk)
+	}
+
+	hash := crc32.New(castagnoli)
+	if _, err = io.CopyN(hash, f.r, indexSize); err != nil {
+		return f.corruption(handleFrameError(err))
+	}
+
+	var checksum [4]byte
+	if _, err = io.ReadFull(f.r, checksum[:]); err != nil {
+		return f.corruption(handleFrameError(err))
+	}
+
+	if binary.BigEndian.Uint32(checksum[:]) != hash.Sum32() {
+		return f.corruption(ErrInvalidIndex)
+	}
+
+	f.offset += indexSize + 4
+	return f.readTrailer()
+}
+
+func (f *frameReader) readTrailer() (err error) {
+	var trailer [trailerSize]byte
+	if _, err = io.ReadFull(f.r, trailer[:]); err != nil {
+		return f.corruption(handleFrameError(err))
+	}
+
+	checksum := binary.BigEndian.Uint32(trailer[8:])
+	if checksum != crc32.Checksum(trailer[:8], castagnoli) {
+		return f.corruption(ErrInvalidTrailer)
+	}
+
+	if int64(binary.BigEndian.Uint64(trailer[:8])) != f.index {
+		return f.corruption(ErrInvalidTrailer)
+	}
+
+	if f.offset+trailerSize != f.size {
+		// Trailer must end the chunk, bytes after it (E.g. blocks appended to a finished chunk) are not read
+		return f.corruption(ErrInvalidTrailer)
+	}
+
+	return io.EOF
+}
+
+func (f *frameReader) corruption(err error) *CorruptionError {
+	return newCorruptionError(f.offset, f.index, err)
+}
+
+func handleFrameError(err error) error {
+	switch err {
+	case io.EOF, io.ErrUnexpectedEOF:
+		return ErrTruncatedChunk
+	default:
+		return err
+	}
+}
+
+func uvarintSize(v uint64) (n int) {
+	n = 1
+	for v >= 0x80 {
+		v >>= 7
+		n++
+	}
+
+	return
+}
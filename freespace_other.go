@@ -0,0 +1,8 @@
+//go:build !unix
+
+package kiroku
+
+// getFreeSpace is unsupported on this platform
+func getFreeSpace(dir string) (n int64, err error) {
+	return 0, ErrFreeSpaceUnsupported
+}
@@ -0,0 +1,20 @@
+//go:build unix
+
+package kiroku
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// getFreeSpace will return the number of bytes available to unprivileged users within a directory
+func getFreeSpace(dir string) (n int64, err error) {
+	var stat syscall.Statfs_t
+	if err = syscall.Statfs(dir, &stat); err != nil {
+		err = fmt.Errorf("error getting free space for <%s>: %v", dir, err)
+		return
+	}
+
+	n = int64(stat.Bavail) * int64(stat.Bsize)
+	return
+}
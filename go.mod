@@ -5,6 +5,7 @@ go 1.20
 require (
 	github.com/edsrzf/mmap-go v1.1.0
 	github.com/hatchify/errors v0.4.82
+	github.com/klauspost/compress v1.17.4
 	github.com/mojura/enkodo v0.5.7
 )
 
@@ -0,0 +1,167 @@
+package kiroku
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+)
+
+const (
+	// FormatVersion is the current chunk format version
+	FormatVersion uint8 = 1
+	// headerSize is the size (in bytes) of an encoded Header
+	headerSize = 8
+	// fencingTokenSize is the size (in bytes) of the fencing token following a Header with FlagFencingToken
+	fencingTokenSize = 8
+	// trailerSize is the size (in bytes) of an encoded trailer, excluding the end of blocks marker
+	trailerSize = 12
+	// indexEntrySize is the size (in bytes) of a block index entry
+	indexEntrySize = 8
+)
+
+const (
+	// FlagBlockChecksums denotes that each block is followed by a CRC32C checksum
+	FlagBlockChecksums HeaderFlag = 1 << iota
+	// FlagCompressed denotes that each block is compressed with the header Compression
+	FlagCompressed
+	// FlagEncrypted denotes that the chunk contents following the header are encrypted
+	FlagEncrypted
+	// FlagBlockIndex denotes that the trailer is preceded by an index of block offsets
+	FlagBlockIndex
+	// FlagBlockKinds denotes that each block is prefixed with a byte identifying the kind
+	// of block (raw or BlockEnvelope)
+	FlagBlockKinds
+	// FlagFencingToken denotes that the header is followed by the fencing token of the
+	// Producer lease the chunk was written under
+	FlagFencingToken
+)
+
+// knownFlags represents all of the flags supported by the current format version
+const knownFlags = FlagBlockChecksums | FlagCompressed | FlagEncrypted | FlagBlockIndex | FlagBlockKinds | FlagFencingToken
+
+// fileMagic is the leading sequence of every versioned chunk
+// Note: The leading byte has the high bit set so it's unlikely to collide with
+// the varint length prefix of a small legacy block
+var fileMagic = [4]byte{0x89, 'K', 'I', 'R'}
+
+// castagnoli is the CRC32C table used for block checksums
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+func newHeader(flags HeaderFlag, c Compression) (h Header) {
+	h.Version = FormatVersion
+	h.Flags = flags
+	if c != CompressionNone {
+		h.Flags |= FlagCompressed
+	}
+
+	h.Compression = c
+	return
+}
+
+// Header represents the leading bytes of a chunk file
+type Header struct {
+	// Version of the chunk format, legacy (headerless) chunks will have a version of 0
+	Version uint8
+	// Flags set for the chunk
+	Flags HeaderFlag
+	// Compression used for the blocks of the chunk
+	Compression Compression
+	// Codec used to encode the blocks of the chunk, CodecNone for raw blocks
+	Codec CodecID
+	// KeyID of the key used to wrap the data key of an encrypted chunk
+	// Note: KeyID is not part of the encoded header, it's read from the encryption envelope
+	KeyID string
+	// FencingToken is the token of the Producer lease the chunk was written under, this is
+	// only set when FlagFencingToken is set
+	FencingToken uint64
+}
+
+// IsLegacy will return whether or not the header represents a legacy headerless chunk
+func (h Header) IsLegacy() bool {
+	return h.Version == 0
+}
+
+// Validate ensures that the Header is supported by this version of Kiroku
+func (h Header) Validate() (err error) {
+	switch {
+	case h.Version == 0:
+		return ErrInvalidHeader
+	case h.Version > FormatVersion:
+		return ErrUnsupportedVersion
+	case h.Flags&^knownFlags != 0:
+		return ErrInvalidHeader
+	case h.Flags.Has(FlagCompressed) != (h.Compression != CompressionNone):
+		return ErrInvalidHeader
+	case h.Compression.Validate() != nil:
+		return ErrInvalidHeader
+	default:
+		return
+	}
+}
+
+// Bytes will return the encoded representation of the header
+func (h Header) Bytes() (bs []byte) {
+	bs = make([]byte, h.size())
+	copy(bs, fileMagic[:])
+	bs[4] = h.Version
+	bs[5] = uint8(h.Flags)
+	bs[6] = uint8(h.Compression)
+	bs[7] = uint8(h.Codec)
+	if h.Flags.Has(FlagFencingToken) {
+		binary.BigEndian.PutUint64(bs[headerSize:], h.FencingToken)
+	}
+
+	return
+}
+
+// size will return the size (in bytes) of the encoded header
+func (h Header) size() int64 {
+	if h.Flags.Has(FlagFencingToken) {
+		return headerSize + fencingTokenSize
+	}
+
+	return headerSize
+}
+
+func (h *Header) readFrom(r io.Reader) (ok bool, err error) {
+	bs := make([]byte, headerSize)
+	var n int
+	n, err = io.ReadFull(r, bs)
+	switch {
+	case n < len(fileMagic) || !bytes.Equal(bs[:len(fileMagic)], fileMagic[:]):
+		// Magic is not present, this is a legacy chunk
+		return false, nil
+	case err != nil:
+		// Magic is present, but the header is incomplete
+		return false, ErrTruncatedChunk
+	}
+
+	h.Version = bs[4]
+	h.Flags = HeaderFlag(bs[5])
+	h.Compression = Compression(bs[6])
+	h.Codec = CodecID(bs[7])
+	if err = h.Validate(); err != nil {
+		return true, err
+	}
+
+	if !h.Flags.Has(FlagFencingToken) {
+		return true, nil
+	}
+
+	var token [fencingTokenSize]byte
+	if _, err = io.ReadFull(r, token[:]); err != nil {
+		return true, ErrTruncatedChunk
+	}
+
+	h.FencingToken = binary.BigEndian.Uint64(token[:])
+	return true, nil
+}
+
+// HeaderFlag represents a chunk feature flag
+type HeaderFlag uint8
+
+// Has will return whether or not the provided flag is set
+func (f HeaderFlag) Has(flag HeaderFlag) bool {
+	return f&flag == flag
+}
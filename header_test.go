@@ -0,0 +1,139 @@
+package kiroku
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func TestHeader_Validate(t *testing.T) {
+	type testcase struct {
+		name    string
+		h       Header
+		wantErr error
+	}
+
+	tests := []testcase{
+		{
+			name: "basic",
+			h:    newHeader(FlagBlockChecksums, CompressionNone),
+		},
+		{
+			name:    "missing version",
+			h:       Header{Flags: FlagBlockChecksums},
+			wantErr: ErrInvalidHeader,
+		},
+		{
+			name:    "unsupported version",
+			h:       Header{Version: FormatVersion + 1},
+			wantErr: ErrUnsupportedVersion,
+		},
+		{
+			name: "compressed",
+			h:    newHeader(FlagBlockChecksums, CompressionZstd),
+		},
+		{
+			name:    "compression without flag",
+			h:       Header{Version: FormatVersion, Compression: CompressionZstd},
+			wantErr: ErrInvalidHeader,
+		},
+		{
+			name:    "unknown compression",
+			h:       Header{Version: FormatVersion, Flags: FlagCompressed, Compression: 99},
+			wantErr: ErrInvalidHeader,
+		},
+		{
+			name:    "unknown flags",
+			h:       Header{Version: FormatVersion, Flags: 1 << 7},
+			wantErr: ErrInvalidHeader,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.h.Validate(); err != tt.wantErr {
+				t.Errorf("Header.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHeader_readFrom(t *testing.T) {
+	type testcase struct {
+		name    string
+		bs      []byte
+		want    Header
+		wantOK  bool
+		wantErr error
+	}
+
+	tests := []testcase{
+		{
+			name:   "basic",
+			bs:     newHeader(FlagBlockChecksums, CompressionNone).Bytes(),
+			want:   newHeader(FlagBlockChecksums, CompressionNone),
+			wantOK: true,
+		},
+		{
+			name: "legacy",
+			bs:   []byte("hello world"),
+		},
+		{
+			name: "empty",
+			bs:   []byte{},
+		},
+		{
+			name:    "truncated",
+			bs:      newHeader(FlagBlockChecksums, CompressionNone).Bytes()[:6],
+			wantErr: ErrTruncatedChunk,
+		},
+		{
+			name:   "fencing token",
+			bs:     Header{Version: FormatVersion, Flags: FlagBlockChecksums | FlagFencingToken, FencingToken: 7}.Bytes(),
+			want:   Header{Version: FormatVersion, Flags: FlagBlockChecksums | FlagFencingToken, FencingToken: 7},
+			wantOK: true,
+		},
+		{
+			name:    "truncated fencing token",
+			bs:      Header{Version: FormatVersion, Flags: FlagFencingToken, FencingToken: 7}.Bytes()[:12],
+			wantOK:  true,
+			wantErr: ErrTruncatedChunk,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var h Header
+			ok, err := h.readFrom(bytes.NewReader(tt.bs))
+			if err != tt.wantErr {
+				t.Fatalf("Header.readFrom() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if ok != tt.wantOK {
+				t.Fatalf("Header.readFrom() ok = %v, want %v", ok, tt.wantOK)
+			}
+
+			if ok && err == nil && !reflect.DeepEqual(h, tt.want) {
+				t.Fatalf("Header.readFrom() = %+v, want %+v", h, tt.want)
+			}
+		})
+	}
+}
+
+func newTestChunk(blocks ...Block) (bs []byte) {
+	return newTestChunkWithFlags(FlagBlockChecksums, blocks...)
+}
+
+func newTestChunkWithFlags(flags HeaderFlag, blocks ...Block) (bs []byte) {
+	h := newHeader(flags, CompressionNone)
+	bs = h.Bytes()
+
+	var index []byte
+	for _, b := range blocks {
+		index = binary.BigEndian.AppendUint64(index, uint64(len(bs)))
+		bs = appendFrame(bs, b, h.Flags)
+	}
+
+	return appendTrailer(bs, index, int64(len(blocks)), h.Flags)
+}
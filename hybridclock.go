@@ -0,0 +1,71 @@
+package kiroku
+
+import (
+	"sync"
+	"time"
+)
+
+// clockSkewThreshold is the amount of time the wall clock must regress before the skew is logged
+const clockSkewThreshold = time.Millisecond
+
+func newHybridClock(m *mappedMeta, c clock, l logger) *hybridClock {
+	var h hybridClock
+	h.m = m
+	h.c = c
+	h.log = l
+	return &h
+}
+
+// hybridClock is a hybrid logical clock which issues strictly increasing
+// timestamps. Timestamps follow the wall clock, when the wall clock regresses
+// (E.g. after an NTP correction) timestamps are logically incremented from the
+// last issued timestamp until the wall clock catches up. The last issued
+// timestamp is stored within the Meta so timestamps increase across restarts.
+type hybridClock struct {
+	mux sync.Mutex
+
+	m   *mappedMeta
+	c   clock
+	log logger
+
+	// skewed is true while the wall clock is behind the last issued timestamp
+	skewed bool
+}
+
+// Next will return the next timestamp as Unix nanoseconds
+func (h *hybridClock) Next() (ts int64, err error) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	var last int64
+	now := h.c.Now().UnixNano()
+	if err = h.m.Update(func(m Meta) (out Meta, err error) {
+		last = m.LastCreatedAt
+		ts = now
+		if ts <= last {
+			// Wall clock has not passed the last issued timestamp, increment logically
+			ts = last + 1
+		}
+
+		m.LastCreatedAt = ts
+		out = m
+		return
+	}); err != nil {
+		return
+	}
+
+	h.checkSkew(time.Duration(last - now))
+	return
+}
+
+// checkSkew will log when the wall clock regresses and when it recovers
+func (h *hybridClock) checkSkew(skew time.Duration) {
+	switch {
+	case skew >= clockSkewThreshold && !h.skewed:
+		h.skewed = true
+		h.log.warn("clock skew detected, wall clock is behind the last chunk timestamp", "skew", skew)
+	case skew < 0 && h.skewed:
+		h.skewed = false
+		h.log.info("clock skew resolved, wall clock has passed the last chunk timestamp")
+	}
+}
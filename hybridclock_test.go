@@ -0,0 +1,66 @@
+package kiroku
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_hybridClock_Next(t *testing.T) {
+	opts := MakeOptions("./testing", "test")
+	if err := os.Mkdir(opts.Dir, 0744); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(opts.Dir)
+
+	var logs []string
+	onLog := func(msg string) { logs = append(logs, msg) }
+	l := newLogger(newCallbackLogger(onLog, func(error) {}, false), opts.FullName())
+
+	start := time.Unix(0, 1000000000)
+	next := func(h *hybridClock, want int64) {
+		ts, err := h.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ts != want {
+			t.Fatalf("invalid timestamp, expected %d and received %d", want, ts)
+		}
+	}
+
+	m, err := newMappedMeta(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Timestamps within the same nanosecond are incremented logically
+	h := newHybridClock(m, newFakeClock(start), l)
+	next(h, start.UnixNano())
+	next(h, start.UnixNano()+1)
+	if err = m.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Restart with a wall clock which has regressed by a second
+	if m, err = newMappedMeta(opts); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	c := newFakeClock(start.Add(-time.Second))
+	h = newHybridClock(m, c, l)
+	next(h, start.UnixNano()+2)
+	next(h, start.UnixNano()+3)
+	if len(logs) != 1 || !strings.Contains(logs[0], "clock skew detected") {
+		t.Fatalf("invalid logs, expected a clock skew entry and received %v", logs)
+	}
+
+	// Wall clock catches up
+	c.Advance(time.Second * 2)
+	next(h, start.Add(time.Second).UnixNano())
+	if len(logs) != 2 || !strings.Contains(logs[1], "clock skew resolved") {
+		t.Fatalf("invalid logs, expected a clock skew resolved entry and received %v", logs)
+	}
+}
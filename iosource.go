@@ -11,7 +11,13 @@ import (
 	"strings"
 )
 
-var _ Source = &IOSource{}
+var (
+	_ Source  = &IOSource{}
+	_ Deleter = &IOSource{}
+	_ Lister  = &IOSource{}
+	_ Watcher = &IOSource{}
+	_ Sizer   = &IOSource{}
+)
 
 func NewIOSource(dir string) (ip *IOSource, err error) {
 	var i IOSource
@@ -81,6 +87,18 @@ func (i *IOSource) Get(ctx context.Context, prefix, filename string, fn func(io.
 	return fn(f)
 }
 
+// Subscribe will notify of files added to the prefix, an empty filename is sent when
+// notifications have been dropped and the prefix should be listed again
+// Note: This is only supported on linux, ErrWatchUnsupported is returned on other platforms
+func (i *IOSource) Subscribe(ctx context.Context, prefix string) (filenames <-chan string, err error) {
+	dir := path.Join(i.dir, prefix)
+	if err = os.MkdirAll(dir, 0744); err != nil {
+		return
+	}
+
+	return watchDir(ctx, dir)
+}
+
 func (i *IOSource) GetNext(ctx context.Context, prefix, lastFilename string) (filename string, err error) {
 	wfn := func(walkingFile string, info os.FileInfo, ierr error) (err error) {
 		if ierr != nil {
@@ -104,7 +122,8 @@ func (i *IOSource) GetNext(ctx context.Context, prefix, lastFilename string) (fi
 		return errBreak
 	}
 
-	if err = filepath.Walk(i.dir, wfn); err == errBreak {
+	dir := path.Join(i.dir, prefix)
+	if err = filepath.Walk(dir, wfn); err == errBreak {
 		return filename, nil
 	}
 
@@ -169,6 +188,49 @@ func (i *IOSource) GetInfo(ctx context.Context, prefix, filename string) (info I
 	return
 }
 
+// GetSize will return the size of a file without hashing it
+func (i *IOSource) GetSize(ctx context.Context, prefix, filename string) (size int64, err error) {
+	var fi os.FileInfo
+	if fi, err = os.Stat(path.Join(i.dir, prefix, filename)); err != nil {
+		return
+	}
+
+	return fi.Size(), nil
+}
+
+func (i *IOSource) Delete(ctx context.Context, prefix, filename string) (err error) {
+	filepath := path.Join(i.dir, prefix, filename)
+	if err = os.Remove(filepath); os.IsNotExist(err) {
+		return nil
+	}
+
+	return
+}
+
+func (i *IOSource) List(ctx context.Context, prefix string, fn func(filename string) error) (err error) {
+	var entries []os.DirEntry
+	entries, err = os.ReadDir(path.Join(i.dir, prefix))
+	switch {
+	case os.IsNotExist(err):
+		return nil
+	case err != nil:
+		return
+	}
+
+	// Note: Entries are sorted by filename
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		if err = fn(entry.Name()); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
 func (i *IOSource) getFileHash(filePath string) (out string, err error) {
 	var file *os.File
 	if file, err = os.Open(filePath); err != nil {
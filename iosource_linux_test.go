@@ -0,0 +1,95 @@
+//go:build linux
+
+package kiroku
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIOSource_Subscribe(t *testing.T) {
+	var err error
+	if err = os.MkdirAll("./testing_source", 0744); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll("./testing_source")
+
+	var src *IOSource
+	if src, err = NewIOSource("./testing_source"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var filenames <-chan string
+	if filenames, err = src.Subscribe(ctx, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = src.Export(ctx, "test", "test.1.chunk.kir", strings.NewReader("hello world")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case filename := <-filenames:
+		if filename != "test.1.chunk.kir" {
+			t.Fatalf("invalid filename, expected <%s> and received <%s>", "test.1.chunk.kir", filename)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out waiting for notification")
+	}
+
+	// Ensure the channel is closed once the context is done
+	cancel()
+	for range filenames {
+	}
+}
+
+func TestConsumer_subscribe(t *testing.T) {
+	var err error
+	if err = os.MkdirAll("./testing_source", 0744); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll("./testing_source")
+
+	var src *IOSource
+	if src, err = NewIOSource("./testing_source"); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := MakeOptions("./testing", "test")
+	// Ensure the Consumer does not wake from polling
+	opts.EndOfResultsDelay = time.Hour
+	if err = os.MkdirAll(opts.Dir, 0744); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(opts.Dir)
+
+	processed := make(chan struct{}, 1)
+	var c *Consumer
+	if c, err = NewConsumer(opts, src, func(typ Type, r *Reader) error {
+		processed <- struct{}{}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// Wait for the Consumer to reach the end of the Source
+	time.Sleep(time.Millisecond * 50)
+	filename := makeFilename("test", time.Now().UnixNano(), TypeChunk)
+	if _, err = src.Export(context.Background(), "test", filename.String(), bytes.NewReader(newTestChunk(Block("hello world")))); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-processed:
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out waiting for chunk to be processed")
+	}
+}
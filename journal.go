@@ -0,0 +1,200 @@
+package kiroku
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"sync"
+)
+
+func newJournal(dir, name string) (jp *journal, err error) {
+	var j journal
+	j.filepath = path.Join(dir, name+".journal")
+	j.processed = map[string]int64{}
+	j.pending = map[string]struct{}{}
+	if err = j.load(); err != nil {
+		err = fmt.Errorf("error loading journal: %v", err)
+		return
+	}
+
+	if j.f, err = createAppendFile(j.filepath); err != nil {
+		return
+	}
+
+	jp = &j
+	return
+}
+
+// journal records the files downloaded by a Consumer within the lookback window
+// so files which are listed again are not applied twice
+// Note: All methods are safe to call on a nil journal, which accepts every file
+type journal struct {
+	mux sync.Mutex
+
+	filepath string
+	f        *os.File
+
+	// Downloaded filenames and their created at timestamps
+	processed map[string]int64
+	// Filenames which are currently being downloaded
+	pending map[string]struct{}
+}
+
+// Reserve will return whether or not the file should be downloaded, reserved
+// files are pending until they are committed or cancelled
+func (j *journal) Reserve(f Filename) (ok bool) {
+	if j == nil {
+		return true
+	}
+
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	key := f.String()
+	if _, ok = j.processed[key]; ok {
+		return false
+	}
+
+	if _, ok = j.pending[key]; ok {
+		return false
+	}
+
+	j.pending[key] = struct{}{}
+	return true
+}
+
+// Commit will record the file as downloaded
+func (j *journal) Commit(f Filename) (err error) {
+	if j == nil {
+		return
+	}
+
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	key := f.String()
+	delete(j.pending, key)
+	if _, ok := j.processed[key]; ok {
+		return
+	}
+
+	if _, err = j.f.WriteString(key + "\n"); err != nil {
+		err = fmt.Errorf("error writing to journal: %v", err)
+		return
+	}
+
+	j.processed[key] = f.CreatedAt
+	return
+}
+
+// Cancel will release a reserved file so it can be reserved again
+func (j *journal) Cancel(f Filename) {
+	if j == nil {
+		return
+	}
+
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	delete(j.pending, f.String())
+}
+
+// Prune will remove the files created before the provided timestamp
+func (j *journal) Prune(before int64) (err error) {
+	if j == nil {
+		return
+	}
+
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	var pruned bool
+	for key, createdAt := range j.processed {
+		if createdAt >= before {
+			continue
+		}
+
+		delete(j.processed, key)
+		pruned = true
+	}
+
+	if !pruned {
+		// Nothing was pruned, return
+		return
+	}
+
+	return j.rewrite()
+}
+
+// Len will return the number of files within the journal
+func (j *journal) Len() int {
+	if j == nil {
+		return 0
+	}
+
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	return len(j.processed)
+}
+
+// Close will close the underlying journal file
+func (j *journal) Close() (err error) {
+	if j == nil {
+		return
+	}
+
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	return j.f.Close()
+}
+
+func (j *journal) load() (err error) {
+	var f *os.File
+	if f, err = os.Open(j.filepath); os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var parsed Filename
+		if parsed, err = ParseFilename(scanner.Text()); err != nil {
+			// Entry is invalid (E.g. a partially written line), skip
+			err = nil
+			continue
+		}
+
+		j.processed[parsed.String()] = parsed.CreatedAt
+	}
+
+	return scanner.Err()
+}
+
+// rewrite will replace the journal file with the current entries
+func (j *journal) rewrite() (err error) {
+	tmpFilepath := j.filepath + ".tmp"
+	var tmp *os.File
+	if tmp, err = createFile(tmpFilepath); err != nil {
+		return
+	}
+
+	w := bufio.NewWriter(tmp)
+	for key := range j.processed {
+		if _, err = w.WriteString(key + "\n"); err != nil {
+			tmp.Close()
+			return
+		}
+	}
+
+	if err = handleTwoErrors(w.Flush(), tmp.Close()); err != nil {
+		return
+	}
+
+	if err = renameFile(tmpFilepath, j.filepath); err != nil {
+		return
+	}
+
+	// Reopen the journal, as the previous file has been replaced
+	_ = j.f.Close()
+	j.f, err = createAppendFile(j.filepath)
+	return
+}
@@ -0,0 +1,90 @@
+package kiroku
+
+import (
+	"os"
+	"testing"
+)
+
+func Test_journal(t *testing.T) {
+	dir := "./testing"
+	if err := os.Mkdir(dir, 0744); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	j, err := newJournal(dir, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := makeFilename("test", 1000, TypeChunk)
+	second := makeSequencedFilename("test", 2000, 1, 2, TypeChunk)
+	if !j.Reserve(first) {
+		t.Fatal("expected first file to be reserved")
+	}
+
+	if j.Reserve(first) {
+		t.Fatal("expected pending file to not be reserved twice")
+	}
+
+	// Cancelled files can be reserved again
+	j.Cancel(first)
+	if !j.Reserve(first) {
+		t.Fatal("expected cancelled file to be reserved")
+	}
+
+	if err = j.Commit(first); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = j.Commit(second); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = j.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Ensure the journal is loaded from disk
+	if j, err = newJournal(dir, "test"); err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	if j.Reserve(first) || j.Reserve(second) {
+		t.Fatal("expected committed files to not be reserved")
+	}
+
+	if err = j.Prune(1500); err != nil {
+		t.Fatal(err)
+	}
+
+	if !j.Reserve(first) {
+		t.Fatal("expected pruned file to be reserved")
+	}
+
+	if j.Len() != 1 {
+		t.Fatalf("invalid journal length, expected %d and received %d", 1, j.Len())
+	}
+
+	// Ensure the journal can be written to after it's been rewritten
+	if err = j.Commit(first); err != nil {
+		t.Fatal(err)
+	}
+
+	if j.Len() != 2 {
+		t.Fatalf("invalid journal length, expected %d and received %d", 2, j.Len())
+	}
+}
+
+func Test_journal_nil(t *testing.T) {
+	var j *journal
+	f := makeFilename("test", 1000, TypeChunk)
+	if !j.Reserve(f) || !j.Reserve(f) {
+		t.Fatal("expected nil journal to reserve every file")
+	}
+
+	if err := j.Commit(f); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,134 @@
+package kiroku
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"sync"
+
+	"github.com/hatchify/errors"
+)
+
+const (
+	// ErrUnknownKeyID is returned when a key ID is not present within a Keyring
+	ErrUnknownKeyID = errors.Error("unknown key ID")
+	// ErrEmptyKeyID is returned when a key ID is empty
+	ErrEmptyKeyID = errors.Error("invalid key ID, cannot be empty")
+)
+
+var _ KeyProvider = &Keyring{}
+
+// KeyProvider wraps and unwraps the data keys used to encrypt exported chunks
+// Note: Implementations may delegate to an external key management service
+type KeyProvider interface {
+	// WrapKey will encrypt a data key with the current key encryption key and return the ID of the key used
+	WrapKey(ctx context.Context, dataKey []byte) (keyID string, wrapped []byte, err error)
+	// UnwrapKey will decrypt a data key which was wrapped by the key with the provided ID
+	UnwrapKey(ctx context.Context, keyID string, wrapped []byte) (dataKey []byte, err error)
+}
+
+// NewKeyring will initialize a new Keyring with the provided current key
+func NewKeyring(keyID string, key []byte) (kp *Keyring, err error) {
+	var k Keyring
+	k.keys = map[string]cipher.AEAD{}
+	if err = k.Rotate(keyID, key); err != nil {
+		return
+	}
+
+	kp = &k
+	return
+}
+
+// Keyring is a KeyProvider which wraps data keys locally using AES-GCM
+type Keyring struct {
+	mux sync.RWMutex
+
+	current string
+	keys    map[string]cipher.AEAD
+}
+
+// Add will add a key which can be used to unwrap data keys
+func (k *Keyring) Add(keyID string, key []byte) (err error) {
+	if len(keyID) == 0 {
+		return ErrEmptyKeyID
+	}
+
+	var aead cipher.AEAD
+	if aead, err = newAEAD(key); err != nil {
+		return fmt.Errorf("error initializing key <%s>: %v", keyID, err)
+	}
+
+	k.mux.Lock()
+	defer k.mux.Unlock()
+	k.keys[keyID] = aead
+	return
+}
+
+// Rotate will add a key and set it as the current key for new chunks
+// Note: Previous keys are kept so chunks encrypted with them remain readable
+func (k *Keyring) Rotate(keyID string, key []byte) (err error) {
+	if err = k.Add(keyID, key); err != nil {
+		return
+	}
+
+	k.mux.Lock()
+	defer k.mux.Unlock()
+	k.current = keyID
+	return
+}
+
+// CurrentKeyID will return the ID of the key used for new chunks
+func (k *Keyring) CurrentKeyID() string {
+	k.mux.RLock()
+	defer k.mux.RUnlock()
+	return k.current
+}
+
+// WrapKey will encrypt a data key with the current key
+func (k *Keyring) WrapKey(ctx context.Context, dataKey []byte) (keyID string, wrapped []byte, err error) {
+	k.mux.RLock()
+	defer k.mux.RUnlock()
+	keyID = k.current
+	aead := k.keys[keyID]
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return
+	}
+
+	// Key ID is used as additional data so a wrapped key cannot be associated with another key ID
+	wrapped = aead.Seal(nonce, nonce, dataKey, []byte(keyID))
+	return
+}
+
+// UnwrapKey will decrypt a data key with the key matching the provided ID
+func (k *Keyring) UnwrapKey(ctx context.Context, keyID string, wrapped []byte) (dataKey []byte, err error) {
+	k.mux.RLock()
+	aead, ok := k.keys[keyID]
+	k.mux.RUnlock()
+	if !ok {
+		return nil, ErrUnknownKeyID
+	}
+
+	if len(wrapped) < aead.NonceSize() {
+		return nil, ErrDecryptionFailed
+	}
+
+	nonce := wrapped[:aead.NonceSize()]
+	if dataKey, err = aead.Open(nil, nonce, wrapped[aead.NonceSize():], []byte(keyID)); err != nil {
+		return nil, ErrDecryptionFailed
+	}
+
+	return
+}
+
+func newAEAD(key []byte) (aead cipher.AEAD, err error) {
+	var block cipher.Block
+	if block, err = aes.NewCipher(key); err != nil {
+		return
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,117 @@
+package kiroku
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestKeyring_WrapKey_UnwrapKey(t *testing.T) {
+	type testcase struct {
+		name string
+		// Keys to rotate to after wrapping
+		rotate  []string
+		unwrap  string
+		tamper  bool
+		wantErr error
+	}
+
+	tests := []testcase{
+		{
+			name:   "basic",
+			unwrap: "key_0",
+		},
+		{
+			name:   "after rotation",
+			rotate: []string{"key_1", "key_2"},
+			unwrap: "key_0",
+		},
+		{
+			name:    "unknown key",
+			unwrap:  "key_3",
+			wantErr: ErrUnknownKeyID,
+		},
+		{
+			name:    "mismatched key",
+			rotate:  []string{"key_1"},
+			unwrap:  "key_1",
+			wantErr: ErrDecryptionFailed,
+		},
+		{
+			name:    "tampered",
+			unwrap:  "key_0",
+			tamper:  true,
+			wantErr: ErrDecryptionFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, err := NewKeyring("key_0", newTestKey(0))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			dataKey := newTestKey(42)
+			keyID, wrapped, err := k.WrapKey(context.Background(), dataKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if keyID != "key_0" {
+				t.Fatalf("invalid key ID, expected <key_0> and received <%s>", keyID)
+			}
+
+			for i, id := range tt.rotate {
+				if err = k.Rotate(id, newTestKey(byte(i+1))); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if tt.tamper {
+				wrapped[len(wrapped)-1] ^= 0xff
+			}
+
+			var got []byte
+			got, err = k.UnwrapKey(context.Background(), tt.unwrap, wrapped)
+			if err != tt.wantErr {
+				t.Fatalf("Keyring.UnwrapKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err == nil && !bytes.Equal(got, dataKey) {
+				t.Fatal("invalid unwrapped data key")
+			}
+		})
+	}
+}
+
+func TestKeyring_Rotate(t *testing.T) {
+	k, err := NewKeyring("key_0", newTestKey(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = k.Rotate("key_1", newTestKey(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	if id := k.CurrentKeyID(); id != "key_1" {
+		t.Fatalf("invalid current key ID, expected <key_1> and received <%s>", id)
+	}
+
+	if err = k.Rotate("", newTestKey(2)); err != ErrEmptyKeyID {
+		t.Fatalf("invalid error, expected <%v> and received <%v>", ErrEmptyKeyID, err)
+	}
+
+	if err = k.Rotate("key_2", []byte("too short")); err == nil {
+		t.Fatal("expected error for invalid key size and received nil")
+	}
+
+	if id := k.CurrentKeyID(); id != "key_1" {
+		t.Fatalf("invalid current key ID, expected <key_1> and received <%s>", id)
+	}
+}
+
+func newTestKey(seed byte) []byte {
+	return bytes.Repeat([]byte{seed}, dataKeySize)
+}
@@ -0,0 +1,315 @@
+package kiroku
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hatchify/errors"
+)
+
+const (
+	// ErrLeaseHeld is returned when a Producer cannot acquire a lease which is held by another Producer
+	ErrLeaseHeld = errors.Error("lease is held by another producer")
+	// ErrLeaseLost is returned when a Producer no longer holds the lease for it's stream
+	ErrLeaseLost = errors.Error("lease has been lost, transactions and snapshots are refused")
+	// ErrLeaseNilSource is returned when a LeasePolicy is set for a Producer without a Source
+	ErrLeaseNilSource = errors.Error("invalid lease policy, leases require a source")
+	// ErrStaleFencingToken is reported to the Observer when a Consumer skips a chunk written under an
+	// older lease than a previously applied chunk (E.g. a paused Producer exported after it's lease was taken over)
+	ErrStaleFencingToken = errors.Error("chunk was written under a stale lease, fencing token is older than the last applied token")
+	// ErrInvalidLeasePolicy is returned when a LeasePolicy has invalid values
+	ErrInvalidLeasePolicy = errors.Error("invalid lease policy, values cannot be negative and RenewInterval must be less than TTL")
+)
+
+// leasesPrefix is the Source prefix containing the leases of Producers
+const leasesPrefix = "_leases"
+
+// LeasePolicy enables single-writer fencing for Producers sharing a Name. The
+// lease is stored within the Source and must be held to write transactions,
+// snapshots, and to export files. The fencing token of the lease is written to
+// the header of each chunk and snapshot, Consumers reject files with a token
+// older than the last applied token.
+// Note: Sources do not provide a compare-and-swap, acquiring a lease is a write
+// followed by a read-back and is best-effort. Consumers rejecting stale tokens is
+// what guarantees a stale Producer's files are not applied.
+type LeasePolicy struct {
+	// TTL is the amount of time a lease is held without being renewed (Default
+	// is disabled)
+	TTL time.Duration `toml:"ttl" json:"ttl"`
+	// RenewInterval is the amount of time to wait between lease renewals
+	// (Default is a third of the TTL)
+	RenewInterval time.Duration `toml:"renew_interval" json:"renewInterval"`
+}
+
+// IsEmpty will return whether or not leases are enabled
+func (l LeasePolicy) IsEmpty() bool {
+	return l.TTL == 0
+}
+
+// Validate ensures that the LeasePolicy values are valid
+func (l LeasePolicy) Validate() (err error) {
+	switch {
+	case l.TTL < 0, l.RenewInterval < 0:
+		return ErrInvalidLeasePolicy
+	case l.RenewInterval > 0 && l.RenewInterval >= l.TTL:
+		return ErrInvalidLeasePolicy
+	}
+
+	return
+}
+
+func (l LeasePolicy) renewInterval() time.Duration {
+	if l.RenewInterval > 0 {
+		return l.RenewInterval
+	}
+
+	return l.TTL / 3
+}
+
+// Lease represents a Producer's claim to be the single writer of a stream
+type Lease struct {
+	// ProducerID is the ID of the Producer which holds the lease
+	ProducerID ProducerID `json:"producerID"`
+	// Token is the fencing token of the lease, it increases each time the lease
+	// is acquired by a Producer
+	Token uint64 `json:"token"`
+	// ExpiresAt is the time at which the lease expires unless it is renewed
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// IsExpired will return whether or not the lease has expired at the provided time
+func (l Lease) IsExpired(now time.Time) bool {
+	return !now.Before(l.ExpiresAt)
+}
+
+func (l Lease) equals(in Lease) bool {
+	switch {
+	case l.ProducerID != in.ProducerID:
+	case l.Token != in.Token:
+	case !l.ExpiresAt.Equal(in.ExpiresAt):
+	default:
+		return true
+	}
+
+	return false
+}
+
+func newLeaser(src Source, name string, id ProducerID, policy LeasePolicy, c clock) *leaser {
+	var l leaser
+	l.src = src
+	l.name = name
+	l.id = id
+	l.policy = policy
+	l.clock = c
+	return &l
+}
+
+// leaser acquires, renews, and releases the lease of a Producer
+type leaser struct {
+	mux sync.RWMutex
+
+	src    Source
+	name   string
+	id     ProducerID
+	policy LeasePolicy
+	clock  clock
+
+	// Last lease successfully written by the leaser
+	lease Lease
+	lost  bool
+}
+
+// Acquire will acquire the lease, ErrLeaseHeld is returned when another
+// Producer holds an unexpired lease
+func (l *leaser) Acquire(ctx context.Context) (err error) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	var current Lease
+	if current, err = l.get(ctx); err != nil {
+		return
+	}
+
+	now := l.clock.Now()
+	if current.ProducerID != l.id && !current.IsExpired(now) {
+		return ErrLeaseHeld
+	}
+
+	var lease Lease
+	lease.ProducerID = l.id
+	// Increment the fencing token as the lease is changing hands
+	lease.Token = current.Token + 1
+	lease.ExpiresAt = now.Add(l.policy.TTL)
+	if err = l.put(ctx, lease); err != nil {
+		return
+	}
+
+	l.lease = lease
+	l.lost = false
+	return
+}
+
+// Renew will extend the lease, ErrLeaseLost is returned when the lease has
+// been acquired by another Producer or has expired
+func (l *leaser) Renew(ctx context.Context) (err error) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if l.lost {
+		return ErrLeaseLost
+	}
+
+	var current Lease
+	if current, err = l.get(ctx); err != nil {
+		// Unable to confirm the lease, it is lost once it has expired
+		return l.checkExpired(err)
+	}
+
+	if current.ProducerID != l.id || current.Token != l.lease.Token {
+		l.lost = true
+		return ErrLeaseLost
+	}
+
+	lease := l.lease
+	lease.ExpiresAt = l.clock.Now().Add(l.policy.TTL)
+	switch err = l.put(ctx, lease); err {
+	case nil:
+	case ErrLeaseHeld:
+		// Another Producer acquired the lease while renewing
+		l.lost = true
+		return ErrLeaseLost
+	default:
+		return l.checkExpired(err)
+	}
+
+	l.lease = lease
+	return
+}
+
+// Release will expire the lease so another Producer can acquire it immediately
+func (l *leaser) Release(ctx context.Context) (err error) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if l.lost {
+		return
+	}
+
+	var current Lease
+	if current, err = l.get(ctx); err != nil {
+		return
+	}
+
+	if current.ProducerID != l.id || current.Token != l.lease.Token {
+		// Lease is held by another Producer, return
+		return
+	}
+
+	lease := l.lease
+	lease.ExpiresAt = time.Time{}
+	if err = l.put(ctx, lease); err != nil {
+		return
+	}
+
+	l.lease = lease
+	l.lost = true
+	return
+}
+
+// Validate will return ErrLeaseLost when the lease is not held
+// Note: Leases are disabled when the leaser is nil
+func (l *leaser) Validate() (err error) {
+	if l == nil {
+		return
+	}
+
+	l.mux.RLock()
+	defer l.mux.RUnlock()
+	if l.lost || l.lease.IsExpired(l.clock.Now()) {
+		return ErrLeaseLost
+	}
+
+	return
+}
+
+// Token will return the fencing token of the lease
+// Note: The token is 0 when leases are disabled (the leaser is nil)
+func (l *leaser) Token() uint64 {
+	if l == nil {
+		return 0
+	}
+
+	l.mux.RLock()
+	defer l.mux.RUnlock()
+	return l.lease.Token
+}
+
+// Lease will return the last lease written by the leaser
+func (l *leaser) Lease() Lease {
+	l.mux.RLock()
+	defer l.mux.RUnlock()
+	return l.lease
+}
+
+func (l *leaser) next() <-chan time.Time {
+	return l.clock.After(l.policy.renewInterval())
+}
+
+func (l *leaser) checkExpired(err error) error {
+	if !l.lease.IsExpired(l.clock.Now()) {
+		return err
+	}
+
+	l.lost = true
+	return fmt.Errorf("%w: %v", ErrLeaseLost, err)
+}
+
+func (l *leaser) get(ctx context.Context) (lease Lease, err error) {
+	err = l.src.Get(ctx, leasesPrefix, getLeaseName(l.name), func(r io.Reader) (err error) {
+		return json.NewDecoder(r).Decode(&lease)
+	})
+
+	switch err {
+	case nil:
+	case os.ErrNotExist:
+		// Lease has not been written, return an empty lease
+		err = nil
+	default:
+		err = fmt.Errorf("error getting lease: %v", err)
+	}
+
+	return
+}
+
+func (l *leaser) put(ctx context.Context, lease Lease) (err error) {
+	var bs []byte
+	if bs, err = json.Marshal(lease); err != nil {
+		return
+	}
+
+	if _, err = l.src.Export(ctx, leasesPrefix, getLeaseName(l.name), bytes.NewReader(bs)); err != nil {
+		return fmt.Errorf("error setting lease: %v", err)
+	}
+
+	// Read the lease back, another Producer may have written it's lease concurrently
+	// Note: This is best-effort, two Producers can both read back their own lease when
+	// their writes interleave. Stale writers are fenced by the token within chunk headers
+	var current Lease
+	if current, err = l.get(ctx); err != nil {
+		return
+	}
+
+	if !current.equals(lease) {
+		return ErrLeaseHeld
+	}
+
+	return
+}
+
+func getLeaseName(name string) string {
+	return fmt.Sprintf("%s.json", name)
+}
@@ -0,0 +1,263 @@
+package kiroku
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestLeasePolicy_Validate(t *testing.T) {
+	type testcase struct {
+		name    string
+		policy  LeasePolicy
+		wantErr error
+	}
+
+	tests := []testcase{
+		{
+			name: "empty",
+		},
+		{
+			name:   "ttl",
+			policy: LeasePolicy{TTL: time.Second},
+		},
+		{
+			name:   "ttl and renew interval",
+			policy: LeasePolicy{TTL: time.Second, RenewInterval: time.Millisecond * 100},
+		},
+		{
+			name:    "negative ttl",
+			policy:  LeasePolicy{TTL: -1},
+			wantErr: ErrInvalidLeasePolicy,
+		},
+		{
+			name:    "renew interval exceeds ttl",
+			policy:  LeasePolicy{TTL: time.Second, RenewInterval: time.Second},
+			wantErr: ErrInvalidLeasePolicy,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.policy.Validate(); err != tt.wantErr {
+				t.Fatalf("invalid error, expected <%v> and received <%v>", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func Test_leaser(t *testing.T) {
+	var err error
+	if err = os.MkdirAll("./testing_source", 0744); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll("./testing_source")
+
+	var src *IOSource
+	if src, err = NewIOSource("./testing_source"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	c := newFakeClock(time.Unix(0, 0))
+	policy := LeasePolicy{TTL: time.Second}
+	first := newLeaser(src, "test", 1, policy, c)
+	second := newLeaser(src, "test", 2, policy, c)
+	if err = first.Acquire(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = second.Acquire(ctx); err != ErrLeaseHeld {
+		t.Fatalf("invalid error, expected <%v> and received <%v>", ErrLeaseHeld, err)
+	}
+
+	// Renewing extends the lease beyond the original TTL
+	c.Advance(time.Millisecond * 600)
+	if err = first.Renew(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	c.Advance(time.Millisecond * 600)
+	if err = first.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Allow the lease to expire, it can then be acquired by the second leaser
+	c.Advance(time.Second)
+	if err = first.Validate(); err != ErrLeaseLost {
+		t.Fatalf("invalid error, expected <%v> and received <%v>", ErrLeaseLost, err)
+	}
+
+	if err = second.Acquire(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if token := second.Lease().Token; token != 2 {
+		t.Fatalf("invalid fencing token, expected %d and received %d", 2, token)
+	}
+
+	if err = first.Renew(ctx); err != ErrLeaseLost {
+		t.Fatalf("invalid error, expected <%v> and received <%v>", ErrLeaseLost, err)
+	}
+
+	// Releasing allows the lease to be acquired immediately
+	if err = second.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = first.Acquire(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if token := first.Lease().Token; token != 3 {
+		t.Fatalf("invalid fencing token, expected %d and received %d", 3, token)
+	}
+}
+
+func Test_leaser_Renew_unreachable(t *testing.T) {
+	unreachable := fmt.Errorf("source unreachable")
+	src := &mockSource{
+		getFn: func(ctx context.Context, prefix, filename string, fn func(io.Reader) error) error {
+			return unreachable
+		},
+	}
+
+	ctx := context.Background()
+	c := newFakeClock(time.Unix(0, 0))
+	l := newLeaser(src, "test", 1, LeasePolicy{TTL: time.Second}, c)
+	l.lease = Lease{ProducerID: 1, Token: 1, ExpiresAt: c.Now().Add(time.Second)}
+
+	// The lease is retained while it has not expired
+	err := l.Renew(ctx)
+	if err == nil || stderrors.Is(err, ErrLeaseLost) {
+		t.Fatalf("invalid error, expected <%v> and received <%v>", unreachable, err)
+	}
+
+	// The lease is lost once it has expired without being renewed
+	c.Advance(time.Second)
+	if err = l.Renew(ctx); !stderrors.Is(err, ErrLeaseLost) {
+		t.Fatalf("invalid error, expected <%v> and received <%v>", ErrLeaseLost, err)
+	}
+}
+
+func TestProducer_lease(t *testing.T) {
+	var err error
+	if err = os.MkdirAll("./testing_source", 0744); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll("./testing_source")
+
+	var src *IOSource
+	if src, err = NewIOSource("./testing_source"); err != nil {
+		t.Fatal(err)
+	}
+
+	newOpts := func(dir string) (opts Options) {
+		opts = MakeOptions(dir, "test")
+		opts.Lease.TTL = time.Second
+		if err = os.MkdirAll(opts.Dir, 0744); err != nil {
+			t.Fatal(err)
+		}
+
+		return
+	}
+
+	firstOpts := newOpts("./testing_first")
+	defer os.RemoveAll(firstOpts.Dir)
+	secondOpts := newOpts("./testing_second")
+	defer os.RemoveAll(secondOpts.Dir)
+
+	c := newFakeClock(time.Now())
+	var first *Producer
+	if first, err = newProducer(context.Background(), firstOpts, src, c); err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+
+	if _, err = NewProducer(secondOpts, src); err != ErrLeaseHeld {
+		t.Fatalf("invalid error, expected <%v> and received <%v>", ErrLeaseHeld, err)
+	}
+
+	if err = first.Transaction(func(txn *Transaction) error {
+		return txn.Write([]byte("hello world"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var lease Lease
+	if lease, err = first.Lease(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Exported chunks carry the fencing token of the lease
+	var filename string
+	waitFor(t, func() bool {
+		filename, err = src.GetNext(context.Background(), "test", "")
+		return err == nil
+	})
+
+	var h Header
+	if err = src.Get(context.Background(), "test", filename, func(r io.Reader) (err error) {
+		h, err = NewReader(readFile(t, r)).Header()
+		return
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !h.Flags.Has(FlagFencingToken) || h.FencingToken != lease.Token {
+		t.Fatalf("invalid fencing token, expected %d and received %d", lease.Token, h.FencingToken)
+	}
+
+	// Another Producer takes over the lease
+
+	takeover := newLeaser(src, "test", lease.ProducerID+1, firstOpts.Lease, c)
+	lease.ProducerID = takeover.id
+	lease.Token++
+	if err = takeover.put(context.Background(), lease); err != nil {
+		t.Fatal(err)
+	}
+
+	// Trigger a renewal of the first Producer's lease
+	waitFor(t, func() bool { return c.Waiters() == 1 })
+	c.Advance(firstOpts.Lease.renewInterval())
+	waitFor(t, func() bool {
+		err = first.Transaction(func(txn *Transaction) error {
+			return txn.Write([]byte("hello world"))
+		})
+
+		return err == ErrLeaseLost
+	})
+
+	if err = first.Snapshot(func(ss *Snapshot) error {
+		return ss.Write([]byte("snapshot"))
+	}); err != ErrLeaseLost {
+		t.Fatalf("invalid error, expected <%v> and received <%v>", ErrLeaseLost, err)
+	}
+}
+
+func TestProducer_lease_nil_source(t *testing.T) {
+	opts := MakeOptions("./testing", "test")
+	opts.Lease.TTL = time.Second
+	if err := os.MkdirAll(opts.Dir, 0744); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(opts.Dir)
+
+	if _, err := NewProducer(opts, nil); err != ErrLeaseNilSource {
+		t.Fatalf("invalid error, expected <%v> and received <%v>", ErrLeaseNilSource, err)
+	}
+}
+
+func readFile(t *testing.T, r io.Reader) File {
+	bs, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return bytes.NewReader(bs)
+}
@@ -1,9 +1,33 @@
 package kiroku
 
+var (
+	_ LedgerWriter = &Producer{}
+	_ LedgerWriter = &MemoryLedger{}
+	_ LedgerReader = &Consumer{}
+	_ LedgerReader = &MemoryConsumer{}
+)
+
+// Ledger represents the functionality shared by the read and write sides of a ledger
 type Ledger interface {
 	Meta() (m Meta, err error)
-	Transaction(fn func(*Transaction) error) (err error)
-	Snapshot(fn func(*Snapshot) error) (err error)
-	Filename() (filename string, err error)
 	Close() (err error)
 }
+
+// LedgerWriter represents the write side of a ledger
+// Note: Implemented by Producer and MemoryLedger
+type LedgerWriter interface {
+	Ledger
+
+	Transaction(fn TransactionFn) (err error)
+	Snapshot(fn func(*Snapshot) error) (err error)
+	Batch(fn BatchFn) (err error)
+	BatchBlock(value []byte) (err error)
+}
+
+// LedgerReader represents the read side of a ledger
+// Note: Implemented by Consumer and MemoryConsumer
+type LedgerReader interface {
+	Ledger
+
+	Stats() (stats ConsumerStats, err error)
+}
@@ -0,0 +1,156 @@
+package kiroku
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hatchify/errors"
+)
+
+const (
+	// LogKeyStream is the log attribute key for the full stream name
+	LogKeyStream = "stream"
+	// LogKeyFilename is the log attribute key for a chunk or snapshot filename
+	LogKeyFilename = "filename"
+	// LogKeyType is the log attribute key for a chunk or snapshot type
+	LogKeyType = "type"
+	// LogKeyAttempt is the log attribute key for the attempt count of a retried operation
+	LogKeyAttempt = "attempt"
+	// LogKeyProducerID is the log attribute key for the ID of the Producer which wrote a chunk or snapshot
+	LogKeyProducerID = "producer_id"
+	// LogKeyError is the log attribute key for an error
+	LogKeyError = "error"
+)
+
+// Logger is a structured logger which accepts alternating key/value attribute pairs
+// Note: *slog.Logger satisfies this interface
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// newLogger will initialize a logger which includes the stream attribute with every entry
+func newLogger(l Logger, stream string) logger {
+	return logger{l: l, args: []any{LogKeyStream, stream}}
+}
+
+// logger wraps a Logger with a set of base attributes
+// Note: The zero value discards all entries
+type logger struct {
+	l    Logger
+	args []any
+}
+
+func (l logger) debug(msg string, args ...any) {
+	if l.l == nil {
+		return
+	}
+
+	l.l.Debug(msg, l.with(args)...)
+}
+
+func (l logger) info(msg string, args ...any) {
+	if l.l == nil {
+		return
+	}
+
+	l.l.Info(msg, l.with(args)...)
+}
+
+func (l logger) warn(msg string, args ...any) {
+	if l.l == nil {
+		return
+	}
+
+	l.l.Warn(msg, l.with(args)...)
+}
+
+func (l logger) error(msg string, args ...any) {
+	if l.l == nil {
+		return
+	}
+
+	l.l.Error(msg, l.with(args)...)
+}
+
+func (l logger) with(args []any) []any {
+	out := make([]any, 0, len(l.args)+len(args))
+	out = append(out, l.args...)
+	return append(out, args...)
+}
+
+// newCallbackLogger will initialize a Logger which calls the provided OnLog and OnError funcs
+// Note: Debug entries are only provided to OnLog when debugging is true
+func newCallbackLogger(onLog func(string), onError func(error), debugging bool) *callbackLogger {
+	var c callbackLogger
+	c.onLog = onLog
+	c.onError = onError
+	c.debugging = debugging
+	return &c
+}
+
+// callbackLogger adapts the OnLog and OnError callbacks to a Logger
+type callbackLogger struct {
+	onLog   func(string)
+	onError func(error)
+
+	debugging bool
+}
+
+// Debug will call OnLog with the formatted entry when debugging is enabled
+func (c *callbackLogger) Debug(msg string, args ...any) {
+	if !c.debugging {
+		return
+	}
+
+	c.onLog(formatLog(msg, args))
+}
+
+// Info will call OnLog with the formatted entry
+func (c *callbackLogger) Info(msg string, args ...any) {
+	c.onLog(formatLog(msg, args))
+}
+
+// Warn will call OnLog with the formatted entry
+func (c *callbackLogger) Warn(msg string, args ...any) {
+	c.onLog(formatLog(msg, args))
+}
+
+// Error will call OnError with the formatted entry
+func (c *callbackLogger) Error(msg string, args ...any) {
+	c.onError(errors.Error(formatLog(msg, args)))
+}
+
+// formatLog will format a log entry as a message followed by key=value attributes
+func formatLog(msg string, args []any) string {
+	var sb strings.Builder
+	sb.WriteString(msg)
+	for i := 0; i < len(args); i += 2 {
+		sb.WriteByte(' ')
+		if i+1 == len(args) {
+			// Dangling value without a key
+			fmt.Fprintf(&sb, "!BADKEY=%v", args[i])
+			break
+		}
+
+		fmt.Fprintf(&sb, "%v=%v", args[i], args[i+1])
+	}
+
+	return sb.String()
+}
+
+// getLogError will return the value of the error attribute, if it exists
+func getLogError(args []any) (err error, ok bool) {
+	for i := 0; i+1 < len(args); i += 2 {
+		if args[i] != LogKeyError {
+			continue
+		}
+
+		err, ok = args[i+1].(error)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,189 @@
+package kiroku
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+var _ Logger = &slog.Logger{}
+
+func Test_formatLog(t *testing.T) {
+	type testcase struct {
+		name string
+		msg  string
+		args []any
+		want string
+	}
+
+	tests := []testcase{
+		{
+			name: "message only",
+			msg:  "hello",
+			want: "hello",
+		},
+		{
+			name: "attributes",
+			msg:  "error processing",
+			args: []any{LogKeyStream, "test", LogKeyAttempt, 2, LogKeyError, fmt.Errorf("foo")},
+			want: "error processing stream=test attempt=2 error=foo",
+		},
+		{
+			name: "dangling value",
+			msg:  "hello",
+			args: []any{LogKeyStream, "test", "bar"},
+			want: "hello stream=test !BADKEY=bar",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatLog(tt.msg, tt.args); got != tt.want {
+				t.Fatalf("formatLog() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_callbackLogger(t *testing.T) {
+	type testcase struct {
+		name      string
+		debugging bool
+		log       func(Logger)
+		wantLogs  []string
+		wantErrs  []string
+	}
+
+	tests := []testcase{
+		{
+			name: "info",
+			log: func(l Logger) {
+				l.Info("downloading", LogKeyFilename, "test.1.chunk.kir")
+			},
+			wantLogs: []string{"downloading filename=test.1.chunk.kir"},
+		},
+		{
+			name: "warn",
+			log: func(l Logger) {
+				l.Warn("warning")
+			},
+			wantLogs: []string{"warning"},
+		},
+		{
+			name: "error",
+			log: func(l Logger) {
+				l.Error("error processing", LogKeyError, fmt.Errorf("foo"))
+			},
+			wantErrs: []string{"error processing error=foo"},
+		},
+		{
+			name: "debug without debugging",
+			log: func(l Logger) {
+				l.Debug("queue full")
+			},
+		},
+		{
+			name:      "debug with debugging",
+			debugging: true,
+			log: func(l Logger) {
+				l.Debug("queue full")
+			},
+			wantLogs: []string{"queue full"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs, errs []string
+			onLog := func(msg string) { logs = append(logs, msg) }
+			onError := func(err error) { errs = append(errs, err.Error()) }
+			tt.log(newCallbackLogger(onLog, onError, tt.debugging))
+
+			if fmt.Sprint(logs) != fmt.Sprint(tt.wantLogs) {
+				t.Fatalf("invalid logs, expected %v and received %v", tt.wantLogs, logs)
+			}
+
+			if fmt.Sprint(errs) != fmt.Sprint(tt.wantErrs) {
+				t.Fatalf("invalid errors, expected %v and received %v", tt.wantErrs, errs)
+			}
+		})
+	}
+}
+
+func TestOptions_Logger(t *testing.T) {
+	var err error
+	if err = os.MkdirAll("./testing_source", 0744); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll("./testing_source")
+
+	var src *IOSource
+	if src, err = NewIOSource("./testing_source"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	filename := makeFilename("test", 1000, TypeChunk)
+	if _, err = src.Export(ctx, "test", filename.String(), bytes.NewReader(newTestChunk(Block("hello world")))); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mux sync.Mutex
+		buf bytes.Buffer
+	)
+
+	opts := MakeOptions("./testing", "test")
+	opts.ErrorDelay = time.Millisecond
+	opts.Logger = slog.New(slog.NewTextHandler(&lockedWriter{mux: &mux, w: &buf}, nil))
+	if err = os.MkdirAll(opts.Dir, 0744); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(opts.Dir)
+
+	var c *Consumer
+	if c, err = NewConsumer(opts, src, func(typ Type, r *Reader) error {
+		return fmt.Errorf("foo")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	want := []string{
+		"level=ERROR",
+		"msg=\"error processing\"",
+		"stream=test",
+		"filename=" + filename.String(),
+		"type=chunk",
+		"attempt=2",
+	}
+
+	waitFor(t, func() bool {
+		mux.Lock()
+		defer mux.Unlock()
+		out := buf.String()
+		for _, w := range want {
+			if !bytes.Contains([]byte(out), []byte(w)) {
+				return false
+			}
+		}
+
+		return true
+	})
+}
+
+type lockedWriter struct {
+	mux *sync.Mutex
+	w   *bytes.Buffer
+}
+
+func (l *lockedWriter) Write(bs []byte) (n int, err error) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	return l.w.Write(bs)
+}
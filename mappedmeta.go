@@ -73,15 +73,55 @@ func (m *mappedMeta) Update(fn func(Meta) (Meta, error)) (err error) {
 	return
 }
 
-func (m *mappedMeta) SetDownloaded(createdAt int64, t Type) {
+func (m *mappedMeta) SetProcessed(f Filename) {
 	m.mux.Lock()
 	defer m.mux.Unlock()
 	if m.closed {
 		return
 	}
 
-	m.m.LastDownloadedTimestamp = createdAt
-	m.m.LastDownloadedType = t
+	m.m.setProcessed(f)
+}
+
+func (m *mappedMeta) SetDownloaded(f Filename) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if m.closed {
+		return
+	}
+
+	m.m.setDownloaded(f)
+}
+
+func (m *mappedMeta) SetApplied(f Filename, blockIndex int64) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if m.closed {
+		return
+	}
+
+	m.m.setApplied(f, blockIndex)
+}
+
+// SetFencingToken will set the last fencing token when the provided token is newer
+func (m *mappedMeta) SetFencingToken(token uint64) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if m.closed || token <= m.m.LastFencingToken {
+		return
+	}
+
+	m.m.LastFencingToken = token
+}
+
+func (m *mappedMeta) SetSequence(sequence uint64) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if m.closed {
+		return
+	}
+
+	m.m.Sequence = sequence
 }
 
 func (m *mappedMeta) Close() (err error) {
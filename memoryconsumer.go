@@ -0,0 +1,134 @@
+package kiroku
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hatchify/errors"
+)
+
+// NewMemoryConsumer will initialize a new MemoryConsumer for the provided MemoryLedger
+// Note: Unlike Consumer, files are only processed when Sync is called
+func NewMemoryConsumer(l *MemoryLedger, onUpdate UpdateFunc) *MemoryConsumer {
+	var c MemoryConsumer
+	c.l = l
+	c.onUpdate = onUpdate
+	return &c
+}
+
+// NewMemoryConsumerWithBlockCheckpoints will initialize a new MemoryConsumer which
+// retries a failed file from the block following the last applied block
+// Note: See Options.BlockCheckpoints
+func NewMemoryConsumerWithBlockCheckpoints(l *MemoryLedger, onUpdate UpdateFunc) *MemoryConsumer {
+	c := NewMemoryConsumer(l, onUpdate)
+	c.blockCheckpoints = true
+	return c
+}
+
+// MemoryConsumer is an in-memory LedgerReader which reads from a MemoryLedger
+type MemoryConsumer struct {
+	mux sync.Mutex
+
+	l        *MemoryLedger
+	onUpdate UpdateFunc
+	// Whether or not failed files are retried from the last applied block
+	blockCheckpoints bool
+
+	meta      Meta
+	processed counter
+	errs      errorStats
+
+	closed bool
+}
+
+// Sync will process all files written to the MemoryLedger since the last call
+// Note: When UpdateFunc returns an error, the following call will retry the file (resuming
+// from the last applied block when block checkpoints are enabled)
+func (c *MemoryConsumer) Sync() (err error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.closed {
+		return errors.ErrIsClosed
+	}
+
+	for _, f := range c.l.getNext(c.meta.LastProcessedTimestamp) {
+		if err = c.apply(f); err != nil {
+			err = fmt.Errorf("error encountered while processing <%s>: %v", f.filename, err)
+			c.errs.add(err.Error())
+			return
+		}
+	}
+
+	return
+}
+
+// Meta will return a copy of the current Meta
+func (c *MemoryConsumer) Meta() (meta Meta, err error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.closed {
+		err = errors.ErrIsClosed
+		return
+	}
+
+	meta = c.meta
+	return
+}
+
+// Stats will return the current ConsumerStats
+// Note: QueueDepth is the number of files which have not yet been processed
+func (c *MemoryConsumer) Stats() (stats ConsumerStats, err error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.closed {
+		err = errors.ErrIsClosed
+		return
+	}
+
+	stats.QueueDepth = int64(len(c.l.getNext(c.meta.LastProcessedTimestamp)))
+	stats.FilesProcessed, stats.BytesProcessed = c.processed.get()
+	stats.LastProcessedTimestamp = c.meta.LastProcessedTimestamp
+	stats.NewestSourceTimestamp = c.l.getNewest()
+	if stats.NewestSourceTimestamp < stats.LastProcessedTimestamp {
+		stats.NewestSourceTimestamp = stats.LastProcessedTimestamp
+	}
+
+	stats.Lag = time.Duration(stats.NewestSourceTimestamp - stats.LastProcessedTimestamp)
+	stats.ErrorCount, stats.LastError, stats.LastErrorAt = c.errs.get()
+	stats.WatcherState = WatcherStateIdle
+	return
+}
+
+// Close will close the MemoryConsumer
+func (c *MemoryConsumer) Close() (err error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.closed {
+		return errors.ErrIsClosed
+	}
+
+	c.closed = true
+	return
+}
+
+func (c *MemoryConsumer) apply(f memoryFile) (err error) {
+	r := NewReader(bytes.NewReader(f.bs))
+	if c.blockCheckpoints {
+		// Resume from the block following the last applied block
+		r.start = c.meta.nextBlockIndex(f.filename)
+		r.onApplied = func(pos Position) {
+			c.meta.setApplied(f.filename, pos.Index)
+		}
+	}
+
+	if err = c.onUpdate(f.filename.Filetype, r); err != nil {
+		return
+	}
+
+	c.meta.setProcessed(f.filename)
+	c.meta.setDownloaded(f.filename)
+	c.processed.add(int64(len(f.bs)))
+	return
+}
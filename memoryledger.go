@@ -0,0 +1,187 @@
+package kiroku
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/hatchify/errors"
+)
+
+// NewMemoryLedger will initialize a new in-memory Ledger
+// Note: This is intended for unit testing code which depends on kiroku without a filesystem or Source
+func NewMemoryLedger(name string) *MemoryLedger {
+	return NewMemoryLedgerWithCodec(name, nil)
+}
+
+// NewMemoryLedgerWithCodec will initialize a new in-memory Ledger which writes values with the provided Codec
+func NewMemoryLedgerWithCodec(name string, c Codec) *MemoryLedger {
+	var m MemoryLedger
+	m.name = name
+	m.codec = c
+	m.latestSnapshot = -1
+	// Batches are committed immediately, as there is no export to amortize
+	m.b = newBatcher(0, m.Transaction)
+	return &m
+}
+
+// MemoryLedger is an in-memory LedgerWriter, chunks and snapshots are kept in memory
+// and can be read by a MemoryConsumer
+type MemoryLedger struct {
+	mux sync.RWMutex
+
+	name  string
+	codec Codec
+	b     *batcher
+
+	files []memoryFile
+	// Index of the latest snapshot within files, -1 when no snapshot has been written
+	latestSnapshot int
+
+	closed bool
+}
+
+// Transaction will engage a new in-memory transaction
+func (m *MemoryLedger) Transaction(fn TransactionFn) (err error) {
+	return m.transaction(TypeChunk, func(w *Writer) error {
+		return fn(newTransaction(w))
+	})
+}
+
+// Snapshot will engage a new in-memory snapshot
+func (m *MemoryLedger) Snapshot(fn func(*Snapshot) error) (err error) {
+	return m.transaction(TypeSnapshot, func(w *Writer) error {
+		return fn(newSnapshot(w))
+	})
+}
+
+// Batch will engage a new batch transaction
+func (m *MemoryLedger) Batch(fn BatchFn) (err error) {
+	return m.b.Batch(fn)
+}
+
+// BatchBlock will add a block to a batch transaction
+func (m *MemoryLedger) BatchBlock(value []byte) (err error) {
+	berr := m.Batch(func(txn *Transaction) {
+		err = txn.Write(value)
+	})
+	return handleTwoErrors(berr, err)
+}
+
+// Meta will return the Meta of the last written chunk or snapshot
+func (m *MemoryLedger) Meta() (meta Meta, err error) {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	if m.closed {
+		err = errors.ErrIsClosed
+		return
+	}
+
+	if len(m.files) == 0 {
+		return
+	}
+
+	last := m.files[len(m.files)-1].filename
+	meta.LastProcessedTimestamp = last.CreatedAt
+	meta.LastProcessedType = last.Filetype
+	return
+}
+
+// Filenames will return the filenames of the written chunks and snapshots in order
+func (m *MemoryLedger) Filenames() (filenames []string) {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	for _, f := range m.files {
+		filenames = append(filenames, f.filename.String())
+	}
+
+	return
+}
+
+// Close will close the MemoryLedger
+func (m *MemoryLedger) Close() (err error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if m.closed {
+		return errors.ErrIsClosed
+	}
+
+	m.closed = true
+	return
+}
+
+func (m *MemoryLedger) transaction(t Type, fn func(*Writer) error) (err error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if m.closed {
+		return errors.ErrIsClosed
+	}
+
+	createdAt := time.Now().UnixNano()
+	if n := len(m.files); n > 0 && createdAt <= m.files[n-1].filename.CreatedAt {
+		// Ensure filenames are strictly ordered
+		createdAt = m.files[n-1].filename.CreatedAt + 1
+	}
+
+	var (
+		w   *Writer
+		buf bytes.Buffer
+	)
+
+	filename := makeFilename(m.name, createdAt, t)
+	if w, err = newWriterWithTarget(nopWriteCloser{&buf}, 0, filename, CompressionNone, m.codec, 0); err != nil {
+		return
+	}
+
+	if err = handleTwoErrors(fn(w), w.Close()); err != nil || w.blockCount == 0 {
+		return
+	}
+
+	if t == TypeSnapshot {
+		m.latestSnapshot = len(m.files)
+	}
+
+	m.files = append(m.files, memoryFile{filename: filename, bs: buf.Bytes()})
+	return
+}
+
+// getNext will return the files after the provided timestamp
+// Note: When the latest snapshot is newer than the timestamp, files begin at the latest snapshot
+func (m *MemoryLedger) getNext(lastProcessed int64) (files []memoryFile) {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	if m.latestSnapshot > -1 && m.files[m.latestSnapshot].filename.CreatedAt > lastProcessed {
+		return m.files[m.latestSnapshot:]
+	}
+
+	for i, f := range m.files {
+		if f.filename.CreatedAt > lastProcessed {
+			return m.files[i:]
+		}
+	}
+
+	return
+}
+
+func (m *MemoryLedger) getNewest() (createdAt int64) {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	if len(m.files) == 0 {
+		return
+	}
+
+	return m.files[len(m.files)-1].filename.CreatedAt
+}
+
+type memoryFile struct {
+	filename Filename
+	bs       []byte
+}
+
+type nopWriteCloser struct {
+	*bytes.Buffer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
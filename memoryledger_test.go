@@ -0,0 +1,201 @@
+package kiroku
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/hatchify/errors"
+)
+
+func TestMemoryLedger(t *testing.T) {
+	type write struct {
+		typ    Type
+		blocks []string
+	}
+
+	type testcase struct {
+		name   string
+		before []write
+		after  []write
+		// failAt fails the UpdateFunc once when the provided block is received
+		failAt string
+		// blockCheckpoints resumes a failed file from the last applied block
+		blockCheckpoints bool
+		want             []string
+	}
+
+	tests := []testcase{
+		{
+			name: "chunks",
+			before: []write{
+				{typ: TypeChunk, blocks: []string{"a", "b"}},
+				{typ: TypeChunk, blocks: []string{"c"}},
+			},
+			want: []string{"chunk:a", "chunk:b", "chunk:c"},
+		},
+		{
+			name: "empty transaction",
+			before: []write{
+				{typ: TypeChunk},
+				{typ: TypeChunk, blocks: []string{"a"}},
+			},
+			want: []string{"chunk:a"},
+		},
+		{
+			name: "starts from latest snapshot",
+			before: []write{
+				{typ: TypeChunk, blocks: []string{"a"}},
+				{typ: TypeSnapshot, blocks: []string{"ss"}},
+				{typ: TypeChunk, blocks: []string{"b"}},
+			},
+			want: []string{"snapshot:ss", "chunk:b"},
+		},
+		{
+			name: "snapshot after processing",
+			before: []write{
+				{typ: TypeChunk, blocks: []string{"a"}},
+			},
+			after: []write{
+				{typ: TypeSnapshot, blocks: []string{"ss"}},
+				{typ: TypeChunk, blocks: []string{"b"}},
+			},
+			want: []string{"chunk:a", "snapshot:ss", "chunk:b"},
+		},
+		{
+			name: "retry after failure",
+			before: []write{
+				{typ: TypeChunk, blocks: []string{"a", "b", "c"}},
+			},
+			failAt: "b",
+			want:   []string{"chunk:a", "chunk:a", "chunk:b", "chunk:c"},
+		},
+		{
+			name: "resume after failure",
+			before: []write{
+				{typ: TypeChunk, blocks: []string{"a", "b", "c"}},
+			},
+			failAt:           "b",
+			blockCheckpoints: true,
+			want:             []string{"chunk:a", "chunk:b", "chunk:c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewMemoryLedger("test")
+			defer l.Close()
+
+			writeAll := func(writes []write) {
+				for _, wr := range writes {
+					fn := func(w interface{ Write([]byte) error }) (err error) {
+						for _, block := range wr.blocks {
+							if err = w.Write([]byte(block)); err != nil {
+								return
+							}
+						}
+
+						return
+					}
+
+					var err error
+					if wr.typ == TypeSnapshot {
+						err = l.Snapshot(func(ss *Snapshot) error { return fn(ss) })
+					} else {
+						err = l.Transaction(func(txn *Transaction) error { return fn(txn) })
+					}
+
+					if err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			var (
+				got    []string
+				failed bool
+			)
+
+			onUpdate := func(typ Type, r *Reader) error {
+				return r.ForEach(0, func(b Block) error {
+					if string(b) == tt.failAt && !failed {
+						failed = true
+						return fmt.Errorf("failure")
+					}
+
+					got = append(got, fmt.Sprintf("%s:%s", typ, b))
+					return nil
+				})
+			}
+
+			c := NewMemoryConsumer(l, onUpdate)
+			if tt.blockCheckpoints {
+				c = NewMemoryConsumerWithBlockCheckpoints(l, onUpdate)
+			}
+			defer c.Close()
+
+			writeAll(tt.before)
+			if err := c.Sync(); err != nil && !failed {
+				t.Fatal(err)
+			}
+
+			writeAll(tt.after)
+			if err := c.Sync(); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("invalid blocks, expected %v and received %v", tt.want, got)
+			}
+
+			stats, err := c.Stats()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if stats.QueueDepth != 0 || stats.Lag != 0 {
+				t.Fatalf("invalid stats, expected an empty queue and received %+v", stats)
+			}
+		})
+	}
+}
+
+func TestMemoryLedger_BatchBlock(t *testing.T) {
+	l := NewMemoryLedger("test")
+	if err := l.BatchBlock([]byte("hello world")); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	c := NewMemoryConsumer(l, func(typ Type, r *Reader) error {
+		return r.ForEach(0, func(b Block) error {
+			got = append(got, string(b))
+			return nil
+		})
+	})
+
+	if err := c.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"hello world"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("invalid blocks, expected %v and received %v", want, got)
+	}
+
+	meta, err := l.Meta()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if filenames := l.Filenames(); len(filenames) != 1 || filenames[0] != makeFilename("test", meta.LastProcessedTimestamp, TypeChunk).String() {
+		t.Fatalf("invalid filenames, received %v for meta %+v", filenames, meta)
+	}
+
+	if err = l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = l.Transaction(func(txn *Transaction) error { return nil }); err != errors.ErrIsClosed {
+		t.Fatalf("invalid error, expected %v and received %v", errors.ErrIsClosed, err)
+	}
+}
@@ -7,23 +7,13 @@ var (
 	metaSize  = int64(unsafe.Sizeof(Meta{}))
 )
 
-func makeMetaFromFilename(filename string) (m Meta, err error) {
-	var parsed Filename
-	if parsed, err = ParseFilename(filename); err != nil {
-		return
-	}
-
-	m.LastProcessedTimestamp = parsed.CreatedAt
-	m.LastProcessedType = parsed.Filetype
-	return
-}
-
 func newMetaFromBytes(bs []byte) *Meta {
 	// Associate meta with provided bytes
 	return (*Meta)(unsafe.Pointer(&bs[0]))
 }
 
 // Meta represents the historical meta data
+// Note: New fields must be appended, as Meta is memory mapped
 type Meta struct {
 	// LastProcessedTimestamp is the last processed timestamp
 	LastProcessedTimestamp int64 `json:"lastProcessedTimestamp"`
@@ -31,8 +21,78 @@ type Meta struct {
 
 	LastDownloadedTimestamp int64 `json:"lastDownloadedTimestamp"`
 	LastDownloadedType      Type  `json:"lastDownloadedType"`
+
+	// LastAppliedTimestamp and LastAppliedType identify the chunk currently (or most recently) being applied
+	LastAppliedTimestamp int64 `json:"lastAppliedTimestamp"`
+	LastAppliedType      Type  `json:"lastAppliedType"`
+	// LastAppliedBlockIndex is the index of the last block successfully applied within the chunk
+	// Note: This value is only valid when the LastAppliedTimestamp and LastAppliedType match the chunk
+	LastAppliedBlockIndex int64 `json:"lastAppliedBlockIndex"`
+
+	// Producer IDs and sequences of the last processed, downloaded, and applied files
+	// Note: These are empty for legacy filenames
+	LastProcessedProducerID  ProducerID `json:"lastProcessedProducerID"`
+	LastProcessedSequence    uint64     `json:"lastProcessedSequence"`
+	LastDownloadedProducerID ProducerID `json:"lastDownloadedProducerID"`
+	LastDownloadedSequence   uint64     `json:"lastDownloadedSequence"`
+	LastAppliedProducerID    ProducerID `json:"lastAppliedProducerID"`
+	LastAppliedSequence      uint64     `json:"lastAppliedSequence"`
+
+	// ProducerID is the ID used by a Producer when naming chunks and snapshots
+	ProducerID ProducerID `json:"producerID"`
+	// Sequence is the sequence number of the last chunk or snapshot committed by a Producer
+	Sequence uint64 `json:"sequence"`
+	// LastCreatedAt is the last timestamp issued by the hybrid logical clock of a Producer
+	LastCreatedAt int64 `json:"lastCreatedAt"`
+	// LastSnapshotTimestamp is the timestamp of the newest snapshot queued by a Consumer, late
+	// arrivals created before the snapshot are not downloaded
+	LastSnapshotTimestamp int64 `json:"lastSnapshotTimestamp"`
+	// LastFencingToken is the highest lease fencing token of the files applied by a Consumer
+	LastFencingToken uint64 `json:"lastFencingToken"`
 }
 
 func (m *Meta) IsEmpty() bool {
 	return *m == emptyMeta
 }
+
+// lastProcessed will return the filename of the last processed file
+func (m *Meta) lastProcessed(name string) Filename {
+	return makeSequencedFilename(name, m.LastProcessedTimestamp, m.LastProcessedProducerID, m.LastProcessedSequence, m.LastProcessedType)
+}
+
+func (m *Meta) setProcessed(f Filename) {
+	m.LastProcessedTimestamp = f.CreatedAt
+	m.LastProcessedType = f.Filetype
+	m.LastProcessedProducerID = f.ProducerID
+	m.LastProcessedSequence = f.Sequence
+}
+
+func (m *Meta) setDownloaded(f Filename) {
+	m.LastDownloadedTimestamp = f.CreatedAt
+	m.LastDownloadedType = f.Filetype
+	m.LastDownloadedProducerID = f.ProducerID
+	m.LastDownloadedSequence = f.Sequence
+}
+
+func (m *Meta) setApplied(f Filename, blockIndex int64) {
+	m.LastAppliedTimestamp = f.CreatedAt
+	m.LastAppliedType = f.Filetype
+	m.LastAppliedProducerID = f.ProducerID
+	m.LastAppliedSequence = f.Sequence
+	m.LastAppliedBlockIndex = blockIndex
+}
+
+// nextBlockIndex will return the index of the next block to apply for the provided chunk
+func (m *Meta) nextBlockIndex(filename Filename) int64 {
+	switch {
+	case m.LastAppliedTimestamp != filename.CreatedAt:
+	case m.LastAppliedType != filename.Filetype:
+	case m.LastAppliedProducerID != filename.ProducerID:
+	case m.LastAppliedSequence != filename.Sequence:
+	default:
+		return m.LastAppliedBlockIndex + 1
+	}
+
+	// Checkpoint belongs to another chunk, start from the first block
+	return 0
+}
@@ -53,3 +53,70 @@ func TestMeta_IsEmpty(t *testing.T) {
 		})
 	}
 }
+
+func TestMeta_nextBlockIndex(t *testing.T) {
+	type testcase struct {
+		name     string
+		meta     Meta
+		filename Filename
+		want     int64
+	}
+
+	tests := []testcase{
+		{
+			name:     "empty",
+			filename: makeFilename("test", 100, TypeChunk),
+			want:     0,
+		},
+		{
+			name: "matching chunk",
+			meta: Meta{
+				LastAppliedTimestamp:  100,
+				LastAppliedType:       TypeChunk,
+				LastAppliedBlockIndex: 4,
+			},
+			filename: makeFilename("test", 100, TypeChunk),
+			want:     5,
+		},
+		{
+			name: "different chunk",
+			meta: Meta{
+				LastAppliedTimestamp:  100,
+				LastAppliedType:       TypeChunk,
+				LastAppliedBlockIndex: 4,
+			},
+			filename: makeFilename("test", 200, TypeChunk),
+			want:     0,
+		},
+		{
+			name: "different type",
+			meta: Meta{
+				LastAppliedTimestamp:  100,
+				LastAppliedType:       TypeChunk,
+				LastAppliedBlockIndex: 4,
+			},
+			filename: makeFilename("test", 100, TypeSnapshot),
+			want:     0,
+		},
+		{
+			name: "different producer",
+			meta: Meta{
+				LastAppliedTimestamp:  100,
+				LastAppliedType:       TypeChunk,
+				LastAppliedProducerID: 1,
+				LastAppliedSequence:   1,
+				LastAppliedBlockIndex: 4,
+			},
+			filename: makeSequencedFilename("test", 100, 2, 1, TypeChunk),
+			want:     0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.meta.nextBlockIndex(tt.filename); got != tt.want {
+				t.Errorf("Meta.nextBlockIndex() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package kiroku
 import (
 	"io"
 	"io/fs"
+	"sync"
 	"time"
 )
 
@@ -43,3 +44,69 @@ func (m *mockFileInfo) IsDir() bool {
 func (m *mockFileInfo) Sys() any {
 	return nil
 }
+
+var _ clock = &fakeClock{}
+
+func newFakeClock(now time.Time) *fakeClock {
+	var f fakeClock
+	f.now = now
+	return &f
+}
+
+// fakeClock is a clock which only moves forward when advanced
+type fakeClock struct {
+	mux sync.Mutex
+
+	now     time.Time
+	waiters []fakeClockWaiter
+}
+
+type fakeClockWaiter struct {
+	deadline time.Time
+	ch       chan time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) After(d time.Duration) <-chan time.Time {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- f.now
+		return ch
+	}
+
+	f.waiters = append(f.waiters, fakeClockWaiter{deadline: f.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance will move the clock forward and notify any expired waiters
+func (f *fakeClock) Advance(d time.Duration) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.now = f.now.Add(d)
+
+	waiters := f.waiters[:0]
+	for _, w := range f.waiters {
+		if w.deadline.After(f.now) {
+			waiters = append(waiters, w)
+			continue
+		}
+
+		w.ch <- f.now
+	}
+
+	f.waiters = waiters
+}
+
+// Waiters will return the number of pending waiters
+func (f *fakeClock) Waiters() int {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	return len(f.waiters)
+}
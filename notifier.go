@@ -0,0 +1,86 @@
+package kiroku
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hatchify/errors"
+)
+
+// ErrWatchUnsupported is returned when watching a directory on a platform without inotify
+const ErrWatchUnsupported = errors.Error("watching a directory is only supported on linux")
+
+// watchOverflow is sent by watchDir when notifications have been dropped (E.g. the inotify
+// queue overflowed), the directory must be listed again to find the files which were missed
+const watchOverflow = ""
+
+func newNotifier() *notifier {
+	var n notifier
+	n.ch = make(chan struct{})
+	return &n
+}
+
+// notifier wakes waiting scanners when new files are available
+// Note: A nil notifier will always wait for the full delay
+type notifier struct {
+	mux sync.Mutex
+
+	// Generation is incremented for each notification
+	gen uint64
+	// Channel which is closed on the next notification
+	ch chan struct{}
+}
+
+// Generation will return the current notification generation
+func (n *notifier) Generation() uint64 {
+	if n == nil {
+		return 0
+	}
+
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	return n.gen
+}
+
+// Notify will wake all waiters
+func (n *notifier) Notify() {
+	if n == nil {
+		return
+	}
+
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	n.gen++
+	close(n.ch)
+	n.ch = make(chan struct{})
+}
+
+// Wait will wait until a notification has occurred since the provided generation,
+// the delay has elapsed, or the context is done
+func (n *notifier) Wait(ctx context.Context, gen uint64, delay time.Duration) (err error) {
+	if n == nil {
+		return sleep(ctx, delay)
+	}
+
+	n.mux.Lock()
+	if n.gen != gen {
+		// Notified since the generation, return
+		n.mux.Unlock()
+		return
+	}
+
+	ch := n.ch
+	n.mux.Unlock()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-ch:
+	case <-timer.C:
+	}
+
+	return
+}
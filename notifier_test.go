@@ -0,0 +1,46 @@
+package kiroku
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_notifier_Wait(t *testing.T) {
+	n := newNotifier()
+	ctx := context.Background()
+
+	// Notifications since the generation return immediately
+	gen := n.Generation()
+	n.Notify()
+	start := time.Now()
+	if err := n.Wait(ctx, gen, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	// Waiters are woken by notifications
+	gen = n.Generation()
+	go func() {
+		time.Sleep(time.Millisecond * 10)
+		n.Notify()
+	}()
+
+	if err := n.Wait(ctx, gen, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("invalid wait duration, expected less than a second and received %v", elapsed)
+	}
+
+	// Waiters are woken once the delay has elapsed
+	if err := n.Wait(ctx, n.Generation(), time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := n.Wait(cctx, n.Generation(), time.Minute); err != context.Canceled {
+		t.Fatalf("invalid error, expected <%v> and received <%v>", context.Canceled, err)
+	}
+}
@@ -0,0 +1,74 @@
+//go:build linux
+
+package kiroku
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// watchDir will notify of the files written or moved into a directory using inotify
+// Note: The channel is closed once the context is done, watchOverflow is sent when events were dropped
+func watchDir(ctx context.Context, dir string) (filenames <-chan string, err error) {
+	var fd int
+	if fd, err = syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK); err != nil {
+		err = fmt.Errorf("error initializing inotify: %v", err)
+		return
+	}
+
+	// Files are either written in place or moved into the directory
+	if _, err = syscall.InotifyAddWatch(fd, dir, syscall.IN_CLOSE_WRITE|syscall.IN_MOVED_TO); err != nil {
+		syscall.Close(fd)
+		err = fmt.Errorf("error watching <%s>: %v", dir, err)
+		return
+	}
+
+	// Note: The file descriptor is non-blocking, so closing the file will interrupt reads
+	f := os.NewFile(uintptr(fd), "inotify")
+	ch := make(chan string)
+	go func() {
+		<-ctx.Done()
+		f.Close()
+	}()
+
+	go readInotify(ctx, f, ch)
+	filenames = ch
+	return
+}
+
+func readInotify(ctx context.Context, f *os.File, ch chan<- string) {
+	defer close(ch)
+	buf := make([]byte, (syscall.SizeofInotifyEvent+syscall.NAME_MAX+1)*16)
+	for {
+		n, err := f.Read(buf)
+		if err != nil {
+			// File has been closed, return
+			return
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			offset += syscall.SizeofInotifyEvent
+			// Name is padded with null bytes
+			name := string(bytes.TrimRight(buf[offset:offset+int(event.Len)], "\x00"))
+			offset += int(event.Len)
+			switch {
+			case event.Mask&syscall.IN_Q_OVERFLOW != 0:
+				// Events were dropped by the kernel, signal that the directory must be listed again
+				name = watchOverflow
+			case len(name) == 0:
+				continue
+			}
+
+			select {
+			case ch <- name:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,44 @@
+//go:build linux
+
+package kiroku
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+func Test_readInotify_overflow(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := make(chan string)
+	go readInotify(ctx, r, ch)
+	defer r.Close()
+
+	// Write an overflow event, which has no name
+	var event syscall.InotifyEvent
+	event.Wd = -1
+	event.Mask = syscall.IN_Q_OVERFLOW
+	bs := (*[syscall.SizeofInotifyEvent]byte)(unsafe.Pointer(&event))[:]
+	if _, err = w.Write(bs); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case name := <-ch:
+		if name != watchOverflow {
+			t.Fatalf("invalid name, expected overflow and received <%s>", name)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("timed out waiting for overflow notification")
+	}
+}
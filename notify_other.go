@@ -0,0 +1,10 @@
+//go:build !linux
+
+package kiroku
+
+import "context"
+
+// watchDir is unsupported on this platform
+func watchDir(ctx context.Context, dir string) (filenames <-chan string, err error) {
+	return nil, ErrWatchUnsupported
+}
@@ -0,0 +1,81 @@
+package kiroku
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// PhaseCommit is the writing and closing of a transaction or snapshot
+	PhaseCommit Phase = "commit"
+	// PhaseRename is the rename of a committed temporary file to a chunk or snapshot
+	PhaseRename Phase = "rename"
+	// PhaseExport is the export of a chunk or snapshot to the Source
+	PhaseExport Phase = "export"
+	// PhaseSnapshotPointer is the update of the latest snapshot pointer within the Source
+	PhaseSnapshotPointer Phase = "snapshot_pointer"
+	// PhaseDownload is the download of a chunk or snapshot from the Source
+	PhaseDownload Phase = "download"
+	// PhaseApply is the processing of a chunk or snapshot by the UpdateFunc
+	PhaseApply Phase = "apply"
+	// PhaseRemove is the removal of a local chunk or snapshot once it has been handled
+	PhaseRemove Phase = "remove"
+)
+
+// Phase represents a step within the lifecycle of a chunk or snapshot
+type Phase string
+
+// Observer is notified at the beginning and end of each lifecycle phase
+// Note: Observer methods are called synchronously and should not block
+type Observer interface {
+	// Begin is called before a phase begins, the returned context is provided to End
+	// and to any Source calls made during the phase
+	Begin(ctx context.Context, e Event) context.Context
+	// End is called after a phase ends, Event.Bytes, Event.Duration and Event.Err are set
+	End(ctx context.Context, e Event)
+}
+
+// Event represents a lifecycle phase of a chunk or snapshot
+type Event struct {
+	Phase Phase `json:"phase"`
+	// Stream is the full name of the stream
+	Stream   string `json:"stream"`
+	Filename string `json:"filename"`
+	Type     Type   `json:"type"`
+
+	// Bytes is the number of bytes handled during the phase, only set on End
+	Bytes int64 `json:"bytes"`
+
+	StartedAt time.Time `json:"startedAt"`
+	// Duration is the duration of the phase, only set on End
+	Duration time.Duration `json:"duration"`
+	// Err is the error encountered during the phase, only set on End
+	Err error `json:"-"`
+}
+
+// beginPhase will notify the Observer of the beginning of a phase and return a func to end it
+// Note: When the Observer is nil, the provided context is returned along with a no-op end func
+func beginPhase(ctx context.Context, o Observer, p Phase, stream string, filename Filename) (out context.Context, end func(bytes int64, err error)) {
+	if o == nil {
+		return ctx, func(int64, error) {}
+	}
+
+	var e Event
+	e.Phase = p
+	e.Stream = stream
+	e.Filename = filename.String()
+	e.Type = filename.Filetype
+	e.StartedAt = time.Now()
+	if out = o.Begin(ctx, e); out == nil {
+		out = ctx
+	}
+
+	end = func(bytes int64, err error) {
+		e.Bytes = bytes
+		e.Duration = time.Since(e.StartedAt)
+		e.Err = err
+		o.End(out, e)
+	}
+
+	return
+}
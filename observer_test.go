@@ -0,0 +1,183 @@
+package kiroku
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestObserver(t *testing.T) {
+	var err error
+	if err = os.MkdirAll("./testing_source", 0744); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll("./testing_source")
+
+	var src Source
+	if src, err = NewIOSource("./testing_source"); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		producerObserver mockObserver
+		consumerObserver mockObserver
+	)
+
+	popts := MakeOptions("./testing_producer", "test")
+	popts.Observer = &producerObserver
+	if err = os.MkdirAll(popts.Dir, 0744); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(popts.Dir)
+
+	var p *Producer
+	if p, err = NewProducer(popts, src); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = p.Transaction(func(txn *Transaction) error {
+		return txn.Write([]byte("hello world"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, func() bool { return len(producerObserver.get()) == 4 })
+
+	if err = p.Snapshot(func(ss *Snapshot) error {
+		return ss.Write([]byte("snapshot"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	wantProducer := []string{
+		"commit chunk",
+		"rename chunk",
+		"export chunk",
+		"remove chunk",
+		"commit snapshot",
+		"rename snapshot",
+		"export snapshot",
+		"snapshot_pointer snapshot",
+		"remove snapshot",
+	}
+
+	if got := producerObserver.get(); !reflect.DeepEqual(got, wantProducer) {
+		t.Fatalf("invalid producer events, expected %v and received %v", wantProducer, got)
+	}
+
+	copts := MakeOptions("./testing_consumer", "test")
+	copts.Observer = &consumerObserver
+	if err = os.MkdirAll(copts.Dir, 0744); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(copts.Dir)
+
+	if err = NewOneShotConsumer(copts, src, func(typ Type, r *Reader) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	wantConsumer := []string{
+		"download snapshot",
+		"apply snapshot",
+		"remove snapshot",
+	}
+
+	if got := consumerObserver.get(); !reflect.DeepEqual(got, wantConsumer) {
+		t.Fatalf("invalid consumer events, expected %v and received %v", wantConsumer, got)
+	}
+
+	for _, e := range append(producerObserver.events, consumerObserver.events...) {
+		if e.Stream != "test" {
+			t.Fatalf("invalid stream, expected <%s> and received <%s>", "test", e.Stream)
+		}
+
+		if e.Err != nil {
+			t.Fatalf("unexpected error for %s: %v", e.Phase, e.Err)
+		}
+
+		switch e.Phase {
+		case PhaseCommit, PhaseExport, PhaseDownload, PhaseApply:
+			if e.Bytes == 0 {
+				t.Fatalf("invalid bytes for %s, expected a non-zero value", e.Phase)
+			}
+		}
+	}
+}
+
+func TestObserver_error(t *testing.T) {
+	var err error
+	if err = os.MkdirAll("./testing_source", 0744); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll("./testing_source")
+
+	var src Source
+	if src, err = NewIOSource("./testing_source"); err != nil {
+		t.Fatal(err)
+	}
+
+	var o mockObserver
+	opts := MakeOptions("./testing", "test")
+	opts.Observer = &o
+	if err = os.MkdirAll(opts.Dir, 0744); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(opts.Dir)
+
+	var p *Producer
+	if p, err = NewProducer(opts, src); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	wantErr := fmt.Errorf("foo")
+	if err = p.Transaction(func(txn *Transaction) error {
+		return wantErr
+	}); err != wantErr {
+		t.Fatalf("invalid error, expected %v and received %v", wantErr, err)
+	}
+
+	if len(o.events) != 1 || o.events[0].Phase != PhaseCommit || o.events[0].Err != wantErr {
+		t.Fatalf("invalid events, expected a single failed commit and received %+v", o.events)
+	}
+}
+
+type mockObserverKey struct{}
+
+type mockObserver struct {
+	mux    sync.Mutex
+	events []Event
+}
+
+func (m *mockObserver) Begin(ctx context.Context, e Event) context.Context {
+	return context.WithValue(ctx, mockObserverKey{}, e.StartedAt)
+}
+
+func (m *mockObserver) End(ctx context.Context, e Event) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if startedAt, _ := ctx.Value(mockObserverKey{}).(time.Time); !startedAt.Equal(e.StartedAt) {
+		// Context was not provided from Begin, record as an error
+		e.Err = fmt.Errorf("invalid context provided to End")
+	}
+
+	m.events = append(m.events, e)
+}
+
+func (m *mockObserver) get() (out []string) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	for _, e := range m.events {
+		out = append(out, fmt.Sprintf("%s %s", e.Phase, e.Type))
+	}
+
+	return
+}
@@ -21,6 +21,8 @@ const (
 	DefaultErrorDelay = time.Second * 30
 	// DefaultBatchDuration is the default value for BatchDuration
 	DefaultBatchDuration = time.Second * 10
+	// DefaultConsumerProgressInterval is the default value for ConsumerProgressInterval
+	DefaultConsumerProgressInterval = time.Second * 10
 )
 
 // MakeOptions will create new Options
@@ -36,19 +38,121 @@ type Options struct {
 	Name      string `toml:"name" json:"name"`
 	Namespace string `toml:"namespace" json:"namespace"`
 
-	OnLog    func(message string)
+	// Logger receives all diagnostics with attributes for the stream, filename,
+	// type and attempt count (see LogKeyStream, etc). When Logger is not set,
+	// entries are formatted and provided to OnLog and OnError.
+	Logger Logger `toml:"-" json:"-"`
+
+	// Observer is notified at the beginning and end of each lifecycle phase of
+	// chunks and snapshots (e.g. commit, export, download, apply), see Phase
+	Observer Observer `toml:"-" json:"-"`
+
+	// OnLog is called with Info and Warn entries when Logger is not set
+	OnLog func(message string)
+	// OnError is called with Error entries when Logger is not set
 	OnError  func(err error)
 	OnResume func()
+	// OnSequenceGap is called when a Consumer detects missing sequence numbers
+	// from a Producer (E.g. a chunk was deleted before it was downloaded)
+	OnSequenceGap func(gap SequenceGap)
+	// OnBackpressure is called when a Producer transaction reaches the limits of
+	// the BackpressurePolicy, allowing load to be shed upstream
+	OnBackpressure func(bp Backpressure)
+	// OnEscalate is called when an error is permanent or has reached the MaxAttempts
+	// of the RetryPolicy. The Producer or Consumer stops processing (the watcher state
+	// is WatcherStateHalted) until it is closed
+	OnEscalate func(err *EscalatedError)
+	// OnQuarantine is called when a Consumer quarantines a file which repeatedly
+	// failed to apply, before the QuarantinePolicy action is taken
+	OnQuarantine func(f QuarantinedFile)
 
+	// Debugging will provide Debug entries to OnLog when Logger is not set
 	Debugging bool `toml:"debugging" json:"debugging"`
 
 	AvoidExportOnClose  bool `toml:"avoid_export_on_close" json:"avoidExportOnClose"`
 	AvoidProcessOnClose bool `toml:"avoid_merge_on_close" json:"avoidMergeOnClose"`
 
+	// ProducerID is included within the filenames of chunks and snapshots written by
+	// a Producer along with a sequence number, this prevents Producers sharing a Name
+	// from colliding (Default is a random ID which is stored within the Meta)
+	ProducerID ProducerID `toml:"producer_id" json:"producerID"`
+
+	// LocalNotify keeps the index of local files current using inotify (Linux only).
+	// This is only needed when files are written to Dir by other processes, as they
+	// are otherwise indexed by listing the directory every ErrorDelay.
+	LocalNotify bool `toml:"local_notify" json:"localNotify"`
+
+	// ConsumerID registers a Consumer's progress within the Source when set, a
+	// RetentionManager will not delete any files the Consumer has not yet passed
+	ConsumerID string `toml:"consumer_id" json:"consumerID"`
+	// ConsumerProgressInterval is the maximum frequency at which a Consumer's progress
+	// is exported, progress is also exported when the Consumer is closed
+	// (Default is DefaultConsumerProgressInterval)
+	ConsumerProgressInterval time.Duration `toml:"consumer_progress_interval" json:"consumerProgressInterval"`
+
+	// ConsumerLookback is the trailing window of time which is listed again once a
+	// Consumer reaches the end of the Source. Files within the window which became
+	// visible out of order (E.g. eventually consistent listings, slow uploads or
+	// multiple Producers) are applied, and a journal of downloaded files within the
+	// window ensures files are not applied twice (Default is disabled)
+	ConsumerLookback time.Duration `toml:"consumer_lookback" json:"consumerLookback"`
+
+	// BlockCheckpoints records the last block applied by a Consumer's UpdateFunc, so a
+	// chunk which fails is retried from the following block. Only enable this when each
+	// block is committed as it's applied, an UpdateFunc which applies a chunk atomically
+	// must receive every block when the chunk is retried (Default is false, failed chunks
+	// are retried from the first block)
+	BlockCheckpoints bool `toml:"block_checkpoints" json:"blockCheckpoints"`
+
 	ConsumerFileLimit        int64 `toml:"consumer_file_limit" json:"consumerFileLimit"`
 	ConsumerConcurrencyCount int   `toml:"consumer_concurrency_count" json:"consumerConcurrencyCount"`
 	ConsumerGetNextListSize  int64 `toml:"consumer_get_next_list_size" json:"consumerGetNextListSize"`
 
+	// ConsumerByteLimit is the maximum number of bytes of downloaded files waiting to
+	// be processed. A file is always downloaded when the queue is empty, so files
+	// larger than the limit are not blocked (Default is disabled)
+	ConsumerByteLimit int64 `toml:"consumer_byte_limit" json:"consumerByteLimit"`
+	// ConsumerMinFreeBytes is the minimum number of bytes which must remain free on
+	// the disk containing Dir after a file is downloaded (Default is disabled)
+	// Note: This is only supported on unix platforms
+	ConsumerMinFreeBytes int64 `toml:"consumer_min_free_bytes" json:"consumerMinFreeBytes"`
+
+	// Compression is the codec used to compress the blocks of chunks and snapshots
+	// written by a Producer (Default is none). Consumers detect the codec from the
+	// chunk header and will decompress blocks before they are provided to UpdateFunc.
+	Compression Compression `toml:"compression" json:"compression"`
+
+	// Codec is used to encode and decode typed values (see WriteValue and
+	// NewTypedConsumer). The codec ID is recorded within each chunk header so
+	// chunks written with another Codec are rejected (Default is raw blocks).
+	Codec Codec `toml:"-" json:"-"`
+
+	// KeyProvider enables envelope encryption of exported chunks and snapshots when
+	// set. Each exported file is encrypted with a random data key which is wrapped
+	// by the KeyProvider. Consumers require a KeyProvider which can unwrap the keys
+	// used by the Producer. Local chunk files are not encrypted.
+	KeyProvider KeyProvider `toml:"-" json:"-"`
+	// AllowUnencrypted allows Consumers with a KeyProvider to download chunks and
+	// snapshots which are not encrypted (E.g. written before encryption was enabled).
+	// Otherwise unencrypted files are rejected with ErrUnencryptedChunk, so the
+	// encryption of a file cannot be stripped (Default is false)
+	AllowUnencrypted bool `toml:"allow_unencrypted" json:"allowUnencrypted"`
+
+	// SnapshotPolicy determines when a Producer will automatically write a snapshot
+	// using SnapshotFunc (Default is manual snapshots only)
+	SnapshotPolicy SnapshotPolicy `toml:"snapshot_policy" json:"snapshotPolicy"`
+	// SnapshotFunc is called to write the contents of an automatic snapshot
+	SnapshotFunc SnapshotFunc `toml:"-" json:"-"`
+
+	// Lease enables single-writer fencing, a Producer must hold the lease for it's
+	// Name within the Source to write transactions and snapshots (Default is disabled)
+	Lease LeasePolicy `toml:"lease" json:"lease"`
+
+	// Backpressure limits the chunks and snapshots waiting to be exported, once a
+	// limit is reached transactions wait for exports to catch up or fail with
+	// ErrBackpressure (Default is disabled)
+	Backpressure BackpressurePolicy `toml:"backpressure" json:"backpressure"`
+
 	// BatchDuration represents the amount of time to keep a transaction open for a
 	// Batch operation
 	BatchDuration time.Duration `toml:"batch_duration" json:"batchDuration"`
@@ -60,6 +164,13 @@ type Options struct {
 	// ErrorDelay represents the amount of time to wait before pulling "Next" after
 	// receiving an error
 	ErrorDelay time.Duration `toml:"error_delay" json:"errorDelay"`
+	// Retry determines the backoff between attempts after an error, and when errors
+	// are escalated rather than retried (Default is to retry every ErrorDelay)
+	Retry RetryPolicy `toml:"retry" json:"retry"`
+	// Quarantine determines when a Consumer moves a file which repeatedly fails to
+	// apply into the _quarantine subdirectory of Dir (Default is disabled)
+	// Note: A file is quarantined before its errors are escalated by the RetryPolicy
+	Quarantine QuarantinePolicy `toml:"quarantine" json:"quarantine"`
 
 	// RangeStart will determine the moment in time from which syncs will begin
 	RangeStart time.Time `toml:"range_start" json:"rangeStart"`
@@ -80,6 +191,17 @@ func (o *Options) Validate() (err error) {
 		errs.Push(ErrEmptyName)
 	}
 
+	errs.Push(o.Compression.Validate())
+	errs.Push(validateCodec(o.Codec))
+	errs.Push(o.SnapshotPolicy.Validate())
+	errs.Push(o.Lease.Validate())
+	errs.Push(o.Backpressure.Validate())
+	errs.Push(o.Retry.Validate())
+	errs.Push(o.Quarantine.Validate())
+	if !o.SnapshotPolicy.IsEmpty() && o.SnapshotFunc == nil {
+		errs.Push(ErrNilSnapshotFunc)
+	}
+
 	o.fill()
 	return errs.Err()
 }
@@ -105,6 +227,10 @@ func (o *Options) fill() {
 		o.BatchDuration = DefaultBatchDuration
 	}
 
+	if o.ConsumerProgressInterval == 0 {
+		o.ConsumerProgressInterval = DefaultConsumerProgressInterval
+	}
+
 	if o.OnLog == nil {
 		o.OnLog = func(string) {}
 	}
@@ -113,6 +239,10 @@ func (o *Options) fill() {
 		o.OnError = func(error) {}
 	}
 
+	if o.Logger == nil {
+		o.Logger = newCallbackLogger(o.OnLog, o.OnError, o.Debugging)
+	}
+
 	if o.ConsumerConcurrencyCount <= 0 {
 		o.ConsumerConcurrencyCount = 1
 	}
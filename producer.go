@@ -3,12 +3,13 @@ package kiroku
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"path"
 	"path/filepath"
 	"strings"
 	"sync"
-	"time"
+	"sync/atomic"
 
 	"github.com/hatchify/errors"
 )
@@ -25,13 +26,21 @@ func NewProducer(o Options, src Source) (kp *Producer, err error) {
 // NewWithContext will initialize a new Producer instance with a provided context.Context
 // Note: Processor and Options are optional
 func NewProducerWithContext(ctx context.Context, o Options, src Source) (kp *Producer, err error) {
+	return newProducer(ctx, o, src, realClock{})
+}
+
+func newProducer(ctx context.Context, o Options, src Source, c clock) (kp *Producer, err error) {
 	if err = o.Validate(); err != nil {
 		return
 	}
 
 	var p Producer
+	// Record errors for Stats
+	o.Logger = withErrorStats(&p.errs, o.Logger)
 	// Set options
 	p.opts = o
+	// Set logger
+	p.log = newLogger(o.Logger, o.FullName())
 	// Set directory as a cleaned version of the provided directory
 	p.opts.Dir = filepath.Clean(p.opts.Dir)
 	// Initialize cancel context with the provided context as the parent
@@ -46,8 +55,20 @@ func NewProducerWithContext(ctx context.Context, o Options, src Source) (kp *Pro
 		return
 	}
 
+	if err = p.acquireLease(c); err != nil {
+		p.cancelFn()
+		_ = p.m.Close()
+		return
+	}
+
+	p.h = newHybridClock(p.m, c, p.log)
 	p.w = newWatcher(p.ctx, o, p.exportAndRemove, TypeChunk, TypeSnapshot)
 	p.b = newBatcher(p.opts.BatchDuration, p.Transaction)
+	p.s = newSnapshotter(p.opts.SnapshotPolicy, c)
+	if !p.opts.SnapshotPolicy.IsEmpty() {
+		go p.watchSnapshotPolicy()
+	}
+
 	kp = &p
 	return
 }
@@ -63,6 +84,7 @@ type Producer struct {
 
 	// Producer options
 	opts Options
+	log  logger
 
 	src       Source
 	hasSource bool
@@ -70,12 +92,30 @@ type Producer struct {
 	m *mappedMeta
 	w *watcher
 	b *batcher
+	s *snapshotter
+	h *hybridClock
+	// Note: This field is nil when the LeasePolicy is empty
+	l *leaser
+
+	// Stats counters
+	errs         errorStats
+	exported     counter
+	transactions int64
+	snapshots    int64
+	// Number of transactions which reached the backpressure limits
+	backpressured int64
+	// Timestamp of the last exported file
+	lastExported int64
 }
 
 // Transaction will engage a new history transaction
 func (p *Producer) Transaction(fn TransactionFn) (err error) {
-	p.mux.Lock()
+	// Ensure exports have not fallen behind
+	if err = p.lockWithinBackpressure(); err != nil {
+		return
+	}
 	defer p.mux.Unlock()
+
 	// Check to see if Producer is closed
 	if isClosed(p.ctx) {
 		return errors.ErrIsClosed
@@ -101,14 +141,7 @@ func (p *Producer) Snapshot(fn func(*Snapshot) error) (err error) {
 		return errors.ErrIsClosed
 	}
 
-	txnFn := func(w *Writer) (err error) {
-		// Initialize snapshot
-		ss := newSnapshot(w)
-		// Call provided function
-		return fn(ss)
-	}
-
-	return p.transaction(TypeSnapshot, txnFn)
+	return p.snapshot(fn)
 }
 
 // Batch will engage a new history batch transaction
@@ -135,6 +168,52 @@ func (p *Producer) Meta() (meta Meta, err error) {
 	return
 }
 
+// Lease will return the current Lease of the Producer
+// Note: An empty Lease is returned when the LeasePolicy is empty
+func (p *Producer) Lease() (lease Lease, err error) {
+	if isClosed(p.ctx) {
+		err = errors.ErrIsClosed
+		return
+	}
+
+	if p.l == nil {
+		return
+	}
+
+	lease = p.l.Lease()
+	return
+}
+
+// Stats will return the current ProducerStats
+func (p *Producer) Stats() (stats ProducerStats, err error) {
+	if isClosed(p.ctx) {
+		err = errors.ErrIsClosed
+		return
+	}
+
+	var n int
+	if n, err = p.w.Len(); err != nil {
+		err = fmt.Errorf("error counting queued files: %v", err)
+		return
+	}
+
+	stats.QueueDepth = int64(n)
+	if stats.QueueBytes, err = p.w.Size(); err != nil {
+		err = fmt.Errorf("error getting queued bytes: %v", err)
+		return
+	}
+
+	stats.Transactions = atomic.LoadInt64(&p.transactions)
+	stats.Snapshots = atomic.LoadInt64(&p.snapshots)
+	stats.FilesExported, stats.BytesExported = p.exported.get()
+	stats.LastExportedTimestamp = atomic.LoadInt64(&p.lastExported)
+	stats.ErrorCount, stats.LastError, stats.LastErrorAt = p.errs.get()
+	stats.RetryCount = p.w.Retries()
+	stats.WatcherState = p.w.State()
+	stats.Backpressured = atomic.LoadInt64(&p.backpressured)
+	return
+}
+
 // Close will close the selected instance of Producer
 func (p *Producer) Close() (err error) {
 	p.mux.Lock()
@@ -159,9 +238,142 @@ func (p *Producer) Close() (err error) {
 		errs.Push(p.w.processAll())
 	}
 
+	if p.l != nil {
+		// Release the lease so another Producer can acquire it immediately
+		errs.Push(p.l.Release(context.Background()))
+	}
+
 	return errs.Err()
 }
 
+func (p *Producer) snapshot(fn func(*Snapshot) error) (err error) {
+	txnFn := func(w *Writer) (err error) {
+		// Initialize snapshot
+		ss := newSnapshot(w)
+		// Call provided function
+		return fn(ss)
+	}
+
+	if err = p.transaction(TypeSnapshot, txnFn); err != nil {
+		return
+	}
+
+	// Reset the snapshot policy counters
+	p.s.onSnapshot()
+	return
+}
+
+// watchSnapshotPolicy will write snapshots whenever the snapshot policy is due
+func (p *Producer) watchSnapshotPolicy() {
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-p.s.dueCh:
+		case <-p.s.next():
+		}
+
+		if !p.s.IsDue() {
+			continue
+		}
+
+		if err := p.autoSnapshot(); err != nil {
+			p.log.error("Producer.watchSnapshotPolicy(): error writing snapshot", LogKeyType, TypeSnapshot, LogKeyError, err)
+		}
+	}
+}
+
+func (p *Producer) autoSnapshot() (err error) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if isClosed(p.ctx) {
+		// Producer closed while waiting for the lock, return
+		return
+	}
+
+	p.log.info("snapshot policy reached, writing snapshot", LogKeyType, TypeSnapshot)
+	return p.snapshot(p.opts.SnapshotFunc)
+}
+
+// lockWithinBackpressure will lock the Producer once the files waiting to be exported
+// are within the BackpressurePolicy, ErrBackpressure is returned when the Timeout has elapsed
+// Note: The Producer is not locked while waiting, so Close and Snapshot are not blocked
+func (p *Producer) lockWithinBackpressure() (err error) {
+	policy := p.opts.Backpressure
+	if policy.IsEmpty() {
+		p.mux.Lock()
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(p.ctx, policy.Timeout)
+	defer cancel()
+
+	var reported bool
+	for {
+		// Removals after this point will wake the following wait
+		gen := p.w.n.Generation()
+		var bp Backpressure
+		if bp, err = p.getBackpressure(); err != nil {
+			return
+		}
+
+		if !policy.isExceeded(bp) {
+			p.mux.Lock()
+			// Check again while locked, as another transaction may have reached the limit
+			if bp, err = p.getBackpressure(); err != nil {
+				p.mux.Unlock()
+				return
+			}
+
+			if !policy.isExceeded(bp) {
+				return
+			}
+
+			p.mux.Unlock()
+			continue
+		}
+
+		if !reported {
+			reported = true
+			bp.Blocking = policy.Timeout > 0
+			p.onBackpressure(bp)
+		}
+
+		switch err = p.w.n.Wait(ctx, gen, p.opts.ErrorDelay); {
+		case err == nil:
+		case isClosed(p.ctx):
+			return errors.ErrIsClosed
+		default:
+			return ErrBackpressure
+		}
+	}
+}
+
+func (p *Producer) getBackpressure() (bp Backpressure, err error) {
+	var n int
+	if n, err = p.w.Len(); err != nil {
+		err = fmt.Errorf("error counting queued files: %v", err)
+		return
+	}
+
+	bp.PendingFiles = int64(n)
+	if bp.PendingBytes, err = p.w.Size(); err != nil {
+		err = fmt.Errorf("error getting queued bytes: %v", err)
+		return
+	}
+
+	return
+}
+
+func (p *Producer) onBackpressure(bp Backpressure) {
+	atomic.AddInt64(&p.backpressured, 1)
+	p.log.warn("backpressure limit reached, exports are behind", "pending_files", bp.PendingFiles, "pending_bytes", bp.PendingBytes, "blocking", bp.Blocking)
+	if p.opts.OnBackpressure != nil {
+		// Note: The hook is called within a goroutine so a slow hook does not extend the wait
+		go p.opts.OnBackpressure(bp)
+	}
+}
+
 func (p *Producer) rename(f Filename, t Type) (err error) {
 	newName := f
 	newName.Filetype = t
@@ -184,6 +396,30 @@ func (p *Producer) export(filename Filename) (err error) {
 		return
 	}
 
+	// Ensure the lease is held so files are not interleaved with another Producer
+	if err = p.l.Validate(); err != nil {
+		return
+	}
+
+	var n int64
+	ctx, end := beginPhase(context.Background(), p.opts.Observer, PhaseExport, p.opts.FullName(), filename)
+	n, err = p.exportFile(ctx, filename)
+	end(n, err)
+	if err != nil {
+		return
+	}
+
+	if filename.Filetype != TypeSnapshot {
+		return
+	}
+
+	ctx, end = beginPhase(context.Background(), p.opts.Observer, PhaseSnapshotPointer, p.opts.FullName(), filename)
+	err = p.setLatestSnapshot(ctx, filename)
+	end(int64(len(filename.String())), err)
+	return
+}
+
+func (p *Producer) exportFile(ctx context.Context, filename Filename) (n int64, err error) {
 	var f *os.File
 	filepath := path.Join(p.opts.Dir, filename.String())
 	if f, err = os.Open(filepath); err != nil {
@@ -192,27 +428,44 @@ func (p *Producer) export(filename Filename) (err error) {
 	}
 	defer f.Close()
 
-	var newFilename string
-	if newFilename, err = p.src.Export(context.Background(), p.opts.FullName(), filename.String(), f); err != nil {
-		err = fmt.Errorf("error exporting <%s>: %v", filename.String(), err)
+	var info os.FileInfo
+	if info, err = f.Stat(); err != nil {
+		err = fmt.Errorf("error getting info for <%s>: %v", filename, err)
 		return
 	}
 
-	var m Meta
-	if m, err = makeMetaFromFilename(newFilename); err != nil {
-		err = fmt.Errorf("error getting meta from new filename <%s>: %v", newFilename, err)
-		return
+	var r io.Reader = f
+	if p.opts.KeyProvider != nil {
+		// Encrypt the chunk as it's exported
+		if r, err = newEncrypter(ctx, p.opts.KeyProvider, f); err != nil {
+			err = fmt.Errorf("error initializing encryption for <%s>: %v", filename, err)
+			return
+		}
 	}
 
-	p.m.Set(m)
+	var newFilename string
+	if newFilename, err = p.src.Export(ctx, p.opts.FullName(), filename.String(), r); err != nil {
+		err = p.opts.Retry.annotate(fmt.Sprintf("error exporting <%s>", filename.String()), err)
+		return
+	}
 
-	if filename.Filetype != TypeSnapshot {
+	var parsed Filename
+	if parsed, err = ParseFilename(newFilename); err != nil {
+		err = fmt.Errorf("error parsing new filename <%s>: %v", newFilename, err)
 		return
 	}
 
+	p.m.SetProcessed(parsed)
+	n = info.Size()
+	p.exported.add(n)
+	atomic.StoreInt64(&p.lastExported, filename.CreatedAt)
+	return
+}
+
+func (p *Producer) setLatestSnapshot(ctx context.Context, filename Filename) (err error) {
 	rdr := strings.NewReader(filename.String())
 	snapshotName := getSnapshotName(p.opts.FullName())
-	if _, err = p.src.Export(context.Background(), "_latestSnapshots", snapshotName, rdr); err != nil {
+	if _, err = p.src.Export(ctx, LatestSnapshotsPrefix, snapshotName, rdr); err != nil {
 		err = fmt.Errorf("error setting latest snapshot: %v", err)
 		return
 	}
@@ -227,38 +480,156 @@ func (p *Producer) exportAndRemove(f Filename) (err error) {
 	}
 
 	filepath := path.Join(p.opts.Dir, f.String())
-	return os.Remove(filepath)
+	_, end := beginPhase(context.Background(), p.opts.Observer, PhaseRemove, p.opts.FullName(), f)
+	err = os.Remove(filepath)
+	end(0, err)
+	return
 }
 
 func (p *Producer) transaction(t Type, fn func(*Writer) error) (err error) {
-	// Get current timestamp
-	now := time.Now()
-	// Get Unix nano value from timestamp
-	unix := now.UnixNano()
+	if err = p.l.Validate(); err != nil {
+		return
+	}
+
+	var unix int64
+	// Get the next Unix nano timestamp, this is always after the timestamp of the previous chunk
+	if unix, err = p.h.Next(); err != nil {
+		return
+	}
+
+	var (
+		id  ProducerID
+		seq uint64
+	)
+
+	// Get the producer ID and sequence number of the chunk or snapshot
+	if id, seq, err = p.nextSequence(); err != nil {
+		return
+	}
+
 	// Set name of chunk with temporary prefix
-	name := makeFilename(p.opts.FullName(), unix, TypeTemporary)
+	name := makeSequencedFilename(p.opts.FullName(), unix, id, seq, TypeTemporary)
+	// Set name of the committed chunk or snapshot
+	filename := makeSequencedFilename(p.opts.FullName(), unix, id, seq, t)
 
 	var w *Writer
+	_, end := beginPhase(p.ctx, p.opts.Observer, PhaseCommit, p.opts.FullName(), filename)
 	// Initialize a new chunk Writer
-	if w, err = newWriter(p.opts.Dir, name); err != nil {
+	// Note: The fencing token of the lease is written to the chunk header so Consumers can reject stale writers
+	if w, err = newWriter(p.opts.Dir, name, p.opts.Compression, p.opts.Codec, p.l.Token()); err != nil {
+		end(0, err)
 		return
 	}
 
-	// Call provided function
-
-	err = fn(w)
-	_ = w.Close()
+	// Call provided function and close the Writer
+	// Note: Close errors are not ignored, as a chunk without a trailer is considered truncated
+	err = handleTwoErrors(fn(w), w.Close())
+	end(w.offset, err)
 	if err != nil || w.blockCount == 0 {
 		_ = os.Remove(w.filepath)
 		return
 	}
 
+	// Consume the sequence number now that the chunk has been committed
+	// Note: Empty and failed transactions do not consume a sequence number
+	p.m.SetSequence(seq)
+
+	_, end = beginPhase(p.ctx, p.opts.Observer, PhaseRename, p.opts.FullName(), filename)
 	// Rename to chunk with
-	if err = p.rename(w.filename, t); err != nil {
+	err = p.rename(w.filename, t)
+	end(w.offset, err)
+	if err != nil {
 		return
 	}
 
+	switch t {
+	case TypeChunk:
+		atomic.AddInt64(&p.transactions, 1)
+		// Record chunk for the snapshot policy
+		p.s.onChunk(w.offset)
+	case TypeSnapshot:
+		atomic.AddInt64(&p.snapshots, 1)
+	}
+
 	// Send signal to chunk watcher
-	p.w.trigger()
+	p.w.trigger(filename)
+	return
+}
+
+// nextSequence will return the producer ID and the next sequence number
+func (p *Producer) nextSequence() (id ProducerID, seq uint64, err error) {
+	if id, err = p.producerID(); err != nil {
+		return
+	}
+
+	seq = p.m.Get().Sequence + 1
+	return
+}
+
+// producerID will return the ID of the Producer
+// Note: An ID is generated and stored within the Meta when one is not set
+func (p *Producer) producerID() (id ProducerID, err error) {
+	err = p.m.Update(func(m Meta) (out Meta, err error) {
+		switch {
+		case p.opts.ProducerID != 0:
+			m.ProducerID = p.opts.ProducerID
+		case m.ProducerID == 0:
+			if m.ProducerID, err = newProducerID(); err != nil {
+				return
+			}
+		}
+
+		id = m.ProducerID
+		out = m
+		return
+	})
+
 	return
 }
+
+func (p *Producer) acquireLease(c clock) (err error) {
+	if p.opts.Lease.IsEmpty() {
+		return
+	}
+
+	if !p.hasSource {
+		return ErrLeaseNilSource
+	}
+
+	var id ProducerID
+	if id, err = p.producerID(); err != nil {
+		return
+	}
+
+	p.l = newLeaser(p.src, p.opts.FullName(), id, p.opts.Lease, c)
+	if err = p.l.Acquire(p.ctx); err != nil {
+		return
+	}
+
+	p.log.info("lease acquired", LogKeyProducerID, id, "token", p.l.Lease().Token)
+	go p.watchLease()
+	return
+}
+
+// watchLease will renew the lease until the Producer is closed or the lease is lost
+func (p *Producer) watchLease() {
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-p.l.next():
+		}
+
+		err := p.l.Renew(p.ctx)
+		switch {
+		case err == nil:
+		case p.l.Validate() == ErrLeaseLost:
+			p.log.error("Producer.watchLease(): lease lost", LogKeyProducerID, p.l.id, LogKeyError, err)
+			return
+		case isClosed(p.ctx):
+			return
+		default:
+			p.log.warn("error renewing lease", LogKeyProducerID, p.l.id, LogKeyError, err)
+		}
+	}
+}
@@ -776,7 +776,7 @@ func TestProducer_exportAndRemove(t *testing.T) {
 
 			var w *Writer
 			if !tt.fields.avoidCreate {
-				if w, err = newWriter(tt.fields.opts.Dir, fn); err != nil {
+				if w, err = newWriter(tt.fields.opts.Dir, fn, CompressionNone, nil, 0); err != nil {
 					t.Fatal(err)
 				}
 
@@ -960,3 +960,76 @@ func TestProducer_transaction(t *testing.T) {
 		})
 	}
 }
+
+func TestProducer_sequence(t *testing.T) {
+	var err error
+	if err = os.MkdirAll("./testing_source", 0744); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll("./testing_source")
+
+	opts := MakeOptions("./testing", "test")
+	if err = os.MkdirAll(opts.Dir, 0744); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(opts.Dir)
+
+	var src *IOSource
+	if src, err = NewIOSource("./testing_source"); err != nil {
+		t.Fatal(err)
+	}
+
+	write := func() {
+		var p *Producer
+		if p, err = NewProducer(opts, src); err != nil {
+			t.Fatal(err)
+		}
+		defer p.Close()
+
+		for i := 0; i < 2; i++ {
+			if err = p.Transaction(func(txn *Transaction) error {
+				return txn.Write([]byte("hello world"))
+			}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// Empty transactions do not consume a sequence number
+		if err = p.Transaction(func(txn *Transaction) error { return nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Write with two instances to ensure the producer ID and sequence are resumed
+	write()
+	write()
+
+	var filenames []string
+	if filenames, err = src.GetNextList(context.Background(), "test", "", 10); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(filenames) != 4 {
+		t.Fatalf("invalid number of files, expected %d and received %d", 4, len(filenames))
+	}
+
+	var first Filename
+	for i, filename := range filenames {
+		var parsed Filename
+		if parsed, err = ParseFilename(filename); err != nil {
+			t.Fatal(err)
+		}
+
+		if i == 0 {
+			first = parsed
+		}
+
+		if parsed.ProducerID == 0 || parsed.ProducerID != first.ProducerID {
+			t.Fatalf("invalid producer ID, expected %v and received %v", first.ProducerID, parsed.ProducerID)
+		}
+
+		if want := uint64(i + 1); parsed.Sequence != want {
+			t.Fatalf("invalid sequence, expected %d and received %d", want, parsed.Sequence)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package kiroku
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+func newProgress(ctx context.Context, opts Options, src Source, l logger) *progress {
+	if len(opts.ConsumerID) == 0 {
+		return nil
+	}
+
+	var p progress
+	p.ctx = ctx
+	p.src = src
+	p.prefix = getConsumersPrefix(opts.FullName())
+	p.id = opts.ConsumerID
+	p.interval = opts.ConsumerProgressInterval
+	p.log = l
+	return &p
+}
+
+// progress registers the last processed file of a Consumer within the Source. Exports
+// are batched, so progress is exported at most once per interval
+// Note: The exported progress may trail the Consumer, which only delays retention. All
+// methods are safe to call on a nil progress, which does not export progress
+type progress struct {
+	mux sync.Mutex
+
+	ctx      context.Context
+	src      Source
+	prefix   string
+	id       string
+	interval time.Duration
+	log      logger
+
+	// Last processed file
+	last Filename
+	// Last exported file
+	exported Filename
+	// Timer of the pending export, nil when there is no pending export
+	t *time.Timer
+}
+
+// Set will register the last processed file, which is exported once the interval has elapsed
+func (p *progress) Set(filename Filename) {
+	if p == nil {
+		return
+	}
+
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.last = filename
+	if p.t == nil && !isClosed(p.ctx) {
+		p.t = time.AfterFunc(p.interval, p.onInterval)
+	}
+}
+
+// Flush will export the last processed file without waiting for the interval
+// Note: This is called when the Consumer is closed, once it's context has been cancelled
+func (p *progress) Flush(ctx context.Context) (err error) {
+	if p == nil {
+		return
+	}
+
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if p.t != nil {
+		p.t.Stop()
+		p.t = nil
+	}
+
+	return p.export(ctx)
+}
+
+func (p *progress) onInterval() {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.t = nil
+	if isClosed(p.ctx) {
+		// Progress is flushed when the Consumer is closed
+		return
+	}
+
+	if err := p.export(p.ctx); err != nil {
+		p.log.error("error exporting progress", LogKeyFilename, p.last.String(), LogKeyError, err)
+		// Retry on the following interval
+		p.t = time.AfterFunc(p.interval, p.onInterval)
+	}
+}
+
+func (p *progress) export(ctx context.Context) (err error) {
+	if p.last == p.exported {
+		return
+	}
+
+	rdr := strings.NewReader(p.last.String())
+	if _, err = p.src.Export(ctx, p.prefix, p.id, rdr); err != nil {
+		return
+	}
+
+	p.exported = p.last
+	return
+}
@@ -0,0 +1,80 @@
+package kiroku
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_progress(t *testing.T) {
+	var (
+		mux      sync.Mutex
+		exported []string
+	)
+
+	src := &mockSource{
+		exportFn: func(ctx context.Context, prefix, filename string, r io.Reader) (string, error) {
+			bs, err := io.ReadAll(r)
+			if err != nil {
+				return "", err
+			}
+
+			mux.Lock()
+			defer mux.Unlock()
+			exported = append(exported, string(bs))
+			return filename, nil
+		},
+	}
+
+	getExported := func() []string {
+		mux.Lock()
+		defer mux.Unlock()
+		return append([]string(nil), exported...)
+	}
+
+	opts := MakeOptions("./testing", "test")
+	opts.ConsumerID = "consumer_a"
+	opts.ConsumerProgressInterval = time.Millisecond * 50
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := newProgress(ctx, opts, src, newLogger(opts.Logger, opts.FullName()))
+
+	// Files processed within the interval are exported once
+	for i := int64(0); i < 3; i++ {
+		p.Set(makeFilename("test", 1000+i, TypeChunk))
+	}
+
+	if got := getExported(); len(got) != 0 {
+		t.Fatalf("invalid exports, expected none before the interval and received %v", got)
+	}
+
+	waitFor(t, func() bool { return len(getExported()) > 0 })
+	want := makeFilename("test", 1002, TypeChunk).String()
+	if got := getExported(); len(got) != 1 || got[0] != want {
+		t.Fatalf("invalid exports, expected [%s] and received %v", want, got)
+	}
+
+	// Closed progress is only exported when flushed
+	cancel()
+	p.Set(makeFilename("test", 1003, TypeChunk))
+	time.Sleep(opts.ConsumerProgressInterval * 2)
+	if got := getExported(); len(got) != 1 {
+		t.Fatalf("invalid exports, expected 1 and received %v", got)
+	}
+
+	if err := p.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Flushing again does not export unchanged progress
+	if err := p.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	want = makeFilename("test", 1003, TypeChunk).String()
+	if got := getExported(); len(got) != 2 || got[1] != want {
+		t.Fatalf("invalid exports, expected %s to be flushed and received %v", want, got)
+	}
+}
@@ -0,0 +1,170 @@
+package promcollector
+
+import "github.com/mojura/kiroku"
+
+var consumerMetrics = []metric[kiroku.ConsumerStats]{
+	{
+		name:  "queue_depth",
+		help:  "Number of downloaded files waiting to be processed.",
+		typ:   typeGauge,
+		value: func(s kiroku.ConsumerStats) float64 { return float64(s.QueueDepth) },
+	},
+	{
+		name:  "queue_bytes",
+		help:  "Size of the downloaded files waiting to be processed, including files being downloaded.",
+		typ:   typeGauge,
+		value: func(s kiroku.ConsumerStats) float64 { return float64(s.QueueBytes) },
+	},
+	{
+		name:  "free_bytes",
+		help:  "Free space on the disk containing the consumer directory.",
+		typ:   typeGauge,
+		value: func(s kiroku.ConsumerStats) float64 { return float64(s.FreeBytes) },
+	},
+	{
+		name:  "files_downloaded_total",
+		help:  "Number of files downloaded from the source.",
+		typ:   typeCounter,
+		value: func(s kiroku.ConsumerStats) float64 { return float64(s.FilesDownloaded) },
+	},
+	{
+		name:  "bytes_downloaded_total",
+		help:  "Number of bytes downloaded from the source.",
+		typ:   typeCounter,
+		value: func(s kiroku.ConsumerStats) float64 { return float64(s.BytesDownloaded) },
+	},
+	{
+		name:  "files_processed_total",
+		help:  "Number of files processed.",
+		typ:   typeCounter,
+		value: func(s kiroku.ConsumerStats) float64 { return float64(s.FilesProcessed) },
+	},
+	{
+		name:  "bytes_processed_total",
+		help:  "Number of bytes processed.",
+		typ:   typeCounter,
+		value: func(s kiroku.ConsumerStats) float64 { return float64(s.BytesProcessed) },
+	},
+	{
+		name:  "lag_seconds",
+		help:  "Difference between the newest source timestamp and the last processed timestamp.",
+		typ:   typeGauge,
+		value: func(s kiroku.ConsumerStats) float64 { return s.Lag.Seconds() },
+	},
+	{
+		name:  "errors_total",
+		help:  "Number of errors encountered.",
+		typ:   typeCounter,
+		value: func(s kiroku.ConsumerStats) float64 { return float64(s.ErrorCount) },
+	},
+	{
+		name:  "retries_total",
+		help:  "Number of times a previously failed file has been retried.",
+		typ:   typeCounter,
+		value: func(s kiroku.ConsumerStats) float64 { return float64(s.RetryCount) },
+	},
+	{
+		name:  "watcher_state",
+		help:  "Current watcher state (0 idle, 1 processing, 2 backoff, 3 closed, 4 halted).",
+		typ:   typeGauge,
+		value: func(s kiroku.ConsumerStats) float64 { return float64(s.WatcherState) },
+	},
+	{
+		name:  "sequence_gaps_total",
+		help:  "Number of times missing producer sequence numbers were detected.",
+		typ:   typeCounter,
+		value: func(s kiroku.ConsumerStats) float64 { return float64(s.SequenceGaps) },
+	},
+	{
+		name:  "missing_files_total",
+		help:  "Number of files skipped within producer sequence gaps.",
+		typ:   typeCounter,
+		value: func(s kiroku.ConsumerStats) float64 { return float64(s.MissingFiles) },
+	},
+	{
+		name:  "quarantined_total",
+		help:  "Number of files quarantined after repeatedly failing to apply.",
+		typ:   typeCounter,
+		value: func(s kiroku.ConsumerStats) float64 { return float64(s.Quarantined) },
+	},
+}
+
+var producerMetrics = []metric[kiroku.ProducerStats]{
+	{
+		name:  "queue_depth",
+		help:  "Number of chunks and snapshots waiting to be exported.",
+		typ:   typeGauge,
+		value: func(s kiroku.ProducerStats) float64 { return float64(s.QueueDepth) },
+	},
+	{
+		name:  "queue_bytes",
+		help:  "Size of the chunks and snapshots waiting to be exported.",
+		typ:   typeGauge,
+		value: func(s kiroku.ProducerStats) float64 { return float64(s.QueueBytes) },
+	},
+	{
+		name:  "transactions_total",
+		help:  "Number of transactions written.",
+		typ:   typeCounter,
+		value: func(s kiroku.ProducerStats) float64 { return float64(s.Transactions) },
+	},
+	{
+		name:  "snapshots_total",
+		help:  "Number of snapshots written.",
+		typ:   typeCounter,
+		value: func(s kiroku.ProducerStats) float64 { return float64(s.Snapshots) },
+	},
+	{
+		name:  "files_exported_total",
+		help:  "Number of files exported to the source.",
+		typ:   typeCounter,
+		value: func(s kiroku.ProducerStats) float64 { return float64(s.FilesExported) },
+	},
+	{
+		name:  "bytes_exported_total",
+		help:  "Number of bytes exported to the source.",
+		typ:   typeCounter,
+		value: func(s kiroku.ProducerStats) float64 { return float64(s.BytesExported) },
+	},
+	{
+		name:  "errors_total",
+		help:  "Number of errors encountered.",
+		typ:   typeCounter,
+		value: func(s kiroku.ProducerStats) float64 { return float64(s.ErrorCount) },
+	},
+	{
+		name:  "retries_total",
+		help:  "Number of times a previously failed export has been retried.",
+		typ:   typeCounter,
+		value: func(s kiroku.ProducerStats) float64 { return float64(s.RetryCount) },
+	},
+	{
+		name:  "watcher_state",
+		help:  "Current watcher state (0 idle, 1 processing, 2 backoff, 3 closed, 4 halted).",
+		typ:   typeGauge,
+		value: func(s kiroku.ProducerStats) float64 { return float64(s.WatcherState) },
+	},
+	{
+		name:  "backpressured_total",
+		help:  "Number of transactions which reached the backpressure limits.",
+		typ:   typeCounter,
+		value: func(s kiroku.ProducerStats) float64 { return float64(s.Backpressured) },
+	},
+}
+
+type metric[T any] struct {
+	name  string
+	help  string
+	typ   string
+	value func(T) float64
+}
+
+type consumerSample struct {
+	name  string
+	stats kiroku.ConsumerStats
+}
+
+type producerSample struct {
+	name  string
+	stats kiroku.ProducerStats
+}
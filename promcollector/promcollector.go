@@ -0,0 +1,167 @@
+// Package promcollector exposes kiroku Producer and Consumer stats in the Prometheus text exposition format
+package promcollector
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mojura/kiroku"
+)
+
+var (
+	_ ConsumerStatser = &kiroku.Consumer{}
+	_ ProducerStatser = &kiroku.Producer{}
+)
+
+const (
+	typeGauge   = "gauge"
+	typeCounter = "counter"
+)
+
+// ConsumerStatser is implemented by kiroku.Consumer
+type ConsumerStatser interface {
+	Stats() (kiroku.ConsumerStats, error)
+}
+
+// ProducerStatser is implemented by kiroku.Producer
+type ProducerStatser interface {
+	Stats() (kiroku.ProducerStats, error)
+}
+
+// New will initialize a new Collector
+// Note: Namespace is optional, when set all metric names are prefixed with it
+func New(namespace string) *Collector {
+	var c Collector
+	c.namespace = namespace
+	c.consumers = map[string]ConsumerStatser{}
+	c.producers = map[string]ProducerStatser{}
+	return &c
+}
+
+// Collector gathers stats from registered Producers and Consumers
+type Collector struct {
+	mux sync.RWMutex
+
+	namespace string
+
+	consumers map[string]ConsumerStatser
+	producers map[string]ProducerStatser
+}
+
+// AddConsumer will register a Consumer under the provided stream name
+func (c *Collector) AddConsumer(name string, s ConsumerStatser) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.consumers[name] = s
+}
+
+// AddProducer will register a Producer under the provided stream name
+func (c *Collector) AddProducer(name string, s ProducerStatser) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.producers[name] = s
+}
+
+// Remove will unregister the Producer and Consumer of the provided stream name
+func (c *Collector) Remove(name string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	delete(c.consumers, name)
+	delete(c.producers, name)
+}
+
+// WriteTo will write the current metrics to the provided writer
+// Note: Producers and Consumers which return an error from Stats (e.g. closed) are omitted
+func (c *Collector) WriteTo(w io.Writer) (n int64, err error) {
+	buf := bytes.NewBuffer(nil)
+	c.mux.RLock()
+	consumers := c.getConsumerStats()
+	producers := c.getProducerStats()
+	c.mux.RUnlock()
+
+	for _, m := range consumerMetrics {
+		c.writeMetric(buf, "consumer_"+m.name, m.help, m.typ, len(consumers))
+		for _, s := range consumers {
+			writeSample(buf, c.getName("consumer_"+m.name), s.name, m.value(s.stats))
+		}
+	}
+
+	for _, m := range producerMetrics {
+		c.writeMetric(buf, "producer_"+m.name, m.help, m.typ, len(producers))
+		for _, s := range producers {
+			writeSample(buf, c.getName("producer_"+m.name), s.name, m.value(s.stats))
+		}
+	}
+
+	return buf.WriteTo(w)
+}
+
+// ServeHTTP will serve the current metrics
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if _, err := c.WriteTo(w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (c *Collector) getConsumerStats() (out []consumerSample) {
+	for name, s := range c.consumers {
+		stats, err := s.Stats()
+		if err != nil {
+			continue
+		}
+
+		out = append(out, consumerSample{name: name, stats: stats})
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].name < out[j].name })
+	return
+}
+
+func (c *Collector) getProducerStats() (out []producerSample) {
+	for name, s := range c.producers {
+		stats, err := s.Stats()
+		if err != nil {
+			continue
+		}
+
+		out = append(out, producerSample{name: name, stats: stats})
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].name < out[j].name })
+	return
+}
+
+func (c *Collector) writeMetric(buf *bytes.Buffer, name, help, typ string, samples int) {
+	if samples == 0 {
+		// No samples to write, return
+		return
+	}
+
+	name = c.getName(name)
+	fmt.Fprintf(buf, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(buf, "# TYPE %s %s\n", name, typ)
+}
+
+func (c *Collector) getName(name string) string {
+	if len(c.namespace) == 0 {
+		return "kiroku_" + name
+	}
+
+	return c.namespace + "_kiroku_" + name
+}
+
+func writeSample(buf *bytes.Buffer, name, stream string, value float64) {
+	fmt.Fprintf(buf, "%s{stream=\"%s\"} %v\n", name, escapeLabel(stream), value)
+}
+
+var labelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelReplacer.Replace(value)
+}
@@ -0,0 +1,152 @@
+package promcollector
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hatchify/errors"
+	"github.com/mojura/kiroku"
+)
+
+func TestCollector_WriteTo(t *testing.T) {
+	type testcase struct {
+		name      string
+		namespace string
+		consumers map[string]ConsumerStatser
+		producers map[string]ProducerStatser
+		want      []string
+		notWant   []string
+	}
+
+	tests := []testcase{
+		{
+			name: "empty",
+			notWant: []string{
+				"# HELP",
+			},
+		},
+		{
+			name: "consumer",
+			consumers: map[string]ConsumerStatser{
+				"users": mockConsumer{stats: kiroku.ConsumerStats{QueueDepth: 3, FilesProcessed: 7, Lag: time.Second * 2}},
+			},
+			want: []string{
+				"# TYPE kiroku_consumer_queue_depth gauge\n",
+				`kiroku_consumer_queue_depth{stream="users"} 3`,
+				"# TYPE kiroku_consumer_files_processed_total counter\n",
+				`kiroku_consumer_files_processed_total{stream="users"} 7`,
+				`kiroku_consumer_lag_seconds{stream="users"} 2`,
+			},
+			notWant: []string{
+				"kiroku_producer_",
+			},
+		},
+		{
+			name:      "producer with namespace",
+			namespace: "app",
+			producers: map[string]ProducerStatser{
+				"users": mockProducer{stats: kiroku.ProducerStats{Transactions: 4, WatcherState: kiroku.WatcherStateBackoff}},
+			},
+			want: []string{
+				`app_kiroku_producer_transactions_total{stream="users"} 4`,
+				`app_kiroku_producer_watcher_state{stream="users"} 2`,
+			},
+		},
+		{
+			name: "closed consumer",
+			consumers: map[string]ConsumerStatser{
+				"users":  mockConsumer{err: errors.ErrIsClosed},
+				"orders": mockConsumer{stats: kiroku.ConsumerStats{QueueDepth: 1}},
+			},
+			want: []string{
+				`kiroku_consumer_queue_depth{stream="orders"} 1`,
+			},
+			notWant: []string{
+				`stream="users"`,
+			},
+		},
+		{
+			name: "escaped stream",
+			consumers: map[string]ConsumerStatser{
+				`a"b`: mockConsumer{},
+			},
+			want: []string{
+				`kiroku_consumer_queue_depth{stream="a\"b"} 0`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(tt.namespace)
+			for name, s := range tt.consumers {
+				c.AddConsumer(name, s)
+			}
+
+			for name, s := range tt.producers {
+				c.AddProducer(name, s)
+			}
+
+			buf := bytes.NewBuffer(nil)
+			if _, err := c.WriteTo(buf); err != nil {
+				t.Fatal(err)
+			}
+
+			out := buf.String()
+			for _, want := range tt.want {
+				if !strings.Contains(out, want) {
+					t.Fatalf("expected output to contain <%s>, received:\n%s", want, out)
+				}
+			}
+
+			for _, notWant := range tt.notWant {
+				if strings.Contains(out, notWant) {
+					t.Fatalf("expected output to not contain <%s>, received:\n%s", notWant, out)
+				}
+			}
+		})
+	}
+}
+
+func TestCollector_ServeHTTP(t *testing.T) {
+	c := New("")
+	c.AddProducer("users", mockProducer{stats: kiroku.ProducerStats{QueueDepth: 2}})
+
+	w := httptest.NewRecorder()
+	c.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+		t.Fatalf("invalid content type, received <%s>", w.Header().Get("Content-Type"))
+	}
+
+	if want := `kiroku_producer_queue_depth{stream="users"} 2`; !strings.Contains(w.Body.String(), want) {
+		t.Fatalf("expected body to contain <%s>, received:\n%s", want, w.Body.String())
+	}
+
+	c.Remove("users")
+	w = httptest.NewRecorder()
+	c.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if w.Body.Len() != 0 {
+		t.Fatalf("expected empty body after removal, received:\n%s", w.Body.String())
+	}
+}
+
+type mockConsumer struct {
+	stats kiroku.ConsumerStats
+	err   error
+}
+
+func (m mockConsumer) Stats() (kiroku.ConsumerStats, error) {
+	return m.stats, m.err
+}
+
+type mockProducer struct {
+	stats kiroku.ProducerStats
+	err   error
+}
+
+func (m mockProducer) Stats() (kiroku.ProducerStats, error) {
+	return m.stats, m.err
+}
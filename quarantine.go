@@ -0,0 +1,361 @@
+package kiroku
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hatchify/errors"
+)
+
+const (
+	// ErrInvalidQuarantinePolicy is returned when a QuarantinePolicy has negative values
+	ErrInvalidQuarantinePolicy = errors.Error("invalid quarantine policy, values cannot be negative")
+	// ErrNotQuarantined is returned when re-injecting a file which is not quarantined
+	ErrNotQuarantined = errors.Error("file is not quarantined")
+)
+
+// quarantineDir is the subdirectory of Dir which contains quarantined files
+const quarantineDir = "_quarantine"
+
+const (
+	// QuarantineSkip will continue with the following file once a file has been quarantined
+	QuarantineSkip QuarantineAction = iota
+	// QuarantineHalt will halt the Consumer once a file has been quarantined
+	QuarantineHalt
+)
+
+func parseQuarantineAction(str string) (a QuarantineAction, err error) {
+	switch str {
+	case "", "skip":
+		a = QuarantineSkip
+	case "halt":
+		a = QuarantineHalt
+	default:
+		err = fmt.Errorf("quarantine action of <%s> is not supported", str)
+	}
+
+	return
+}
+
+// QuarantineAction represents the action a Consumer takes after quarantining a file
+type QuarantineAction uint8
+
+// Validate ensures the QuarantineAction is supported
+func (q QuarantineAction) Validate() (err error) {
+	switch q {
+	case QuarantineSkip:
+	case QuarantineHalt:
+
+	default:
+		return fmt.Errorf("invalid quarantine action, <%d> is not supported", uint8(q))
+	}
+
+	return
+}
+
+func (q QuarantineAction) String() (out string) {
+	switch q {
+	case QuarantineSkip:
+		return "skip"
+	case QuarantineHalt:
+		return "halt"
+
+	default:
+		return "INVALID"
+	}
+}
+
+// MarshalText is a encoding.TextMarshaler helper func
+func (q QuarantineAction) MarshalText() (bs []byte, err error) {
+	if err = q.Validate(); err != nil {
+		return
+	}
+
+	return []byte(q.String()), nil
+}
+
+// UnmarshalText is a encoding.TextUnmarshaler helper func
+func (q *QuarantineAction) UnmarshalText(bs []byte) (err error) {
+	var val QuarantineAction
+	if val, err = parseQuarantineAction(string(bs)); err != nil {
+		return
+	}
+
+	*q = val
+	return
+}
+
+// QuarantinePolicy determines when a Consumer moves a file which repeatedly fails
+// to apply (E.g. the UpdateFunc or Reader returns an error) out of the way, so it
+// does not block the files which follow it
+type QuarantinePolicy struct {
+	// Attempts is the number of failed attempts after which a file is quarantined.
+	// Permanent errors (see RetryPolicy) are quarantined immediately (Default is disabled)
+	Attempts int64 `toml:"attempts" json:"attempts"`
+	// Action is taken after a file has been quarantined (Default is QuarantineSkip)
+	Action QuarantineAction `toml:"action" json:"action"`
+}
+
+// IsEmpty will return whether or not quarantining is enabled
+func (q QuarantinePolicy) IsEmpty() bool {
+	return q.Attempts == 0
+}
+
+// Validate ensures that the QuarantinePolicy values are valid
+func (q QuarantinePolicy) Validate() (err error) {
+	if q.Attempts < 0 {
+		return ErrInvalidQuarantinePolicy
+	}
+
+	return q.Action.Validate()
+}
+
+// QuarantinedFile is a file which has been quarantined by a Consumer
+type QuarantinedFile struct {
+	Filename Filename
+	// Reason is the error of the final failed attempt
+	Reason string
+	// Attempts is the number of failed attempts
+	Attempts int64
+	// QuarantinedAt is the time the file was quarantined
+	QuarantinedAt time.Time
+	// AppliedBlocks is the number of blocks applied before the file was quarantined, a
+	// re-injected file resumes from the following block
+	AppliedBlocks int64
+}
+
+// quarantineRecord is the JSON representation of a QuarantinedFile
+// Note: The record is retained after a file is re-injected, so the file resumes from
+// it's applied blocks. It's removed once the file has been applied
+type quarantineRecord struct {
+	Filename      string    `json:"filename"`
+	Reason        string    `json:"reason"`
+	Attempts      int64     `json:"attempts"`
+	QuarantinedAt time.Time `json:"quarantinedAt"`
+	AppliedBlocks int64     `json:"appliedBlocks"`
+}
+
+func newQuarantine(opts Options, l logger) *quarantine {
+	if opts.Quarantine.IsEmpty() {
+		// Quarantine is disabled, files do not pay for quarantine record lookups
+		return nil
+	}
+
+	var q quarantine
+	q.opts = opts
+	q.dir = path.Join(opts.Dir, quarantineDir)
+	q.log = l
+	return &q
+}
+
+// quarantine moves files which repeatedly fail to apply into the quarantine directory
+// Note: All methods are safe to call on a nil quarantine, which never quarantines files
+type quarantine struct {
+	mux sync.Mutex
+
+	opts Options
+	dir  string
+	log  logger
+
+	// Last file which failed to apply
+	last Filename
+	// Number of consecutive failed attempts for the last file
+	failures int64
+}
+
+// OnFailure will quarantine the file once it has reached the policy's attempts. When
+// the file has not been quarantined, the error is returned so the file is retried.
+// When the file has been quarantined, nil is returned to skip the file or a
+// permanent error is returned to halt the Consumer
+// Note: Applied is the number of blocks of the file which have been applied
+func (q *quarantine) OnFailure(filename Filename, applied int64, cause error) (quarantined bool, err error) {
+	if q == nil {
+		return false, cause
+	}
+
+	q.mux.Lock()
+	defer q.mux.Unlock()
+	if filename != q.last {
+		q.last = filename
+		q.failures = 0
+	}
+
+	q.failures++
+	if q.failures < q.opts.Quarantine.Attempts && q.opts.Retry.classify(cause) != ErrorPermanent {
+		return false, cause
+	}
+
+	var qf QuarantinedFile
+	qf.Filename = filename
+	qf.Reason = cause.Error()
+	qf.Attempts = q.failures
+	qf.QuarantinedAt = time.Now()
+	qf.AppliedBlocks = applied
+	if err = q.move(qf); err != nil {
+		// Unable to quarantine, the file will be retried
+		return false, fmt.Errorf("%v (error quarantining: %v)", cause, err)
+	}
+
+	q.last = Filename{}
+	q.failures = 0
+	q.log.warn("file quarantined", LogKeyFilename, filename.String(), LogKeyType, filename.Filetype, LogKeyAttempt, qf.Attempts, "action", q.opts.Quarantine.Action, LogKeyError, cause)
+	if q.opts.OnQuarantine != nil {
+		q.opts.OnQuarantine(qf)
+	}
+
+	if q.opts.Quarantine.Action == QuarantineHalt {
+		// Note: The permanent error escalates and halts the watcher, OnEscalate is called
+		return true, Permanent(fmt.Errorf("<%s> has been quarantined: %v", filename, cause))
+	}
+
+	return true, nil
+}
+
+// List will return the quarantined files in filename order
+func (q *quarantine) List() (files []QuarantinedFile, err error) {
+	if q == nil {
+		return
+	}
+
+	var entries []os.DirEntry
+	if entries, err = os.ReadDir(q.dir); os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".kir") {
+			continue
+		}
+
+		var qf QuarantinedFile
+		if qf, err = q.get(entry.Name()); err != nil {
+			return
+		}
+
+		files = append(files, qf)
+	}
+
+	return
+}
+
+// Reinject will move a quarantined file back into the directory so it is applied again
+// Note: The record is retained so the file resumes from it's applied blocks
+func (q *quarantine) Reinject(filename Filename) (err error) {
+	if q == nil {
+		return ErrNotQuarantined
+	}
+
+	name := filename.String()
+	quarantined := path.Join(q.dir, name)
+	if _, err = os.Stat(quarantined); os.IsNotExist(err) {
+		return ErrNotQuarantined
+	} else if err != nil {
+		return
+	}
+
+	if err = renameFile(quarantined, path.Join(q.opts.Dir, name)); err != nil {
+		err = fmt.Errorf("error moving <%s> out of quarantine: %v", name, err)
+		return
+	}
+
+	return nil
+}
+
+// AppliedBlocks will return the number of blocks applied before a re-injected file was
+// quarantined, 0 is returned for files which have not been quarantined
+func (q *quarantine) AppliedBlocks(filename Filename) (applied int64) {
+	if q == nil {
+		return
+	}
+
+	qf, err := q.get(filename.String())
+	if err != nil {
+		q.log.warn("error reading quarantine record", LogKeyFilename, filename.String(), LogKeyError, err)
+		return
+	}
+
+	return qf.AppliedBlocks
+}
+
+// OnApplied will remove the record of a re-injected file once it has been applied
+func (q *quarantine) OnApplied(filename Filename) (err error) {
+	if q == nil {
+		return
+	}
+
+	if err = os.Remove(q.recordPath(filename.String())); err != nil && !os.IsNotExist(err) {
+		err = fmt.Errorf("error removing quarantine record for <%s>: %v", filename, err)
+		return
+	}
+
+	return nil
+}
+
+func (q *quarantine) move(qf QuarantinedFile) (err error) {
+	if err = os.MkdirAll(q.dir, 0744); err != nil {
+		return
+	}
+
+	name := qf.Filename.String()
+	var r quarantineRecord
+	r.Filename = name
+	r.Reason = qf.Reason
+	r.Attempts = qf.Attempts
+	r.QuarantinedAt = qf.QuarantinedAt
+	r.AppliedBlocks = qf.AppliedBlocks
+
+	var bs []byte
+	if bs, err = json.Marshal(r); err != nil {
+		return
+	}
+
+	// Note: The record is written before the file is moved, so a quarantined file always
+	// retains the number of blocks which were applied
+	if err = os.WriteFile(q.recordPath(name), bs, 0744); err != nil {
+		return
+	}
+
+	if err = renameFile(path.Join(q.opts.Dir, name), path.Join(q.dir, name)); err != nil {
+		_ = os.Remove(q.recordPath(name))
+		return
+	}
+
+	return
+}
+
+func (q *quarantine) get(name string) (qf QuarantinedFile, err error) {
+	if qf.Filename, err = ParseFilename(name); err != nil {
+		return
+	}
+
+	var bs []byte
+	if bs, err = os.ReadFile(q.recordPath(name)); os.IsNotExist(err) {
+		// Record was not written, return the file without a reason
+		return qf, nil
+	} else if err != nil {
+		return
+	}
+
+	var r quarantineRecord
+	if err = json.Unmarshal(bs, &r); err != nil {
+		err = fmt.Errorf("error parsing quarantine record for <%s>: %v", name, err)
+		return
+	}
+
+	qf.Reason = r.Reason
+	qf.Attempts = r.Attempts
+	qf.QuarantinedAt = r.QuarantinedAt
+	qf.AppliedBlocks = r.AppliedBlocks
+	return
+}
+
+func (q *quarantine) recordPath(name string) string {
+	return path.Join(q.dir, name+".json")
+}
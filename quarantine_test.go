@@ -0,0 +1,332 @@
+package kiroku
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestQuarantinePolicy_Validate(t *testing.T) {
+	type testcase struct {
+		name    string
+		policy  QuarantinePolicy
+		wantErr bool
+	}
+
+	tests := []testcase{
+		{
+			name: "empty",
+		},
+		{
+			name:   "halt",
+			policy: QuarantinePolicy{Attempts: 3, Action: QuarantineHalt},
+		},
+		{
+			name:    "negative attempts",
+			policy:  QuarantinePolicy{Attempts: -1},
+			wantErr: true,
+		},
+		{
+			name:    "invalid action",
+			policy:  QuarantinePolicy{Attempts: 1, Action: 7},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.policy.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("invalid error, expected error %v and received <%v>", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestQuarantineAction_UnmarshalText(t *testing.T) {
+	type testcase struct {
+		name    string
+		text    string
+		want    QuarantineAction
+		wantErr bool
+	}
+
+	tests := []testcase{
+		{
+			name: "empty",
+			want: QuarantineSkip,
+		},
+		{
+			name: "skip",
+			text: "skip",
+			want: QuarantineSkip,
+		},
+		{
+			name: "halt",
+			text: "halt",
+			want: QuarantineHalt,
+		},
+		{
+			name:    "invalid",
+			text:    "retry",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var a QuarantineAction
+			err := a.UnmarshalText([]byte(tt.text))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("invalid error, expected error %v and received <%v>", tt.wantErr, err)
+			}
+
+			if a != tt.want {
+				t.Fatalf("invalid action, expected %v and received %v", tt.want, a)
+			}
+		})
+	}
+}
+
+func Test_quarantine(t *testing.T) {
+	type testcase struct {
+		name   string
+		policy QuarantinePolicy
+		err    error
+
+		wantAttempts int64
+		wantHalted   bool
+	}
+
+	tests := []testcase{
+		{
+			name:         "skip",
+			policy:       QuarantinePolicy{Attempts: 3},
+			err:          io.ErrUnexpectedEOF,
+			wantAttempts: 3,
+		},
+		{
+			name:         "skip permanent",
+			policy:       QuarantinePolicy{Attempts: 3},
+			err:          Permanent(fmt.Errorf("poison chunk")),
+			wantAttempts: 1,
+		},
+		{
+			name:         "halt",
+			policy:       QuarantinePolicy{Attempts: 2, Action: QuarantineHalt},
+			err:          io.ErrUnexpectedEOF,
+			wantAttempts: 2,
+			wantHalted:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := MakeOptions("./testing", "testing")
+			opts.ErrorDelay = time.Millisecond
+			opts.Quarantine = tt.policy
+			if err := os.Mkdir(opts.Dir, 0744); err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(opts.Dir)
+
+			poison := makeFilename("testing", 1, TypeChunk)
+			next := makeFilename("testing", 2, TypeChunk)
+			for _, filename := range []Filename{poison, next} {
+				f, err := os.Create(path.Join(opts.Dir, filename.String()))
+				if err != nil {
+					t.Fatal(err)
+				}
+				_ = f.Close()
+			}
+
+			quarantined := make(chan QuarantinedFile, 1)
+			opts.OnQuarantine = func(f QuarantinedFile) { quarantined <- f }
+			opts.fill()
+
+			q := newQuarantine(opts, newLogger(opts.Logger, opts.FullName()))
+			applied := make(chan Filename, 2)
+			ctx, cancel := context.WithCancel(context.Background())
+			w := newWatcher(ctx, opts, func(f Filename) (err error) {
+				if f == poison {
+					_, err = q.OnFailure(f, 0, tt.err)
+					return
+				}
+
+				applied <- f
+				return
+			}, TypeChunk)
+			defer w.waitToComplete()
+			defer cancel()
+
+			qf := <-quarantined
+			if qf.Filename != poison {
+				t.Fatalf("invalid filename, expected <%v> and received <%v>", poison, qf.Filename)
+			}
+
+			if qf.Attempts != tt.wantAttempts {
+				t.Fatalf("invalid attempts, expected %d and received %d", tt.wantAttempts, qf.Attempts)
+			}
+
+			if tt.wantHalted {
+				waitFor(t, func() bool { return w.State() == WatcherStateHalted })
+			} else if f := <-applied; f != next {
+				t.Fatalf("invalid applied file, expected <%v> and received <%v>", next, f)
+			}
+
+			files, err := q.List()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(files) != 1 {
+				t.Fatalf("invalid number of quarantined files, expected %d and received %d", 1, len(files))
+			}
+
+			if files[0].Filename != poison || files[0].Reason != tt.err.Error() || files[0].Attempts != tt.wantAttempts {
+				t.Fatalf("invalid quarantined file, expected <%v> and received %+v", poison, files[0])
+			}
+
+			if err = q.Reinject(poison); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err = os.Stat(path.Join(opts.Dir, poison.String())); err != nil {
+				t.Fatalf("expected re-injected file to exist: %v", err)
+			}
+
+			if files, err = q.List(); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(files) != 0 {
+				t.Fatalf("invalid number of quarantined files, expected %d and received %d", 0, len(files))
+			}
+
+			if err = q.Reinject(poison); err != ErrNotQuarantined {
+				t.Fatalf("invalid error, expected <%v> and received <%v>", ErrNotQuarantined, err)
+			}
+		})
+	}
+}
+
+func Test_newQuarantine_disabled(t *testing.T) {
+	opts := MakeOptions(t.TempDir(), "test")
+	if q := newQuarantine(opts, newLogger(opts.Logger, opts.FullName())); q != nil {
+		t.Fatalf("expected nil quarantine for an empty policy and received %+v", q)
+	}
+
+	c := newTestConsumer(t, opts, func(typ Type, r *Reader) error {
+		return fmt.Errorf("apply fail")
+	})
+
+	filename := makeFilename("test", 1, TypeChunk)
+	w, err := newWriter(opts.Dir, filename, CompressionNone, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a quarantine, the error is returned so the file is retried
+	if err = c.onChunk(filename); err == nil {
+		t.Fatal("expected error and received nil")
+	}
+
+	if _, err = os.Stat(path.Join(opts.Dir, quarantineDir)); !os.IsNotExist(err) {
+		t.Fatalf("expected quarantine directory to not exist, received <%v>", err)
+	}
+}
+
+func TestConsumer_Reinject_partial(t *testing.T) {
+	opts := MakeOptions(t.TempDir(), "test")
+	opts.Quarantine = QuarantinePolicy{Attempts: 1}
+	opts.BlockCheckpoints = true
+
+	var (
+		applied []string
+		failAt  = int64(2)
+	)
+
+	poison := makeFilename("test", 1, TypeChunk)
+	next := makeFilename("test", 2, TypeChunk)
+	c := newTestConsumer(t, opts, func(typ Type, r *Reader) error {
+		return r.ForEachWithPosition(0, func(pos Position, b Block) (err error) {
+			if pos.Index == failAt {
+				return fmt.Errorf("apply fail")
+			}
+
+			applied = append(applied, string(b))
+			return
+		})
+	})
+
+	writeChunk := func(filename Filename, blocks ...string) {
+		w, err := newWriter(opts.Dir, filename, CompressionNone, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, b := range blocks {
+			if err = w.Write(Block(b)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err = w.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeChunk(poison, "0", "1", "2", "3")
+	writeChunk(next, "a", "b")
+
+	// Fail while applying the third block, the chunk is quarantined and skipped
+	if err := c.onChunk(poison); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := c.q.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 1 || files[0].AppliedBlocks != 2 {
+		t.Fatalf("invalid quarantined files, expected 2 applied blocks and received %+v", files)
+	}
+
+	// The following chunk moves the applied checkpoint on
+	failAt = -1
+	if err = c.onChunk(next); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = c.q.Reinject(poison); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the blocks which were not applied before the chunk was quarantined should be applied
+	if err = c.onChunk(poison); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"0", "1", "a", "b", "2", "3"}
+	if !reflect.DeepEqual(applied, want) {
+		t.Fatalf("invalid applied blocks, expected %v and received %v", want, applied)
+	}
+
+	if _, err = os.Stat(c.q.recordPath(poison.String())); !os.IsNotExist(err) {
+		t.Fatalf("expected quarantine record to be removed, received <%v>", err)
+	}
+
+	if n := atomic.LoadInt64(&c.quarantined); n != 1 {
+		t.Fatalf("invalid quarantined count, expected %d and received %d", 1, n)
+	}
+}
@@ -1,7 +1,10 @@
 package kiroku
 
 import (
+	"bufio"
+	"encoding/binary"
 	"fmt"
+	"hash/crc32"
 	"io"
 	"os"
 
@@ -35,36 +38,142 @@ func Read(filename string, p Processor) (err error) {
 // Reader will parse and read a history chunk
 type Reader struct {
 	r io.ReadSeeker
+
+	// start is the minimum block index to iterate from, set by a Consumer resuming a chunk
+	start int64
+	// onApplied is called after a block has been successfully processed
+	onApplied func(Position)
+}
+
+// Header will return the header of the chunk
+// Note: Legacy headerless chunks will return an empty Header
+func (r *Reader) Header() (h Header, err error) {
+	// Seek to the first byte
+	if _, err = r.r.Seek(0, io.SeekStart); err != nil {
+		err = fmt.Errorf("error seeking to first byte: %v", err)
+		return
+	}
+
+	var ok bool
+	if ok, err = h.readFrom(r.r); err != nil {
+		err = newCorruptionError(0, 0, err)
+		return
+	}
+
+	if !ok {
+		// Header was not found, return an empty Header to denote a legacy chunk
+		h = Header{}
+		return
+	}
+
+	if !h.Flags.Has(FlagEncrypted) {
+		return
+	}
+
+	var env envelope
+	// Read encryption envelope to determine the key ID
+	if err = env.readFrom(r.r); err != nil {
+		err = newCorruptionError(headerSize, 0, handleFrameError(err))
+		return
+	}
+
+	h.KeyID = env.keyID
+	return
 }
 
-// ForEach will iterate through all the blocks within the reader
+// ForEach will iterate through all the blocks within the reader, starting at the block index of seek
 func (r *Reader) ForEach(seek int64, fn func(Block) error) (err error) {
+	return r.ForEachWithPosition(seek, func(_ Position, b Block) error {
+		return fn(b)
+	})
+}
+
+// ForEachWithPosition will iterate through the blocks within the reader, starting at the block index of seek
+// The position of each block is provided so that a failed iteration can be resumed at the failed block
+// Note: Chunks with a block index will jump directly to the seek block, other chunks will skip preceding blocks
+// Note: BlockEnvelopes are provided in their encoded form, use ForEachEnvelope to decode them
+func (r *Reader) ForEachWithPosition(seek int64, fn func(Position, Block) error) (err error) {
+	return r.forEach(seek, func(pos Position, _ blockKind, b Block) error {
+		return fn(pos, b)
+	})
+}
+
+// forEach will iterate through the blocks and their kinds within the reader, starting at the block index of seek
+func (r *Reader) forEach(seek int64, fn func(Position, blockKind, Block) error) (err error) {
+	if seek < r.start {
+		// Blocks before the start have already been applied, skip them
+		seek = r.start
+	}
+
+	if r.onApplied != nil {
+		fn = r.withCheckpoint(fn)
+	}
+
+	var h Header
+	if h, err = r.Header(); err != nil {
+		return
+	}
+
+	switch {
+	case h.IsLegacy():
+		return r.forEachLegacy(seek, fn)
+	case h.Flags.Has(FlagEncrypted):
+		return ErrEncryptedChunk
+	}
+
+	var size int64
+	// Seek to the end to determine the size of the chunk
+	if size, err = r.r.Seek(0, io.SeekEnd); err != nil {
+		err = fmt.Errorf("error seeking to last byte: %v", err)
+		return
+	}
+
+	var start Position
+	// Get the position of the first block to read
+	if start, err = r.getStartPosition(h, seek, size); err != nil {
+		return
+	}
+
 	// Seek to the first block byte
-	if _, err = r.r.Seek(0, 0); err != nil {
+	if _, err = r.r.Seek(start.Offset, io.SeekStart); err != nil {
 		err = fmt.Errorf("error seeking to first block byte: %v", err)
 		return
 	}
 
-	// Initialize a new Enkodo reader
-	rdr := enkodo.NewReader(r.r)
+	fr := newFrameReader(r.r, h, start, size)
 
 	// Iterate until break
 	for {
-		var b Block
-		// Decode next block
-		if err = rdr.Decode(&b); err != nil {
-			// Error encountered while decoding, break out of the loop
+		pos := fr.position()
+		var (
+			kind blockKind
+			b    Block
+		)
+
+		// Read next block
+		if kind, b, err = fr.next(); err != nil {
+			// Error encountered while reading, break out of the loop
 			break
 		}
 
+		if pos.Index < seek {
+			// Block precedes the seek block, skip
+			continue
+		}
+
 		// Call provided function
-		if err = fn(b); err != nil {
+		if err = fn(pos, kind, b); err != nil {
 			// Function returned an error, return
 			return
 		}
 	}
 
-	return r.handleError(err)
+	if err == io.EOF {
+		// Trailer has been reached, return
+		return nil
+	}
+
+	return
 }
 
 // Copy will copy the entire reader
@@ -84,6 +193,114 @@ func (r *Reader) ReadSeeker() io.ReadSeeker {
 	return r.r
 }
 
+// withCheckpoint will wrap the provided function so onApplied is called after each successful block
+func (r *Reader) withCheckpoint(fn func(Position, blockKind, Block) error) func(Position, blockKind, Block) error {
+	return func(pos Position, kind blockKind, b Block) (err error) {
+		if err = fn(pos, kind, b); err != nil {
+			return
+		}
+
+		r.onApplied(pos)
+		return
+	}
+}
+
+// getStartPosition will return the position of the block at the seek index
+// Note: Chunks without a block index will return the position of the first block
+func (r *Reader) getStartPosition(h Header, seek, size int64) (pos Position, err error) {
+	pos.Offset = h.size()
+	if seek <= 0 || !h.Flags.Has(FlagBlockIndex) {
+		return
+	}
+
+	var trailer [trailerSize]byte
+	// Read the trailer to determine the number of blocks
+	if _, err = r.r.Seek(size-trailerSize, io.SeekStart); err != nil {
+		return pos, newCorruptionError(h.size(), 0, ErrTruncatedChunk)
+	}
+
+	if _, err = io.ReadFull(r.r, trailer[:]); err != nil {
+		return pos, newCorruptionError(size-trailerSize, 0, handleFrameError(err))
+	}
+
+	if binary.BigEndian.Uint32(trailer[8:]) != crc32.Checksum(trailer[:8], castagnoli) {
+		return pos, newCorruptionError(size-trailerSize, 0, ErrInvalidTrailer)
+	}
+
+	count := int64(binary.BigEndian.Uint64(trailer[:8]))
+	if count < 0 || count > size/indexEntrySize {
+		return pos, newCorruptionError(size-trailerSize, 0, ErrInvalidTrailer)
+	}
+
+	indexStart := size - trailerSize - 4 - count*indexEntrySize
+	if indexStart <= h.size() {
+		return pos, newCorruptionError(size-trailerSize, 0, ErrInvalidTrailer)
+	}
+
+	if seek >= count {
+		// Seek is past the last block, start at the end of blocks marker so the index and trailer are verified
+		pos.Offset = indexStart - 1
+		pos.Index = count
+		return
+	}
+
+	var entry [indexEntrySize]byte
+	// Read the index entry for the seek block
+	if _, err = r.r.Seek(indexStart+seek*indexEntrySize, io.SeekStart); err != nil {
+		err = fmt.Errorf("error seeking to block index: %v", err)
+		return
+	}
+
+	if _, err = io.ReadFull(r.r, entry[:]); err != nil {
+		return pos, newCorruptionError(indexStart, seek, handleFrameError(err))
+	}
+
+	pos.Offset = int64(binary.BigEndian.Uint64(entry[:]))
+	pos.Index = seek
+	if pos.Offset < h.size() || pos.Offset >= indexStart-1 {
+		return pos, newCorruptionError(indexStart, seek, ErrInvalidIndex)
+	}
+
+	return
+}
+
+func (r *Reader) forEachLegacy(seek int64, fn func(Position, blockKind, Block) error) (err error) {
+	// Seek to the first block byte
+	if _, err = r.r.Seek(0, io.SeekStart); err != nil {
+		err = fmt.Errorf("error seeking to first block byte: %v", err)
+		return
+	}
+
+	// Initialize a new Enkodo reader
+	// Note: The counting reader is used to determine the offset of each block
+	cr := newCountingReader(r.r)
+	rdr := enkodo.NewReader(cr)
+
+	// Iterate until break
+	for index := int64(0); ; index++ {
+		pos := Position{Offset: cr.n, Index: index}
+		var b Block
+		// Decode next block
+		if err = rdr.Decode(&b); err != nil {
+			// Error encountered while decoding, break out of the loop
+			break
+		}
+
+		if index < seek {
+			// Block precedes the seek block, skip
+			continue
+		}
+
+		// Call provided function
+		if err = fn(pos, blockKindRaw, b); err != nil {
+			// Function returned an error, return
+			return
+		}
+	}
+
+	return r.handleError(err)
+}
+
 func (r *Reader) handleError(inbound error) (err error) {
 	switch inbound {
 	case nil:
@@ -95,3 +312,37 @@ func (r *Reader) handleError(inbound error) (err error) {
 		return fmt.Errorf("error decoding block: %v", inbound)
 	}
 }
+
+// Position represents the location of a block within a chunk
+type Position struct {
+	// Offset is the byte offset of the block frame
+	Offset int64
+	// Index is the index of the block within the chunk
+	Index int64
+}
+
+func newCountingReader(r io.Reader) *countingReader {
+	var c countingReader
+	c.r = bufio.NewReader(r)
+	return &c
+}
+
+// countingReader counts the bytes read from a buffered reader
+type countingReader struct {
+	r *bufio.Reader
+	n int64
+}
+
+func (c *countingReader) Read(bs []byte) (n int, err error) {
+	n, err = c.r.Read(bs)
+	c.n += int64(n)
+	return
+}
+
+func (c *countingReader) ReadByte() (b byte, err error) {
+	if b, err = c.r.ReadByte(); err == nil {
+		c.n++
+	}
+
+	return
+}
@@ -2,6 +2,7 @@ package kiroku
 
 import (
 	"bytes"
+	"compress/gzip"
 	"io"
 	"os"
 	"reflect"
@@ -190,6 +191,307 @@ func TestReader_ForEach(t *testing.T) {
 	}
 }
 
+func TestReader_ForEach_integrity(t *testing.T) {
+	type testcase struct {
+		name       string
+		bs         func() []byte
+		wantBlocks int
+		wantErr    error
+	}
+
+	tests := []testcase{
+		{
+			name: "basic",
+			bs: func() []byte {
+				return newTestChunk(Block("hello"), Block("world"))
+			},
+			wantBlocks: 2,
+		},
+		{
+			name: "legacy",
+			bs: func() []byte {
+				buf := bytes.NewBuffer(nil)
+				w := enkodo.NewWriter(buf)
+				_ = w.Encode(Block("hello"))
+				_ = w.Encode(Block("world"))
+				return buf.Bytes()
+			},
+			wantBlocks: 2,
+		},
+		{
+			name: "flipped bit",
+			bs: func() (bs []byte) {
+				bs = newTestChunk(Block("hello"), Block("world"))
+				bs[headerSize+2] ^= 0x01
+				return
+			},
+			wantErr: ErrChecksumMismatch,
+		},
+		{
+			name: "truncated within block",
+			bs: func() (bs []byte) {
+				bs = newTestChunk(Block("hello"), Block("world"))
+				return bs[:headerSize+10]
+			},
+			wantBlocks: 1,
+			wantErr:    ErrTruncatedChunk,
+		},
+		{
+			name: "truncated at block boundary",
+			bs: func() (bs []byte) {
+				bs = newTestChunk(Block("hello"), Block("world"))
+				return bs[:len(bs)-trailerSize-1]
+			},
+			wantBlocks: 2,
+			wantErr:    ErrTruncatedChunk,
+		},
+		{
+			name: "invalid trailer",
+			bs: func() (bs []byte) {
+				bs = newTestChunk(Block("hello"), Block("world"))
+				bs[len(bs)-5] ^= 0x01
+				return
+			},
+			wantBlocks: 2,
+			wantErr:    ErrInvalidTrailer,
+		},
+		{
+			name: "appended after trailer",
+			bs: func() (bs []byte) {
+				bs = newTestChunk(Block("hello"), Block("world"))
+				return appendFrame(bs, Block("foo"), FlagBlockChecksums)
+			},
+			wantBlocks: 2,
+			wantErr:    ErrInvalidTrailer,
+		},
+		{
+			name: "invalid compressed block",
+			bs: func() (bs []byte) {
+				h := newHeader(FlagBlockChecksums|FlagCompressed, CompressionGzip)
+				bs = appendFrame(h.Bytes(), []byte("hello world!"), h.Flags)
+				return appendTrailer(bs, nil, 1, h.Flags)
+			},
+			wantErr: gzip.ErrHeader,
+		},
+		{
+			name: "unsupported version",
+			bs: func() (bs []byte) {
+				bs = newTestChunk(Block("hello"))
+				bs[4] = FormatVersion + 1
+				return
+			},
+			wantErr: ErrUnsupportedVersion,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var count int
+			r := NewReader(bytes.NewReader(tt.bs()))
+			err := r.ForEach(0, func(b Block) (err error) {
+				count++
+				return
+			})
+
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Fatalf("Reader.ForEach() unexpected error = %v", err)
+			case tt.wantErr != nil:
+				cerr, ok := err.(*CorruptionError)
+				if !ok || cerr.Err != tt.wantErr {
+					t.Fatalf("Reader.ForEach() error = %v, wantErr %v", err, tt.wantErr)
+				}
+			}
+
+			if count != tt.wantBlocks {
+				t.Fatalf("Reader.ForEach() invalid block count, expected %d and received %d", tt.wantBlocks, count)
+			}
+		})
+	}
+}
+
+func TestReader_ForEachWithPosition(t *testing.T) {
+	type testcase struct {
+		name      string
+		bs        []byte
+		seek      int64
+		want      []Block
+		wantIndex []int64
+	}
+
+	blocks := []Block{Block("hello"), Block("world"), Block("foo"), Block("bar")}
+	indexed := newTestChunkWithFlags(FlagBlockChecksums|FlagBlockIndex, blocks...)
+	unindexed := newTestChunk(blocks...)
+	legacy := newTestLegacyChunk(blocks...)
+
+	tests := []testcase{
+		{
+			name:      "indexed",
+			bs:        indexed,
+			want:      blocks,
+			wantIndex: []int64{0, 1, 2, 3},
+		},
+		{
+			name:      "indexed with seek",
+			bs:        indexed,
+			seek:      2,
+			want:      blocks[2:],
+			wantIndex: []int64{2, 3},
+		},
+		{
+			name: "indexed with seek past end",
+			bs:   indexed,
+			seek: 10,
+		},
+		{
+			name:      "unindexed with seek",
+			bs:        unindexed,
+			seek:      1,
+			want:      blocks[1:],
+			wantIndex: []int64{1, 2, 3},
+		},
+		{
+			name:      "legacy with seek",
+			bs:        legacy,
+			seek:      3,
+			want:      blocks[3:],
+			wantIndex: []int64{3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				got      []Block
+				gotIndex []int64
+			)
+
+			r := NewReader(bytes.NewReader(tt.bs))
+			if err := r.ForEachWithPosition(tt.seek, func(pos Position, b Block) (err error) {
+				// Ensure the block can be read directly from it's position
+				var direct []Block
+				if err = r.ForEachWithPosition(pos.Index, func(p Position, b Block) error {
+					if p != pos {
+						t.Errorf("invalid position, expected %+v and received %+v", pos, p)
+					}
+
+					direct = append(direct, b)
+					return errors.ErrIsClosed
+				}); err != errors.ErrIsClosed {
+					return
+				}
+
+				if !bytes.Equal(direct[0], b) {
+					t.Errorf("invalid block at position %+v, expected <%s> and received <%s>", pos, b, direct[0])
+				}
+
+				got = append(got, b)
+				gotIndex = append(gotIndex, pos.Index)
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("invalid blocks, expected %s and received %s", tt.want, got)
+			}
+
+			if !reflect.DeepEqual(gotIndex, tt.wantIndex) {
+				t.Fatalf("invalid indexes, expected %v and received %v", tt.wantIndex, gotIndex)
+			}
+		})
+	}
+}
+
+func TestReader_ForEachWithPosition_resume(t *testing.T) {
+	blocks := []Block{Block("hello"), Block("world"), Block("foo"), Block("bar")}
+	r := NewReader(bytes.NewReader(newTestChunkWithFlags(FlagBlockChecksums|FlagBlockIndex, blocks...)))
+
+	var (
+		got    []Block
+		failed Position
+	)
+
+	// Fail on the third block
+	err := r.ForEachWithPosition(0, func(pos Position, b Block) error {
+		if pos.Index == 2 {
+			failed = pos
+			return errors.ErrIsClosed
+		}
+
+		got = append(got, b)
+		return nil
+	})
+
+	if err != errors.ErrIsClosed {
+		t.Fatalf("invalid error, expected <%v> and received <%v>", errors.ErrIsClosed, err)
+	}
+
+	// Resume at the failed block
+	if err = r.ForEachWithPosition(failed.Index, func(pos Position, b Block) error {
+		got = append(got, b)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, blocks) {
+		t.Fatalf("invalid blocks, expected %s and received %s", blocks, got)
+	}
+}
+
+func TestReader_ForEachWithPosition_invalid_index(t *testing.T) {
+	type testcase struct {
+		name    string
+		modify  func(bs []byte) []byte
+		seek    int64
+		wantErr error
+	}
+
+	tests := []testcase{
+		{
+			name: "index checksum",
+			modify: func(bs []byte) []byte {
+				// Last byte of the final index entry
+				bs[len(bs)-trailerSize-5] ^= 0x01
+				return bs
+			},
+			wantErr: ErrInvalidIndex,
+		},
+		{
+			name: "index entry out of range",
+			modify: func(bs []byte) []byte {
+				// First byte of the second index entry
+				bs[len(bs)-trailerSize-4-indexEntrySize] = 0xff
+				return bs
+			},
+			seek:    1,
+			wantErr: ErrInvalidIndex,
+		},
+		{
+			name: "invalid trailer",
+			modify: func(bs []byte) []byte {
+				bs[len(bs)-5] ^= 0x01
+				return bs
+			},
+			seek:    1,
+			wantErr: ErrInvalidTrailer,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bs := tt.modify(newTestChunkWithFlags(FlagBlockChecksums|FlagBlockIndex, Block("hello"), Block("world")))
+			r := NewReader(bytes.NewReader(bs))
+			err := r.ForEachWithPosition(tt.seek, func(pos Position, b Block) error { return nil })
+			cerr, ok := err.(*CorruptionError)
+			if !ok || cerr.Err != tt.wantErr {
+				t.Fatalf("Reader.ForEachWithPosition() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestReader_Copy(t *testing.T) {
 	type fields struct {
 		getReader func() (File, error)
@@ -353,3 +655,13 @@ func TestReader_handleError(t *testing.T) {
 		})
 	}
 }
+
+func newTestLegacyChunk(blocks ...Block) []byte {
+	buf := bytes.NewBuffer(nil)
+	w := enkodo.NewWriter(buf)
+	for _, b := range blocks {
+		_ = w.Encode(b)
+	}
+
+	return buf.Bytes()
+}
@@ -0,0 +1,172 @@
+package kiroku
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/hatchify/errors"
+)
+
+const (
+	// ErrRetentionUnsupported is returned when a retention manager is initialized with a Source which cannot list or delete
+	ErrRetentionUnsupported = errors.Error("source does not support retention, Deleter and Lister must be implemented")
+	// ErrInvalidKeepSnapshots is returned when a RetentionPolicy does not keep any snapshots
+	ErrInvalidKeepSnapshots = errors.Error("invalid retention policy, KeepSnapshots must be greater than zero")
+)
+
+// consumersPrefix is the Source prefix containing the progress of registered consumers
+const consumersPrefix = "_consumers"
+
+// RetentionPolicy determines which chunks and snapshots are kept within a Source
+type RetentionPolicy struct {
+	// KeepSnapshots is the number of most recent snapshots to keep, all chunks
+	// after the oldest kept snapshot are kept as well
+	KeepSnapshots int `toml:"keep_snapshots" json:"keepSnapshots"`
+}
+
+// Validate ensures that the RetentionPolicy values are valid
+func (r RetentionPolicy) Validate() (err error) {
+	if r.KeepSnapshots <= 0 {
+		return ErrInvalidKeepSnapshots
+	}
+
+	return
+}
+
+// NewRetentionManager will initialize a new RetentionManager
+// Note: The Source must implement both Deleter and Lister
+func NewRetentionManager(opts Options, src Source, policy RetentionPolicy) (rp *RetentionManager, err error) {
+	var errs errors.ErrorList
+	errs.Push(opts.Validate())
+	errs.Push(policy.Validate())
+	if err = errs.Err(); err != nil {
+		return
+	}
+
+	var (
+		r  RetentionManager
+		ok bool
+	)
+
+	if r.d, ok = src.(Deleter); !ok {
+		return nil, ErrRetentionUnsupported
+	}
+
+	if r.l, ok = src.(Lister); !ok {
+		return nil, ErrRetentionUnsupported
+	}
+
+	r.opts = opts
+	r.src = src
+	r.policy = policy
+	rp = &r
+	return
+}
+
+// RetentionManager removes superseded chunks and snapshots from a Source
+// Files are never deleted until every registered consumer (Options.ConsumerID) has passed them
+type RetentionManager struct {
+	opts   Options
+	policy RetentionPolicy
+
+	src Source
+	d   Deleter
+	l   Lister
+}
+
+// Collect will delete the chunks and snapshots which are no longer retained
+func (r *RetentionManager) Collect(ctx context.Context) (deleted []string, err error) {
+	var filenames, snapshots []string
+	if err = r.l.List(ctx, r.opts.FullName(), func(filename string) (err error) {
+		var parsed Filename
+		if parsed, err = ParseFilename(filename); err != nil {
+			// Not a chunk or snapshot, ignore
+			return nil
+		}
+
+		if parsed.Filetype == TypeSnapshot {
+			snapshots = append(snapshots, filename)
+		}
+
+		filenames = append(filenames, filename)
+		return
+	}); err != nil {
+		err = fmt.Errorf("error listing files: %v", err)
+		return
+	}
+
+	if len(snapshots) < r.policy.KeepSnapshots {
+		// Not enough snapshots to remove anything, return
+		return
+	}
+
+	// Everything before the oldest kept snapshot has been superseded
+	cutoff := snapshots[len(snapshots)-r.policy.KeepSnapshots]
+
+	var (
+		minProgress string
+		registered  bool
+	)
+
+	if minProgress, registered, err = r.getMinimumProgress(ctx); err != nil {
+		return
+	}
+
+	for _, filename := range filenames {
+		if filename >= cutoff {
+			break
+		}
+
+		if registered && filename > minProgress {
+			// A registered consumer has not yet passed this file, break
+			break
+		}
+
+		if err = r.d.Delete(ctx, r.opts.FullName(), filename); err != nil {
+			err = fmt.Errorf("error deleting <%s>: %v", filename, err)
+			return
+		}
+
+		deleted = append(deleted, filename)
+	}
+
+	return
+}
+
+// Unregister will remove the progress of a consumer so it no longer prevents deletion
+func (r *RetentionManager) Unregister(ctx context.Context, consumerID string) (err error) {
+	return r.d.Delete(ctx, getConsumersPrefix(r.opts.FullName()), consumerID)
+}
+
+// getMinimumProgress will return the last processed filename of the furthest behind registered consumer
+func (r *RetentionManager) getMinimumProgress(ctx context.Context) (minProgress string, registered bool, err error) {
+	prefix := getConsumersPrefix(r.opts.FullName())
+	if err = r.l.List(ctx, prefix, func(consumerID string) (err error) {
+		buf := bytes.NewBuffer(nil)
+		if err = r.src.Get(ctx, prefix, consumerID, func(rdr io.Reader) (err error) {
+			_, err = io.Copy(buf, rdr)
+			return
+		}); err != nil {
+			return fmt.Errorf("error getting progress for consumer <%s>: %v", consumerID, err)
+		}
+
+		if progress := buf.String(); !registered || progress < minProgress {
+			minProgress = progress
+			registered = true
+		}
+
+		return
+	}); err != nil {
+		err = fmt.Errorf("error listing consumers: %v", err)
+		return
+	}
+
+	return
+}
+
+func getConsumersPrefix(name string) string {
+	return path.Join(consumersPrefix, name)
+}
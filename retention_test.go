@@ -0,0 +1,230 @@
+package kiroku
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestRetentionManager_Collect(t *testing.T) {
+	type testcase struct {
+		name string
+		// files are created in order, the value represents the filetype
+		files         []Type
+		keepSnapshots int
+		// progress is a map of consumer ID to the index of the last processed file
+		progress    map[string]int
+		wantDeleted []int
+	}
+
+	tests := []testcase{
+		{
+			name:          "no snapshots",
+			files:         []Type{TypeChunk, TypeChunk, TypeChunk},
+			keepSnapshots: 1,
+		},
+		{
+			name:          "single snapshot",
+			files:         []Type{TypeChunk, TypeSnapshot, TypeChunk},
+			keepSnapshots: 1,
+			wantDeleted:   []int{0},
+		},
+		{
+			name:          "keep last snapshot",
+			files:         []Type{TypeChunk, TypeSnapshot, TypeChunk, TypeSnapshot, TypeChunk},
+			keepSnapshots: 1,
+			wantDeleted:   []int{0, 1, 2},
+		},
+		{
+			name:          "keep last two snapshots",
+			files:         []Type{TypeChunk, TypeSnapshot, TypeChunk, TypeSnapshot, TypeChunk, TypeSnapshot},
+			keepSnapshots: 2,
+			wantDeleted:   []int{0, 1, 2},
+		},
+		{
+			name:          "not enough snapshots",
+			files:         []Type{TypeChunk, TypeSnapshot, TypeChunk, TypeSnapshot},
+			keepSnapshots: 3,
+		},
+		{
+			name:          "consumer behind",
+			files:         []Type{TypeChunk, TypeSnapshot, TypeChunk, TypeSnapshot, TypeChunk},
+			keepSnapshots: 1,
+			progress:      map[string]int{"a": 4, "b": 1},
+			wantDeleted:   []int{0, 1},
+		},
+		{
+			name:          "consumer ahead",
+			files:         []Type{TypeChunk, TypeSnapshot, TypeChunk, TypeSnapshot, TypeChunk},
+			keepSnapshots: 1,
+			progress:      map[string]int{"a": 4},
+			wantDeleted:   []int{0, 1, 2},
+		},
+		{
+			name:          "consumer without progress",
+			files:         []Type{TypeChunk, TypeSnapshot, TypeChunk, TypeSnapshot, TypeChunk},
+			keepSnapshots: 1,
+			progress:      map[string]int{"a": -1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			if err = os.MkdirAll("./testing_source", 0744); err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll("./testing_source")
+
+			var src *IOSource
+			if src, err = NewIOSource("./testing_source"); err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := context.Background()
+			opts := MakeOptions("./testing", "test")
+
+			var filenames []string
+			for i, filetype := range tt.files {
+				filename := makeFilename(opts.Name, int64(1000+i), filetype).String()
+				if _, err = src.Export(ctx, opts.Name, filename, bytes.NewReader([]byte(filename))); err != nil {
+					t.Fatal(err)
+				}
+
+				filenames = append(filenames, filename)
+			}
+
+			for consumerID, index := range tt.progress {
+				var progress string
+				if index >= 0 {
+					progress = filenames[index]
+				}
+
+				if _, err = src.Export(ctx, getConsumersPrefix(opts.Name), consumerID, bytes.NewReader([]byte(progress))); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var r *RetentionManager
+			if r, err = NewRetentionManager(opts, src, RetentionPolicy{KeepSnapshots: tt.keepSnapshots}); err != nil {
+				t.Fatal(err)
+			}
+
+			var deleted []string
+			if deleted, err = r.Collect(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			var wantDeleted []string
+			for _, index := range tt.wantDeleted {
+				wantDeleted = append(wantDeleted, filenames[index])
+			}
+
+			if !reflect.DeepEqual(deleted, wantDeleted) {
+				t.Fatalf("invalid deleted files, expected %v and received %v", wantDeleted, deleted)
+			}
+
+			var remaining []string
+			if remaining, err = src.GetNextList(ctx, opts.Name, "", 100); err != nil {
+				t.Fatal(err)
+			}
+
+			if want := filenames[len(wantDeleted):]; !reflect.DeepEqual(remaining, want) {
+				t.Fatalf("invalid remaining files, expected %v and received %v", want, remaining)
+			}
+		})
+	}
+}
+
+func TestNewRetentionManager(t *testing.T) {
+	type testcase struct {
+		name    string
+		src     Source
+		policy  RetentionPolicy
+		wantErr error
+	}
+
+	ioSource, err := NewIOSource("./testing_source")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll("./testing_source")
+
+	tests := []testcase{
+		{
+			name:   "basic",
+			src:    ioSource,
+			policy: RetentionPolicy{KeepSnapshots: 1},
+		},
+		{
+			name:    "unsupported source",
+			src:     newMockSource(nil, nil, nil, nil, nil, nil),
+			policy:  RetentionPolicy{KeepSnapshots: 1},
+			wantErr: ErrRetentionUnsupported,
+		},
+		{
+			name:    "invalid policy",
+			src:     ioSource,
+			wantErr: ErrInvalidKeepSnapshots,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewRetentionManager(MakeOptions("./testing", "test"), tt.src, tt.policy); err != tt.wantErr {
+				t.Fatalf("NewRetentionManager() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConsumer_setProgress(t *testing.T) {
+	var err error
+	if err = os.MkdirAll("./testing_source", 0744); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll("./testing_source")
+
+	var src *IOSource
+	if src, err = NewIOSource("./testing_source"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	var filenames []string
+	for i := int64(0); i < 3; i++ {
+		filename := makeFilename("test", 1000+i, TypeChunk)
+		bs := newTestChunk(Block("hello world"))
+		if _, err = src.Export(ctx, "test", filename.String(), bytes.NewReader(bs)); err != nil {
+			t.Fatal(err)
+		}
+
+		filenames = append(filenames, filename.String())
+	}
+
+	opts := MakeOptions("./testing", "test")
+	opts.ConsumerID = "consumer_a"
+	if err = os.MkdirAll(opts.Dir, 0744); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(opts.Dir)
+
+	if err = NewOneShotConsumer(opts, src, func(typ Type, r *Reader) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err = src.Get(ctx, getConsumersPrefix(opts.FullName()), opts.ConsumerID, func(r io.Reader) (err error) {
+		_, err = io.Copy(buf, r)
+		return
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := filenames[len(filenames)-1]; buf.String() != want {
+		t.Fatalf("invalid progress, expected <%s> and received <%s>", want, buf.String())
+	}
+}
@@ -0,0 +1,238 @@
+package kiroku
+
+import (
+	stderrors "errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/hatchify/errors"
+)
+
+// ErrInvalidRetryPolicy is returned when a RetryPolicy has invalid values
+const ErrInvalidRetryPolicy = errors.Error("invalid retry policy, values cannot be negative, Multiplier must be at least 1, Jitter cannot exceed 1 and MaxDelay cannot be less than InitialDelay")
+
+// RetryPolicy determines how Producers and Consumers retry after encountering errors.
+// When the policy is empty, errors are retried every ErrorDelay indefinitely
+type RetryPolicy struct {
+	// InitialDelay is the amount of time to wait after the first failed attempt
+	// (Default is ErrorDelay)
+	InitialDelay time.Duration `toml:"initial_delay" json:"initialDelay"`
+	// MaxDelay is the maximum amount of time to wait between attempts (Default is
+	// ten times the InitialDelay)
+	MaxDelay time.Duration `toml:"max_delay" json:"maxDelay"`
+	// Multiplier is the factor the delay is increased by after each failed
+	// attempt (Default is 2)
+	Multiplier float64 `toml:"multiplier" json:"multiplier"`
+	// Jitter is the fraction of the delay which is randomized, from 0 to 1. A
+	// Jitter of 0.2 will wait between 80% and 100% of the delay (Default is none)
+	Jitter float64 `toml:"jitter" json:"jitter"`
+	// MaxAttempts is the number of attempts after which an error is escalated
+	// (Default is unlimited)
+	MaxAttempts int64 `toml:"max_attempts" json:"maxAttempts"`
+
+	// Classifier determines whether an error is transient or permanent, permanent
+	// errors are escalated without being retried (Default is ClassifyError)
+	Classifier func(err error) ErrorClass `toml:"-" json:"-"`
+}
+
+// IsEmpty will return whether or not the policy has any values set
+func (r RetryPolicy) IsEmpty() bool {
+	switch {
+	case r.InitialDelay != 0:
+	case r.MaxDelay != 0:
+	case r.Multiplier != 0:
+	case r.Jitter != 0:
+	case r.MaxAttempts != 0:
+	case r.Classifier != nil:
+	default:
+		return true
+	}
+
+	return false
+}
+
+// Validate ensures that the RetryPolicy values are valid
+func (r RetryPolicy) Validate() (err error) {
+	switch {
+	case r.InitialDelay < 0, r.MaxDelay < 0, r.MaxAttempts < 0:
+		return ErrInvalidRetryPolicy
+	case r.Multiplier != 0 && r.Multiplier < 1:
+		return ErrInvalidRetryPolicy
+	case r.Jitter < 0, r.Jitter > 1:
+		return ErrInvalidRetryPolicy
+	case r.MaxDelay > 0 && r.MaxDelay < r.InitialDelay:
+		return ErrInvalidRetryPolicy
+	}
+
+	return
+}
+
+// delay will return the amount of time to wait after the provided failed attempt
+func (r RetryPolicy) delay(attempt int64, errorDelay time.Duration, random func() float64) time.Duration {
+	initial := r.InitialDelay
+	if initial == 0 {
+		initial = errorDelay
+	}
+
+	if r.IsEmpty() {
+		// Retain the fixed ErrorDelay when a policy has not been set
+		return initial
+	}
+
+	multiplier := r.Multiplier
+	if multiplier == 0 {
+		multiplier = 2
+	}
+
+	maxDelay := r.MaxDelay
+	if maxDelay == 0 {
+		maxDelay = initial * 10
+	}
+
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	d := float64(initial) * math.Pow(multiplier, float64(attempt-1))
+	if d > float64(maxDelay) {
+		d = float64(maxDelay)
+	}
+
+	// Remove a random portion of the delay so retries are spread out
+	d -= d * r.Jitter * random()
+	return time.Duration(d)
+}
+
+// escalation will return an EscalatedError when the error should no longer be retried
+func (r RetryPolicy) escalation(filename Filename, attempt int64, err error) (e *EscalatedError, ok bool) {
+	permanent := r.classify(err) == ErrorPermanent
+	if !permanent && (r.MaxAttempts == 0 || attempt < r.MaxAttempts) {
+		return
+	}
+
+	e = &EscalatedError{Filename: filename, Attempt: attempt, Permanent: permanent, Err: err}
+	return e, true
+}
+
+func (r RetryPolicy) classify(err error) ErrorClass {
+	var perr *PermanentError
+	switch {
+	case stderrors.As(err, &perr):
+		return ErrorPermanent
+	case r.IsEmpty():
+		// Errors are not classified when a policy has not been set
+		return ErrorTransient
+	case r.Classifier == nil:
+		return ClassifyError(err)
+	default:
+		return r.Classifier(err)
+	}
+}
+
+// annotate will add context to an error while retaining it's classification
+// Note: The error is wrapped so a Classifier can match it with errors.Is and errors.As
+func (r RetryPolicy) annotate(msg string, err error) error {
+	if r.classify(err) == ErrorPermanent {
+		return Permanent(fmt.Errorf("%s: %w", msg, err))
+	}
+
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
+// randomJitter is the source of randomness for RetryPolicy jitter
+var randomJitter = rand.Float64
+
+const (
+	// ErrorTransient denotes an error which may succeed when retried
+	ErrorTransient ErrorClass = iota
+	// ErrorPermanent denotes an error which will not succeed when retried
+	ErrorPermanent
+)
+
+// ErrorClass represents whether an error is transient or permanent
+type ErrorClass uint8
+
+// String will return the string representation of the error class
+func (e ErrorClass) String() string {
+	switch e {
+	case ErrorTransient:
+		return "transient"
+	case ErrorPermanent:
+		return "permanent"
+	default:
+		return "invalid"
+	}
+}
+
+// ClassifyError is the default Classifier, chunk corruption, decryption and codec
+// mismatch errors are permanent as retrying will not change the result. Errors can be marked
+// as permanent by wrapping them with Permanent (E.g. within an UpdateFunc)
+func ClassifyError(err error) ErrorClass {
+	var cerr *CorruptionError
+	switch {
+	case stderrors.As(err, &cerr):
+		return ErrorPermanent
+	case stderrors.Is(err, ErrEncryptedChunk),
+		stderrors.Is(err, ErrDecryptionFailed),
+		stderrors.Is(err, ErrUnencryptedChunk),
+		stderrors.Is(err, ErrUnknownKeyID),
+		stderrors.Is(err, ErrCodecMismatch):
+		return ErrorPermanent
+	default:
+		return ErrorTransient
+	}
+}
+
+// Permanent will mark an error as permanent, so it is escalated rather than retried
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &PermanentError{Err: err}
+}
+
+// PermanentError is an error which will not succeed when retried
+type PermanentError struct {
+	Err error
+}
+
+// Error will return the error message
+func (p *PermanentError) Error() string {
+	return p.Err.Error()
+}
+
+// Unwrap will return the underlying error
+func (p *PermanentError) Unwrap() error {
+	return p.Err
+}
+
+// EscalatedError is provided to OnEscalate when an error is permanent or has
+// reached the MaxAttempts of the RetryPolicy
+type EscalatedError struct {
+	// Filename is the file which could not be processed
+	// Note: This is empty for errors which are not caused by a file
+	Filename Filename
+	// Attempt is the number of attempts made
+	Attempt int64
+	// Permanent is whether or not the error was classified as permanent
+	Permanent bool
+	// Err is the error of the final attempt
+	Err error
+}
+
+// Error will return the error message
+func (e *EscalatedError) Error() string {
+	if e.Filename == (Filename{}) {
+		return fmt.Sprintf("error escalated after %d attempt(s): %v", e.Attempt, e.Err)
+	}
+
+	return fmt.Sprintf("error escalated for <%s> after %d attempt(s): %v", e.Filename, e.Attempt, e.Err)
+}
+
+// Unwrap will return the underlying error
+func (e *EscalatedError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,314 @@
+package kiroku
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_Validate(t *testing.T) {
+	type testcase struct {
+		name    string
+		policy  RetryPolicy
+		wantErr error
+	}
+
+	tests := []testcase{
+		{
+			name: "empty",
+		},
+		{
+			name:   "backoff",
+			policy: RetryPolicy{InitialDelay: time.Second, MaxDelay: time.Minute, Multiplier: 2, Jitter: 0.2, MaxAttempts: 5},
+		},
+		{
+			name:    "negative delay",
+			policy:  RetryPolicy{InitialDelay: -1},
+			wantErr: ErrInvalidRetryPolicy,
+		},
+		{
+			name:    "negative attempts",
+			policy:  RetryPolicy{MaxAttempts: -1},
+			wantErr: ErrInvalidRetryPolicy,
+		},
+		{
+			name:    "multiplier below one",
+			policy:  RetryPolicy{Multiplier: 0.5},
+			wantErr: ErrInvalidRetryPolicy,
+		},
+		{
+			name:    "jitter above one",
+			policy:  RetryPolicy{Jitter: 1.5},
+			wantErr: ErrInvalidRetryPolicy,
+		},
+		{
+			name:    "max delay below initial delay",
+			policy:  RetryPolicy{InitialDelay: time.Minute, MaxDelay: time.Second},
+			wantErr: ErrInvalidRetryPolicy,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.policy.Validate(); err != tt.wantErr {
+				t.Fatalf("invalid error, expected <%v> and received <%v>", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_delay(t *testing.T) {
+	type testcase struct {
+		name    string
+		policy  RetryPolicy
+		attempt int64
+		random  float64
+		want    time.Duration
+	}
+
+	tests := []testcase{
+		{
+			name:    "empty",
+			attempt: 5,
+			want:    time.Second,
+		},
+		{
+			name:    "first attempt",
+			policy:  RetryPolicy{Multiplier: 2},
+			attempt: 1,
+			want:    time.Second,
+		},
+		{
+			name:    "exponential",
+			policy:  RetryPolicy{Multiplier: 2},
+			attempt: 3,
+			want:    time.Second * 4,
+		},
+		{
+			name:    "initial delay",
+			policy:  RetryPolicy{InitialDelay: time.Millisecond * 100, Multiplier: 3},
+			attempt: 3,
+			want:    time.Millisecond * 900,
+		},
+		{
+			name:    "default max delay",
+			policy:  RetryPolicy{Multiplier: 2},
+			attempt: 10,
+			want:    time.Second * 10,
+		},
+		{
+			name:    "max delay",
+			policy:  RetryPolicy{MaxDelay: time.Second * 3},
+			attempt: 10,
+			want:    time.Second * 3,
+		},
+		{
+			name:    "jitter",
+			policy:  RetryPolicy{Multiplier: 2, Jitter: 0.5},
+			attempt: 2,
+			random:  0.5,
+			want:    time.Millisecond * 1500,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.policy.delay(tt.attempt, time.Second, func() float64 { return tt.random })
+			if got != tt.want {
+				t.Fatalf("invalid delay, expected %v and received %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_escalation(t *testing.T) {
+	type testcase struct {
+		name          string
+		policy        RetryPolicy
+		attempt       int64
+		err           error
+		wantOk        bool
+		wantPermanent bool
+	}
+
+	corrupted := newCorruptionError(0, 0, ErrChecksumMismatch)
+	tests := []testcase{
+		{
+			name:    "empty policy",
+			attempt: 100,
+			err:     corrupted,
+		},
+		{
+			name:          "empty policy with permanent error",
+			attempt:       1,
+			err:           Permanent(io.ErrUnexpectedEOF),
+			wantOk:        true,
+			wantPermanent: true,
+		},
+		{
+			name:    "transient",
+			policy:  RetryPolicy{MaxAttempts: 3},
+			attempt: 2,
+			err:     io.ErrUnexpectedEOF,
+		},
+		{
+			name:    "max attempts",
+			policy:  RetryPolicy{MaxAttempts: 3},
+			attempt: 3,
+			err:     io.ErrUnexpectedEOF,
+			wantOk:  true,
+		},
+		{
+			name:          "corruption",
+			policy:        RetryPolicy{Multiplier: 2},
+			attempt:       1,
+			err:           corrupted,
+			wantOk:        true,
+			wantPermanent: true,
+		},
+		{
+			name:          "annotated",
+			policy:        RetryPolicy{Multiplier: 2},
+			attempt:       1,
+			err:           RetryPolicy{Multiplier: 2}.annotate("error encountered while processing", corrupted),
+			wantOk:        true,
+			wantPermanent: true,
+		},
+		{
+			name:          "codec mismatch",
+			policy:        RetryPolicy{Multiplier: 2},
+			attempt:       1,
+			err:           fmt.Errorf("%w: expected <json> and received <gob>", ErrCodecMismatch),
+			wantOk:        true,
+			wantPermanent: true,
+		},
+		{
+			name:          "annotated custom classifier",
+			policy:        RetryPolicy{Classifier: classifyErrPermission},
+			attempt:       1,
+			err:           RetryPolicy{}.annotate("error encountered while processing", os.ErrPermission),
+			wantOk:        true,
+			wantPermanent: true,
+		},
+		{
+			name: "custom classifier",
+			policy: RetryPolicy{Classifier: func(err error) ErrorClass {
+				if err == os.ErrPermission {
+					return ErrorPermanent
+				}
+
+				return ErrorTransient
+			}},
+			attempt:       1,
+			err:           os.ErrPermission,
+			wantOk:        true,
+			wantPermanent: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, ok := tt.policy.escalation(Filename{}, tt.attempt, tt.err)
+			if ok != tt.wantOk {
+				t.Fatalf("invalid ok, expected %v and received %v", tt.wantOk, ok)
+			}
+
+			if !ok {
+				return
+			}
+
+			if e.Permanent != tt.wantPermanent {
+				t.Fatalf("invalid permanent value, expected %v and received %v", tt.wantPermanent, e.Permanent)
+			}
+
+			if e.Attempt != tt.attempt {
+				t.Fatalf("invalid attempt, expected %d and received %d", tt.attempt, e.Attempt)
+			}
+
+			if !stderrors.Is(e, tt.err) {
+				t.Fatalf("invalid escalated error, expected <%v> to be within the chain of <%v>", tt.err, e)
+			}
+		})
+	}
+}
+
+func Test_watcher_escalate(t *testing.T) {
+	type testcase struct {
+		name   string
+		policy RetryPolicy
+		err    error
+
+		wantAttempt   int64
+		wantPermanent bool
+	}
+
+	tests := []testcase{
+		{
+			name:          "permanent",
+			err:           Permanent(fmt.Errorf("poison chunk")),
+			wantAttempt:   1,
+			wantPermanent: true,
+		},
+		{
+			name:        "max attempts",
+			policy:      RetryPolicy{InitialDelay: time.Millisecond, MaxAttempts: 3},
+			err:         io.ErrUnexpectedEOF,
+			wantAttempt: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := MakeOptions("./testing", "testing")
+			opts.Retry = tt.policy
+			if err := os.Mkdir(opts.Dir, 0744); err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(opts.Dir)
+
+			filename := makeFilename("testing", 1, TypeChunk)
+			f, err := os.Create(path.Join(opts.Dir, filename.String()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = f.Close()
+
+			escalated := make(chan *EscalatedError, 1)
+			opts.OnEscalate = func(err *EscalatedError) { escalated <- err }
+			opts.fill()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			w := newWatcher(ctx, opts, func(f Filename) error { return tt.err }, TypeChunk)
+			defer w.waitToComplete()
+			defer cancel()
+
+			e := <-escalated
+			if e.Filename != filename {
+				t.Fatalf("invalid filename, expected <%v> and received <%v>", filename, e.Filename)
+			}
+
+			if e.Attempt != tt.wantAttempt {
+				t.Fatalf("invalid attempt, expected %d and received %d", tt.wantAttempt, e.Attempt)
+			}
+
+			if e.Permanent != tt.wantPermanent {
+				t.Fatalf("invalid permanent value, expected %v and received %v", tt.wantPermanent, e.Permanent)
+			}
+
+			waitFor(t, func() bool { return w.State() == WatcherStateHalted })
+		})
+	}
+}
+
+func classifyErrPermission(err error) ErrorClass {
+	if stderrors.Is(err, os.ErrPermission) {
+		return ErrorPermanent
+	}
+
+	return ErrorTransient
+}
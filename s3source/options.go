@@ -0,0 +1,94 @@
+package s3source
+
+import (
+	"net/http"
+
+	"github.com/hatchify/errors"
+)
+
+const (
+	// ErrEmptyEndpoint is returned when an endpoint is empty
+	ErrEmptyEndpoint = errors.Error("invalid endpoint, cannot be empty")
+	// ErrEmptyBucket is returned when a bucket is empty
+	ErrEmptyBucket = errors.Error("invalid bucket, cannot be empty")
+	// ErrEmptyRegion is returned when a region is empty
+	ErrEmptyRegion = errors.Error("invalid region, cannot be empty")
+)
+
+const (
+	// DefaultPartSize is the default value for PartSize (8MiB)
+	DefaultPartSize = 8 * 1024 * 1024
+	// DefaultRangeSize is the default value for RangeSize (8MiB)
+	DefaultRangeSize = 8 * 1024 * 1024
+)
+
+// MakeOptions will create new Options
+func MakeOptions(endpoint, region, bucket string) (o Options) {
+	o.Endpoint = endpoint
+	o.Region = region
+	o.Bucket = bucket
+	return
+}
+
+// Options represent S3Source options
+type Options struct {
+	// Endpoint is the S3 service endpoint (E.g. https://s3.us-east-1.amazonaws.com)
+	Endpoint string `toml:"endpoint" json:"endpoint"`
+	Region   string `toml:"region" json:"region"`
+	Bucket   string `toml:"bucket" json:"bucket"`
+
+	AccessKeyID     string `toml:"access_key_id" json:"accessKeyID"`
+	SecretAccessKey string `toml:"secret_access_key" json:"secretAccessKey"`
+	SessionToken    string `toml:"session_token" json:"sessionToken"`
+
+	// KeyPrefix is an optional prefix prepended to all object keys
+	KeyPrefix string `toml:"key_prefix" json:"keyPrefix"`
+
+	// PathStyle will address the bucket as part of the path rather than the host
+	// Note: This is usually required for S3-compatible services such as MinIO
+	PathStyle bool `toml:"path_style" json:"pathStyle"`
+
+	// PartSize is the size of each part for multipart exports. Exports smaller than
+	// this value will be uploaded with a single request (Default is 8MiB).
+	// Note: S3 requires all parts except the last to be at least 5MiB
+	PartSize int64 `toml:"part_size" json:"partSize"`
+
+	// RangeSize is the size of each ranged request for imports (Default is 8MiB)
+	RangeSize int64 `toml:"range_size" json:"rangeSize"`
+
+	// HTTPClient is an optional client used for requests
+	HTTPClient *http.Client `toml:"-" json:"-"`
+}
+
+// Validate ensures that the Options have all the required fields set
+func (o *Options) Validate() (err error) {
+	var errs errors.ErrorList
+	if len(o.Endpoint) == 0 {
+		errs.Push(ErrEmptyEndpoint)
+	}
+
+	if len(o.Region) == 0 {
+		errs.Push(ErrEmptyRegion)
+	}
+
+	if len(o.Bucket) == 0 {
+		errs.Push(ErrEmptyBucket)
+	}
+
+	o.fill()
+	return errs.Err()
+}
+
+func (o *Options) fill() {
+	if o.PartSize <= 0 {
+		o.PartSize = DefaultPartSize
+	}
+
+	if o.RangeSize <= 0 {
+		o.RangeSize = DefaultRangeSize
+	}
+
+	if o.HTTPClient == nil {
+		o.HTTPClient = http.DefaultClient
+	}
+}
@@ -0,0 +1,549 @@
+package s3source
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mojura/kiroku"
+)
+
+// maxListKeys is the maximum number of keys S3 will return for a single list request
+const maxListKeys = 1000
+
+var (
+	_ kiroku.Source  = &S3Source{}
+	_ kiroku.Deleter = &S3Source{}
+	_ kiroku.Lister  = &S3Source{}
+	_ kiroku.Sizer   = &S3Source{}
+)
+
+// New will initialize a new S3Source instance
+func New(o Options) (sp *S3Source, err error) {
+	if err = o.Validate(); err != nil {
+		return
+	}
+
+	var s S3Source
+	s.opts = o
+	if s.endpoint, err = url.Parse(o.Endpoint); err != nil {
+		err = fmt.Errorf("error parsing endpoint <%s>: %v", o.Endpoint, err)
+		return
+	}
+
+	s.s = newSigner(o)
+	s.now = time.Now
+	sp = &s
+	return
+}
+
+// S3Source is a Source backed by an S3-compatible object store
+type S3Source struct {
+	opts     Options
+	endpoint *url.URL
+
+	s   *signer
+	now func() time.Time
+}
+
+// Export will upload the contents of the reader as prefix/filename
+// Note: Readers larger than PartSize will be uploaded as a multipart upload
+func (s *S3Source) Export(ctx context.Context, prefix, filename string, r io.Reader) (newFilename string, err error) {
+	key := s.getKey(prefix, filename)
+	// Note: The buffer is sized from the reader when it's size is known, so small files do not allocate an entire part
+	buf := make([]byte, s.getBufferSize(r))
+
+	var n int
+	n, err = io.ReadFull(r, buf)
+	if err == nil && int64(len(buf)) < s.opts.PartSize {
+		// Reader is larger than it's reported size, read the remainder of the part
+		buf = append(buf, make([]byte, s.opts.PartSize-int64(len(buf)))...)
+		var remaining int
+		remaining, err = io.ReadFull(r, buf[n:])
+		n += remaining
+	}
+
+	switch err {
+	case nil:
+		// Reader has at least one full part, upload as multipart
+		err = s.exportMultipart(ctx, key, buf, r)
+	case io.EOF, io.ErrUnexpectedEOF:
+		// Reader is smaller than a single part, upload as a single object
+		err = s.putObject(ctx, key, buf[:n])
+	}
+
+	if err != nil {
+		err = fmt.Errorf("error exporting <%s>: %v", key, err)
+		return
+	}
+
+	newFilename = filename
+	return
+}
+
+// getBufferSize will return the size of the buffer used for the first part of an export
+// Note: One byte more than the size of the reader is used, so a reader which fits within
+// a single part is read to EOF
+func (s *S3Source) getBufferSize(r io.Reader) (size int64) {
+	switch rr := r.(type) {
+	case interface{ Len() int }:
+		size = int64(rr.Len())
+	case interface{ Stat() (os.FileInfo, error) }:
+		fi, err := rr.Stat()
+		if err != nil {
+			return s.opts.PartSize
+		}
+
+		size = fi.Size()
+	default:
+		return s.opts.PartSize
+	}
+
+	if size+1 < s.opts.PartSize {
+		return size + 1
+	}
+
+	return s.opts.PartSize
+}
+
+// Import will download prefix/filename to the provided writer using ranged requests
+func (s *S3Source) Import(ctx context.Context, prefix, filename string, w io.Writer) (err error) {
+	var info kiroku.Info
+	if info, err = s.GetInfo(ctx, prefix, filename); err != nil {
+		return
+	}
+
+	key := s.getKey(prefix, filename)
+	for start := int64(0); start < info.Size; start += s.opts.RangeSize {
+		end := start + s.opts.RangeSize - 1
+		if end >= info.Size {
+			end = info.Size - 1
+		}
+
+		var done bool
+		if done, err = s.getRange(ctx, key, start, end, w); err != nil {
+			return
+		}
+
+		if done {
+			// Service returned the entire object, return
+			return
+		}
+	}
+
+	return
+}
+
+// Get will call the provided func with the contents of prefix/filename
+func (s *S3Source) Get(ctx context.Context, prefix, filename string, fn func(io.Reader) error) (err error) {
+	var req *http.Request
+	if req, err = s.newRequest(ctx, http.MethodGet, s.getKey(prefix, filename), nil, nil); err != nil {
+		return
+	}
+
+	var resp *http.Response
+	if resp, err = s.do(req); err != nil {
+		return handleNotFound(err)
+	}
+	defer resp.Body.Close()
+
+	return fn(resp.Body)
+}
+
+// GetNext will return the next filename after the provided last filename
+func (s *S3Source) GetNext(ctx context.Context, prefix, lastFilename string) (filename string, err error) {
+	var filenames []string
+	if filenames, err = s.GetNextList(ctx, prefix, lastFilename, 1); err != nil {
+		return
+	}
+
+	filename = filenames[0]
+	return
+}
+
+// GetNextList will return the next filenames after the provided last filename
+// Note: io.EOF is returned when no filenames are available
+func (s *S3Source) GetNextList(ctx context.Context, prefix, lastFilename string, maxKeys int64) (filenames []string, err error) {
+	listPrefix := s.getKey(prefix, "") + "/"
+	var startAfter string
+	if len(lastFilename) > 0 {
+		startAfter = listPrefix + lastFilename
+	}
+
+	var token string
+	for int64(len(filenames)) < maxKeys {
+		var result listBucketResult
+		if result, err = s.listObjects(ctx, listPrefix, startAfter, token, maxKeys-int64(len(filenames))); err != nil {
+			return nil, err
+		}
+
+		for _, c := range result.Contents {
+			filename := strings.TrimPrefix(c.Key, listPrefix)
+			if strings.Contains(filename, "/") {
+				// Key is nested within a sub-directory, skip
+				continue
+			}
+
+			filenames = append(filenames, filename)
+		}
+
+		if !result.IsTruncated {
+			break
+		}
+
+		token = result.NextContinuationToken
+	}
+
+	if len(filenames) == 0 {
+		return nil, io.EOF
+	}
+
+	return
+}
+
+// GetInfo will return the Info for prefix/filename
+func (s *S3Source) GetInfo(ctx context.Context, prefix, filename string) (info kiroku.Info, err error) {
+	var req *http.Request
+	if req, err = s.newRequest(ctx, http.MethodHead, s.getKey(prefix, filename), nil, nil); err != nil {
+		return
+	}
+
+	var resp *http.Response
+	if resp, err = s.do(req); err != nil {
+		err = handleNotFound(err)
+		return
+	}
+	defer resp.Body.Close()
+
+	info.Key = filename
+	info.Hash = strings.Trim(resp.Header.Get("ETag"), `"`)
+	info.Size = resp.ContentLength
+
+	var lastModified time.Time
+	if lastModified, err = http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.LastModified = lastModified.Unix()
+	}
+
+	err = nil
+	return
+}
+
+// GetSize will return the size of a file within the bucket
+func (s *S3Source) GetSize(ctx context.Context, prefix, filename string) (size int64, err error) {
+	var info kiroku.Info
+	if info, err = s.GetInfo(ctx, prefix, filename); err != nil {
+		return
+	}
+
+	return info.Size, nil
+}
+
+// Delete will delete a file from the bucket
+func (s *S3Source) Delete(ctx context.Context, prefix, filename string) (err error) {
+	var req *http.Request
+	if req, err = s.newRequest(ctx, http.MethodDelete, s.getKey(prefix, filename), nil, nil); err != nil {
+		return
+	}
+
+	if err = s.doAndClose(req, nil); handleNotFound(err) == os.ErrNotExist {
+		// S3 does not error on missing keys, this handles stores which do
+		return nil
+	}
+
+	return
+}
+
+// List will iterate through all the files for a prefix in ascending order
+func (s *S3Source) List(ctx context.Context, prefix string, fn func(filename string) error) (err error) {
+	listPrefix := s.getKey(prefix, "") + "/"
+	var token string
+	for {
+		var result listBucketResult
+		if result, err = s.listObjects(ctx, listPrefix, "", token, 1000); err != nil {
+			return
+		}
+
+		for _, c := range result.Contents {
+			filename := strings.TrimPrefix(c.Key, listPrefix)
+			if strings.Contains(filename, "/") {
+				// Key is nested within a sub-directory, skip
+				continue
+			}
+
+			if err = fn(filename); err != nil {
+				return
+			}
+		}
+
+		if !result.IsTruncated {
+			return
+		}
+
+		token = result.NextContinuationToken
+	}
+}
+
+func (s *S3Source) putObject(ctx context.Context, key string, body []byte) (err error) {
+	var req *http.Request
+	if req, err = s.newRequest(ctx, http.MethodPut, key, nil, body); err != nil {
+		return
+	}
+
+	return s.doAndClose(req, nil)
+}
+
+func (s *S3Source) exportMultipart(ctx context.Context, key string, buf []byte, r io.Reader) (err error) {
+	var uploadID string
+	if uploadID, err = s.createMultipartUpload(ctx, key); err != nil {
+		err = fmt.Errorf("error creating multipart upload: %v", err)
+		return
+	}
+
+	var parts []completedPart
+	if parts, err = s.uploadParts(ctx, key, uploadID, buf, r); err != nil {
+		// Abort with a background context so the upload is cleaned up even if ctx is done
+		s.abortMultipartUpload(context.Background(), key, uploadID)
+		return
+	}
+
+	if err = s.completeMultipartUpload(ctx, key, uploadID, parts); err != nil {
+		s.abortMultipartUpload(context.Background(), key, uploadID)
+		err = fmt.Errorf("error completing multipart upload: %v", err)
+		return
+	}
+
+	return
+}
+
+func (s *S3Source) uploadParts(ctx context.Context, key, uploadID string, buf []byte, r io.Reader) (parts []completedPart, err error) {
+	n := len(buf)
+	for partNumber := 1; n > 0; partNumber++ {
+		var etag string
+		if etag, err = s.uploadPart(ctx, key, uploadID, partNumber, buf[:n]); err != nil {
+			err = fmt.Errorf("error uploading part %d: %v", partNumber, err)
+			return
+		}
+
+		parts = append(parts, completedPart{PartNumber: partNumber, ETag: etag})
+
+		n, err = io.ReadFull(r, buf)
+		switch err {
+		case nil:
+		case io.EOF, io.ErrUnexpectedEOF:
+			// Reader has been exhausted, the next part (if any) is the last
+			err = nil
+		default:
+			return
+		}
+	}
+
+	return
+}
+
+func (s *S3Source) createMultipartUpload(ctx context.Context, key string) (uploadID string, err error) {
+	var req *http.Request
+	query := url.Values{"uploads": {""}}
+	if req, err = s.newRequest(ctx, http.MethodPost, key, query, nil); err != nil {
+		return
+	}
+
+	var result initiateMultipartUploadResult
+	if err = s.doAndClose(req, &result); err != nil {
+		return
+	}
+
+	uploadID = result.UploadID
+	return
+}
+
+func (s *S3Source) uploadPart(ctx context.Context, key, uploadID string, partNumber int, body []byte) (etag string, err error) {
+	var req *http.Request
+	query := url.Values{
+		"partNumber": {strconv.Itoa(partNumber)},
+		"uploadId":   {uploadID},
+	}
+
+	if req, err = s.newRequest(ctx, http.MethodPut, key, query, body); err != nil {
+		return
+	}
+
+	var resp *http.Response
+	if resp, err = s.do(req); err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	etag = resp.Header.Get("ETag")
+	return
+}
+
+func (s *S3Source) completeMultipartUpload(ctx context.Context, key, uploadID string, parts []completedPart) (err error) {
+	var body []byte
+	if body, err = xml.Marshal(completeMultipartUpload{Parts: parts}); err != nil {
+		return
+	}
+
+	var req *http.Request
+	query := url.Values{"uploadId": {uploadID}}
+	if req, err = s.newRequest(ctx, http.MethodPost, key, query, body); err != nil {
+		return
+	}
+
+	// Note: S3 can respond with a 200 status and an error body, decoding the result will surface it
+	var result completeMultipartUploadResult
+	return s.doAndClose(req, &result)
+}
+
+func (s *S3Source) abortMultipartUpload(ctx context.Context, key, uploadID string) {
+	req, err := s.newRequest(ctx, http.MethodDelete, key, url.Values{"uploadId": {uploadID}}, nil)
+	if err != nil {
+		return
+	}
+
+	_ = s.doAndClose(req, nil)
+}
+
+func (s *S3Source) getRange(ctx context.Context, key string, start, end int64, w io.Writer) (done bool, err error) {
+	var req *http.Request
+	if req, err = s.newRequest(ctx, http.MethodGet, key, nil, nil); err != nil {
+		return
+	}
+
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+
+	var resp *http.Response
+	if resp, err = s.do(req); err != nil {
+		err = handleNotFound(err)
+		return
+	}
+	defer resp.Body.Close()
+
+	// A status of 200 means the service ignored the range and returned the entire object
+	done = resp.StatusCode == http.StatusOK
+	if _, err = io.Copy(w, resp.Body); err != nil {
+		err = fmt.Errorf("error copying range %d-%d: %v", start, end, err)
+		return
+	}
+
+	return
+}
+
+func (s *S3Source) listObjects(ctx context.Context, prefix, startAfter, token string, maxKeys int64) (result listBucketResult, err error) {
+	if maxKeys > maxListKeys {
+		maxKeys = maxListKeys
+	}
+
+	query := url.Values{
+		"list-type": {"2"},
+		"prefix":    {prefix},
+		"max-keys":  {strconv.FormatInt(maxKeys, 10)},
+	}
+
+	if len(startAfter) > 0 {
+		query.Set("start-after", startAfter)
+	}
+
+	if len(token) > 0 {
+		query.Set("continuation-token", token)
+	}
+
+	var req *http.Request
+	if req, err = s.newRequest(ctx, http.MethodGet, "", query, nil); err != nil {
+		return
+	}
+
+	err = s.doAndClose(req, &result)
+	return
+}
+
+func (s *S3Source) newRequest(ctx context.Context, method, key string, query url.Values, body []byte) (req *http.Request, err error) {
+	u := *s.endpoint
+	objectPath := "/" + key
+	switch {
+	case !s.opts.PathStyle:
+		u.Host = s.opts.Bucket + "." + u.Host
+	case len(key) == 0:
+		objectPath = "/" + s.opts.Bucket
+	default:
+		objectPath = "/" + s.opts.Bucket + objectPath
+	}
+
+	u.Path = objectPath
+	u.RawPath = escapePath(objectPath)
+	u.RawQuery = getCanonicalQuery(query)
+
+	if req, err = http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body)); err != nil {
+		return
+	}
+
+	req.ContentLength = int64(len(body))
+	s.s.Sign(req, hashHex(body), s.now())
+	return
+}
+
+func (s *S3Source) do(req *http.Request) (resp *http.Response, err error) {
+	if resp, err = s.opts.HTTPClient.Do(req); err != nil {
+		return
+	}
+
+	if resp.StatusCode < 300 {
+		return
+	}
+
+	defer resp.Body.Close()
+	rerr := &ResponseError{StatusCode: resp.StatusCode}
+	// Note: HEAD requests will not have a body to decode
+	_ = xml.NewDecoder(resp.Body).Decode(rerr)
+	if len(rerr.Code) == 0 {
+		rerr.Code = http.StatusText(resp.StatusCode)
+	}
+
+	return nil, rerr
+}
+
+func (s *S3Source) doAndClose(req *http.Request, result interface{}) (err error) {
+	var resp *http.Response
+	if resp, err = s.do(req); err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if result == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return
+	}
+
+	var bs []byte
+	if bs, err = io.ReadAll(resp.Body); err != nil {
+		return
+	}
+
+	rerr := ResponseError{StatusCode: resp.StatusCode}
+	if xml.Unmarshal(bs, &rerr) == nil {
+		// Service responded with a successful status code and an error body
+		return &rerr
+	}
+
+	return xml.Unmarshal(bs, result)
+}
+
+func (s *S3Source) getKey(prefix, filename string) string {
+	return strings.TrimPrefix(path.Join(s.opts.KeyPrefix, prefix, filename), "/")
+}
+
+func handleNotFound(err error) error {
+	if rerr, ok := err.(*ResponseError); ok && rerr.StatusCode == http.StatusNotFound {
+		return os.ErrNotExist
+	}
+
+	return err
+}
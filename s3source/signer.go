@@ -0,0 +1,173 @@
+package s3source
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// signingAlgorithm is the AWS Signature Version 4 algorithm identifier
+	signingAlgorithm = "AWS4-HMAC-SHA256"
+	// amzDateLayout is the layout used for the X-Amz-Date header
+	amzDateLayout = "20060102T150405Z"
+)
+
+// emptyPayloadHash is the SHA-256 hash of an empty payload
+var emptyPayloadHash = hashHex(nil)
+
+func newSigner(o Options) *signer {
+	var s signer
+	s.accessKeyID = o.AccessKeyID
+	s.secretAccessKey = o.SecretAccessKey
+	s.sessionToken = o.SessionToken
+	s.region = o.Region
+	return &s
+}
+
+// signer signs requests using AWS Signature Version 4
+type signer struct {
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+	region          string
+}
+
+// Sign will sign the provided request with the provided payload hash
+func (s *signer) Sign(req *http.Request, payloadHash string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format(amzDateLayout)
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", now.Format("20060102"), s.region)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if len(s.sessionToken) > 0 {
+		req.Header.Set("X-Amz-Security-Token", s.sessionToken)
+	}
+
+	signedHeaders, canonicalHeaders := getCanonicalHeaders(req)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		getCanonicalURI(req.URL),
+		getCanonicalQuery(req.URL.Query()),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	stringToSign := strings.Join([]string{
+		signingAlgorithm,
+		amzDate,
+		scope,
+		hashHex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.secretAccessKey), now.Format("20060102"))
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	authorization := fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		signingAlgorithm, s.accessKeyID, scope, signedHeaders, signature)
+	req.Header.Set("Authorization", authorization)
+}
+
+func getCanonicalHeaders(req *http.Request) (signed, canonical string) {
+	headers := map[string]string{
+		"host": req.URL.Host,
+	}
+
+	for key, values := range req.Header {
+		key = strings.ToLower(key)
+		switch {
+		case key == "range":
+		case key == "content-type":
+		case key == "content-md5":
+		case strings.HasPrefix(key, "x-amz-"):
+		default:
+			// Header is not signed, continue
+			continue
+		}
+
+		headers[key] = strings.TrimSpace(strings.Join(values, ","))
+	}
+
+	keys := make([]string, 0, len(headers))
+	for key := range headers {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for _, key := range keys {
+		sb.WriteString(key)
+		sb.WriteByte(':')
+		sb.WriteString(headers[key])
+		sb.WriteByte('\n')
+	}
+
+	return strings.Join(keys, ";"), sb.String()
+}
+
+func getCanonicalURI(u *url.URL) string {
+	p := u.EscapedPath()
+	if len(p) == 0 {
+		return "/"
+	}
+
+	return p
+}
+
+func getCanonicalQuery(values url.Values) string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		vs := values[key]
+		sort.Strings(vs)
+		for _, v := range vs {
+			pairs = append(pairs, escape(key)+"="+escape(v))
+		}
+	}
+
+	return strings.Join(pairs, "&")
+}
+
+// escape will URI encode a value according to RFC 3986
+func escape(str string) string {
+	return strings.ReplaceAll(url.QueryEscape(str), "+", "%20")
+}
+
+// escapePath will URI encode a key while preserving path separators
+func escapePath(key string) string {
+	spl := strings.Split(key, "/")
+	for i, part := range spl {
+		spl[i] = escape(part)
+	}
+
+	return strings.Join(spl, "/")
+}
+
+func hashHex(bs []byte) string {
+	sum := sha256.Sum256(bs)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,65 @@
+package s3source
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+type listBucketResult struct {
+	XMLName               xml.Name        `xml:"ListBucketResult"`
+	Name                  string          `xml:"Name"`
+	Prefix                string          `xml:"Prefix"`
+	StartAfter            string          `xml:"StartAfter,omitempty"`
+	MaxKeys               int64           `xml:"MaxKeys"`
+	KeyCount              int64           `xml:"KeyCount"`
+	IsTruncated           bool            `xml:"IsTruncated"`
+	ContinuationToken     string          `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string          `xml:"NextContinuationToken,omitempty"`
+	Contents              []objectContent `xml:"Contents"`
+}
+
+type objectContent struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+}
+
+type initiateMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	UploadID string   `xml:"UploadId"`
+}
+
+type completeMultipartUpload struct {
+	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+	Parts   []completedPart `xml:"Part"`
+}
+
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+type completeMultipartUploadResult struct {
+	XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+	Bucket  string   `xml:"Bucket"`
+	Key     string   `xml:"Key"`
+	ETag    string   `xml:"ETag"`
+}
+
+// ResponseError is returned when the S3 service responds with an error
+type ResponseError struct {
+	XMLName    xml.Name `xml:"Error"`
+	StatusCode int      `xml:"-"`
+	Code       string   `xml:"Code"`
+	Message    string   `xml:"Message"`
+	Key        string   `xml:"Key,omitempty"`
+	RequestID  string   `xml:"RequestId,omitempty"`
+}
+
+// Error will return the error message
+func (r *ResponseError) Error() string {
+	return fmt.Sprintf("s3 error (%d) %s: %s", r.StatusCode, r.Code, r.Message)
+}
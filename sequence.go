@@ -0,0 +1,69 @@
+package kiroku
+
+import (
+	"fmt"
+	"sync"
+)
+
+// SequenceGap represents sequence numbers of a Producer which were not seen by a Consumer
+type SequenceGap struct {
+	// Filename is the file which was received after the missing files
+	Filename Filename
+	// ProducerID is the ID of the Producer which wrote the missing files
+	ProducerID ProducerID
+	// Expected is the sequence number which was expected
+	Expected uint64
+	// Received is the sequence number which was received
+	Received uint64
+}
+
+// Missing will return the number of missing files
+func (s SequenceGap) Missing() uint64 {
+	return s.Received - s.Expected
+}
+
+// String will return a description of the sequence gap
+func (s SequenceGap) String() string {
+	return fmt.Sprintf("producer <%s> skipped from sequence %d to %d (%d missing)", s.ProducerID, s.Expected-1, s.Received, s.Missing())
+}
+
+func newSequenceTracker() *sequenceTracker {
+	var s sequenceTracker
+	s.last = map[ProducerID]uint64{}
+	return &s
+}
+
+// sequenceTracker tracks the last seen sequence number of each Producer
+type sequenceTracker struct {
+	mux sync.Mutex
+
+	last map[ProducerID]uint64
+}
+
+// Observe will record the sequence number of the provided file, a gap is
+// returned when sequence numbers were skipped since the last file of the Producer
+func (s *sequenceTracker) Observe(f Filename) (gap SequenceGap, ok bool) {
+	if f.ProducerID == 0 {
+		// Legacy filenames do not have sequence numbers, return
+		return
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	last, seen := s.last[f.ProducerID]
+	if f.Sequence > last || !seen {
+		s.last[f.ProducerID] = f.Sequence
+	}
+
+	if !seen || f.Sequence <= last+1 {
+		// First file seen for the Producer, or no sequence numbers were skipped
+		return
+	}
+
+	gap.Filename = f
+	gap.ProducerID = f.ProducerID
+	gap.Expected = last + 1
+	gap.Received = f.Sequence
+	ok = true
+	return
+}
@@ -0,0 +1,98 @@
+package kiroku
+
+import "testing"
+
+func Test_sequenceTracker_Observe(t *testing.T) {
+	type testcase struct {
+		name      string
+		filenames []Filename
+		wantGaps  []SequenceGap
+	}
+
+	tests := []testcase{
+		{
+			name: "contiguous",
+			filenames: []Filename{
+				makeSequencedFilename("test", 1, 1, 1, TypeChunk),
+				makeSequencedFilename("test", 2, 1, 2, TypeChunk),
+				makeSequencedFilename("test", 3, 1, 3, TypeSnapshot),
+			},
+		},
+		{
+			name: "gap",
+			filenames: []Filename{
+				makeSequencedFilename("test", 1, 1, 1, TypeChunk),
+				makeSequencedFilename("test", 4, 1, 4, TypeChunk),
+			},
+			wantGaps: []SequenceGap{
+				{
+					Filename:   makeSequencedFilename("test", 4, 1, 4, TypeChunk),
+					ProducerID: 1,
+					Expected:   2,
+					Received:   4,
+				},
+			},
+		},
+		{
+			name: "multiple producers",
+			filenames: []Filename{
+				makeSequencedFilename("test", 1, 1, 1, TypeChunk),
+				makeSequencedFilename("test", 1, 2, 7, TypeChunk),
+				makeSequencedFilename("test", 2, 1, 2, TypeChunk),
+				makeSequencedFilename("test", 3, 2, 9, TypeChunk),
+			},
+			wantGaps: []SequenceGap{
+				{
+					Filename:   makeSequencedFilename("test", 3, 2, 9, TypeChunk),
+					ProducerID: 2,
+					Expected:   8,
+					Received:   9,
+				},
+			},
+		},
+		{
+			name: "legacy",
+			filenames: []Filename{
+				makeFilename("test", 1, TypeChunk),
+				makeFilename("test", 2, TypeChunk),
+			},
+		},
+		{
+			name: "sequence reset",
+			filenames: []Filename{
+				makeSequencedFilename("test", 1, 1, 5, TypeChunk),
+				makeSequencedFilename("test", 2, 1, 1, TypeChunk),
+				makeSequencedFilename("test", 3, 1, 6, TypeChunk),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSequenceTracker()
+			var gaps []SequenceGap
+			for _, f := range tt.filenames {
+				if gap, ok := s.Observe(f); ok {
+					gaps = append(gaps, gap)
+				}
+			}
+
+			if len(gaps) != len(tt.wantGaps) {
+				t.Fatalf("invalid number of gaps, expected %d and received %d (%v)", len(tt.wantGaps), len(gaps), gaps)
+			}
+
+			for i, gap := range gaps {
+				if gap != tt.wantGaps[i] {
+					t.Fatalf("invalid gap, expected %+v and received %+v", tt.wantGaps[i], gap)
+				}
+			}
+		})
+	}
+}
+
+func TestSequenceGap_Missing(t *testing.T) {
+	gap := SequenceGap{Expected: 2, Received: 5}
+	if got := gap.Missing(); got != 3 {
+		t.Fatalf("invalid missing count, expected %d and received %d", 3, got)
+	}
+}
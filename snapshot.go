@@ -15,3 +15,18 @@ type Snapshot struct {
 func (s *Snapshot) Write(value []byte) (err error) {
 	return s.w.Write(value)
 }
+
+// Put will add a BlockEnvelope which writes the value to the key
+func (s *Snapshot) Put(key, value []byte) (err error) {
+	return s.w.WriteEnvelope(BlockEnvelope{Op: OpPut, Key: key, Value: value})
+}
+
+// WriteEnvelope will add a BlockEnvelope, this can be used for custom opcodes
+func (s *Snapshot) WriteEnvelope(e BlockEnvelope) (err error) {
+	return s.w.WriteEnvelope(e)
+}
+
+// Codec will return the Codec used by WriteValue
+func (s *Snapshot) Codec() Codec {
+	return s.w.codec
+}
@@ -0,0 +1,138 @@
+package kiroku
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hatchify/errors"
+)
+
+const (
+	// ErrNilSnapshotFunc is returned when a SnapshotPolicy is set without a SnapshotFunc
+	ErrNilSnapshotFunc = errors.Error("invalid snapshot policy, SnapshotFunc cannot be nil")
+	// ErrInvalidSnapshotPolicy is returned when a SnapshotPolicy has negative values
+	ErrInvalidSnapshotPolicy = errors.Error("invalid snapshot policy, values cannot be negative")
+)
+
+// SnapshotFunc is called by a Producer to write an automatic snapshot
+type SnapshotFunc func(*Snapshot) error
+
+// SnapshotPolicy determines when a Producer will automatically write a snapshot
+// A snapshot is written when any of the set thresholds are reached, provided at
+// least one chunk has been written since the previous snapshot
+type SnapshotPolicy struct {
+	// Chunks is the number of chunks to write before a snapshot is taken
+	Chunks int64 `toml:"chunks" json:"chunks"`
+	// Bytes is the total size of chunks to write before a snapshot is taken
+	Bytes int64 `toml:"bytes" json:"bytes"`
+	// Interval is the amount of time to wait between snapshots
+	Interval time.Duration `toml:"interval" json:"interval"`
+}
+
+// IsEmpty will return whether or not the policy has any thresholds set
+func (s SnapshotPolicy) IsEmpty() bool {
+	return s == SnapshotPolicy{}
+}
+
+// Validate ensures that the SnapshotPolicy values are valid
+func (s SnapshotPolicy) Validate() (err error) {
+	if s.Chunks < 0 || s.Bytes < 0 || s.Interval < 0 {
+		return ErrInvalidSnapshotPolicy
+	}
+
+	return
+}
+
+func newSnapshotter(policy SnapshotPolicy, c clock) *snapshotter {
+	var s snapshotter
+	s.policy = policy
+	s.clock = c
+	s.last = c.Now()
+	s.dueCh = make(chan struct{}, 1)
+	return &s
+}
+
+// snapshotter tracks the chunks written since the last snapshot
+type snapshotter struct {
+	mux sync.Mutex
+
+	policy SnapshotPolicy
+	clock  clock
+
+	// Number of chunks written since the last snapshot
+	chunks int64
+	// Total size of the chunks written since the last snapshot
+	bytes int64
+	// Time of the last snapshot
+	last time.Time
+
+	// dueCh is signaled when the chunk or byte threshold has been reached
+	dueCh chan struct{}
+}
+
+// onChunk will record a written chunk and signal when the snapshot is due
+func (s *snapshotter) onChunk(size int64) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.chunks++
+	s.bytes += size
+	if !s.isDue() {
+		return
+	}
+
+	select {
+	case s.dueCh <- struct{}{}:
+	default:
+		// Signal is already pending
+	}
+}
+
+// onSnapshot will reset the counters after a snapshot has been written
+func (s *snapshotter) onSnapshot() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.chunks = 0
+	s.bytes = 0
+	s.last = s.clock.Now()
+}
+
+// IsDue will return whether or not a snapshot should be written
+func (s *snapshotter) IsDue() bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.isDue()
+}
+
+func (s *snapshotter) isDue() bool {
+	switch {
+	case s.chunks == 0:
+		// Nothing has been written since the last snapshot
+		return false
+	case s.policy.Chunks > 0 && s.chunks >= s.policy.Chunks:
+		return true
+	case s.policy.Bytes > 0 && s.bytes >= s.policy.Bytes:
+		return true
+	case s.policy.Interval > 0 && s.clock.Now().Sub(s.last) >= s.policy.Interval:
+		return true
+	default:
+		return false
+	}
+}
+
+// next will return a channel which receives once the interval has elapsed since the last snapshot
+// Note: A nil channel is returned when the policy has no interval
+func (s *snapshotter) next() <-chan time.Time {
+	if s.policy.Interval <= 0 {
+		return nil
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	remaining := s.policy.Interval - s.clock.Now().Sub(s.last)
+	if remaining <= 0 {
+		// Interval has elapsed without any chunks being written, onChunk will signal once a chunk is written
+		remaining = s.policy.Interval
+	}
+
+	return s.clock.After(remaining)
+}
@@ -0,0 +1,266 @@
+package kiroku
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSnapshotter_IsDue(t *testing.T) {
+	type testcase struct {
+		name    string
+		policy  SnapshotPolicy
+		chunks  []int64
+		advance time.Duration
+		want    bool
+	}
+
+	tests := []testcase{
+		{
+			name:   "no chunks",
+			policy: SnapshotPolicy{Chunks: 1, Interval: time.Minute},
+			want:   false,
+		},
+		{
+			name:    "no chunks after interval",
+			policy:  SnapshotPolicy{Interval: time.Minute},
+			advance: time.Hour,
+			want:    false,
+		},
+		{
+			name:   "chunks below threshold",
+			policy: SnapshotPolicy{Chunks: 3},
+			chunks: []int64{10, 10},
+			want:   false,
+		},
+		{
+			name:   "chunks threshold",
+			policy: SnapshotPolicy{Chunks: 3},
+			chunks: []int64{10, 10, 10},
+			want:   true,
+		},
+		{
+			name:   "bytes below threshold",
+			policy: SnapshotPolicy{Bytes: 1024},
+			chunks: []int64{512, 511},
+			want:   false,
+		},
+		{
+			name:   "bytes threshold",
+			policy: SnapshotPolicy{Bytes: 1024},
+			chunks: []int64{512, 512},
+			want:   true,
+		},
+		{
+			name:    "interval not elapsed",
+			policy:  SnapshotPolicy{Interval: time.Minute},
+			chunks:  []int64{10},
+			advance: time.Second * 59,
+			want:    false,
+		},
+		{
+			name:    "interval elapsed",
+			policy:  SnapshotPolicy{Interval: time.Minute},
+			chunks:  []int64{10},
+			advance: time.Minute,
+			want:    true,
+		},
+		{
+			name:    "any threshold",
+			policy:  SnapshotPolicy{Chunks: 100, Bytes: 1 << 20, Interval: time.Minute},
+			chunks:  []int64{10},
+			advance: time.Minute,
+			want:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newFakeClock(time.Unix(0, 0))
+			s := newSnapshotter(tt.policy, c)
+			for _, size := range tt.chunks {
+				s.onChunk(size)
+			}
+
+			c.Advance(tt.advance)
+			if got := s.IsDue(); got != tt.want {
+				t.Fatalf("snapshotter.IsDue() = %v, want %v", got, tt.want)
+			}
+
+			s.onSnapshot()
+			if s.IsDue() {
+				t.Fatal("snapshotter.IsDue() expected false after snapshot")
+			}
+		})
+	}
+}
+
+func TestSnapshotPolicy_Validate(t *testing.T) {
+	type testcase struct {
+		name    string
+		policy  SnapshotPolicy
+		wantErr error
+	}
+
+	tests := []testcase{
+		{name: "empty"},
+		{name: "basic", policy: SnapshotPolicy{Chunks: 10, Bytes: 1024, Interval: time.Minute}},
+		{name: "negative chunks", policy: SnapshotPolicy{Chunks: -1}, wantErr: ErrInvalidSnapshotPolicy},
+		{name: "negative bytes", policy: SnapshotPolicy{Bytes: -1}, wantErr: ErrInvalidSnapshotPolicy},
+		{name: "negative interval", policy: SnapshotPolicy{Interval: -1}, wantErr: ErrInvalidSnapshotPolicy},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.policy.Validate(); err != tt.wantErr {
+				t.Fatalf("SnapshotPolicy.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProducer_snapshotPolicy(t *testing.T) {
+	type testcase struct {
+		name   string
+		policy SnapshotPolicy
+		// chunks is the number of chunks to write
+		chunks  int
+		advance time.Duration
+		want    bool
+	}
+
+	tests := []testcase{
+		{
+			name:   "chunks",
+			policy: SnapshotPolicy{Chunks: 3},
+			chunks: 3,
+			want:   true,
+		},
+		{
+			name:   "chunks below threshold",
+			policy: SnapshotPolicy{Chunks: 3},
+			chunks: 2,
+			want:   false,
+		},
+		{
+			name:   "bytes",
+			policy: SnapshotPolicy{Bytes: 1},
+			chunks: 1,
+			want:   true,
+		},
+		{
+			name:    "interval",
+			policy:  SnapshotPolicy{Interval: time.Hour},
+			chunks:  1,
+			advance: time.Hour,
+			want:    true,
+		},
+		{
+			name:    "interval not elapsed",
+			policy:  SnapshotPolicy{Interval: time.Hour},
+			chunks:  1,
+			advance: time.Minute,
+			want:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			if err = os.MkdirAll("./testing_source", 0744); err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll("./testing_source")
+
+			opts := MakeOptions("./testing", "test")
+			if err = os.MkdirAll(opts.Dir, 0744); err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(opts.Dir)
+
+			var src Source
+			if src, err = NewIOSource("./testing_source"); err != nil {
+				t.Fatal(err)
+			}
+
+			snapshotCh := make(chan struct{}, 1)
+			opts.SnapshotPolicy = tt.policy
+			opts.SnapshotFunc = func(ss *Snapshot) (err error) {
+				defer func() { snapshotCh <- struct{}{} }()
+				return ss.Write([]byte("snapshot"))
+			}
+
+			c := newFakeClock(time.Now())
+			var p *Producer
+			if p, err = newProducer(context.Background(), opts, src, c); err != nil {
+				t.Fatal(err)
+			}
+			defer p.Close()
+
+			for i := 0; i < tt.chunks; i++ {
+				if err = p.Transaction(func(txn *Transaction) error {
+					return txn.Write([]byte("hello world"))
+				}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if tt.advance > 0 {
+				// Wait for the policy watcher to wait on the interval
+				waitFor(t, func() bool { return c.Waiters() > 0 })
+				c.Advance(tt.advance)
+			}
+
+			var got bool
+			select {
+			case <-snapshotCh:
+				got = true
+			case <-time.After(time.Millisecond * 100):
+			}
+
+			if got != tt.want {
+				t.Fatalf("invalid snapshot state, expected %v and received %v", tt.want, got)
+			}
+
+			if !got {
+				return
+			}
+
+			if err = p.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			// Ensure the latest snapshot pointer was updated
+			var latest bytes.Buffer
+			if err = src.Get(context.Background(), LatestSnapshotsPrefix, getSnapshotName(opts.FullName()), func(r io.Reader) (err error) {
+				_, err = io.Copy(&latest, r)
+				return
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			var filename Filename
+			if filename, err = ParseFilename(latest.String()); err != nil {
+				t.Fatal(err)
+			}
+
+			if filename.Filetype != TypeSnapshot {
+				t.Fatalf("invalid latest snapshot type, expected <%s> and received <%s>", TypeSnapshot, filename.Filetype)
+			}
+		})
+	}
+}
+
+func waitFor(t *testing.T, fn func() bool) {
+	deadline := time.Now().Add(time.Second * 5)
+	for !fn() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -5,6 +5,9 @@ import (
 	"io"
 )
 
+// LatestSnapshotsPrefix is the Source prefix containing the latest snapshot pointer of each stream
+const LatestSnapshotsPrefix = "_latestSnapshots"
+
 // Source is used for importing
 type Source interface {
 	Export(ctx context.Context, prefix, filename string, r io.Reader) (newFilename string, err error)
@@ -14,3 +17,30 @@ type Source interface {
 	GetNextList(ctx context.Context, prefix, lastFilename string, maxkeys int64) (nextKeys []string, err error)
 	GetInfo(ctx context.Context, prefix, filename string) (info Info, err error)
 }
+
+// Deleter is an optional Source extension which allows files to be deleted
+// Note: Deleting a file which does not exist should not return an error
+type Deleter interface {
+	Delete(ctx context.Context, prefix, filename string) error
+}
+
+// Lister is an optional Source extension which lists all the files for a prefix
+// Note: Filenames are provided in ascending order
+type Lister interface {
+	List(ctx context.Context, prefix string, fn func(filename string) error) error
+}
+
+// Sizer is an optional Source extension which returns the size of a file without
+// hashing it's contents. Consumers with byte limits use Sizer rather than GetInfo
+type Sizer interface {
+	GetSize(ctx context.Context, prefix, filename string) (size int64, err error)
+}
+
+// Watcher is an optional Source extension which notifies subscribers of new files.
+// Consumers wake immediately when notified rather than waiting for EndOfResultsDelay
+// Note: The channel receives the filenames of files added to the prefix, and is
+// closed once the context is done. Notifications are hints, consumers will still
+// list the Source to determine which files to download
+type Watcher interface {
+	Subscribe(ctx context.Context, prefix string) (filenames <-chan string, err error)
+}
@@ -51,9 +51,20 @@ func (m *mockSource) GetInfo(ctx context.Context, prefix, filename string) (Info
 	return m.getInfoFn(ctx, prefix, filename)
 }
 
+// mockSizer is a mockSource which implements Sizer
+type mockSizer struct {
+	*mockSource
+	getSizeFn getSizeFn
+}
+
+func (m *mockSizer) GetSize(ctx context.Context, prefix, filename string) (int64, error) {
+	return m.getSizeFn(ctx, prefix, filename)
+}
+
 type exportFn func(ctx context.Context, prefix, filename string, r io.Reader) (string, error)
 type importFn func(ctx context.Context, prefix, filename string, w io.Writer) error
 type getFn func(ctx context.Context, prefix, filename string, fn func(io.Reader) error) error
 type getNextFn func(ctx context.Context, prefix, lastFilename string) (filename string, err error)
 type getNextListFn func(ctx context.Context, prefix, lastFilename string, maxKeys int64) (filenames []string, err error)
 type getInfoFn func(ctx context.Context, prefix, filename string) (Info, error)
+type getSizeFn func(ctx context.Context, prefix, filename string) (int64, error)
@@ -0,0 +1,456 @@
+// Package sourcetest provides a conformance test harness for kiroku.Source implementations
+package sourcetest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/mojura/kiroku"
+)
+
+const (
+	// testPrefix is the stream prefix used by the conformance tests
+	testPrefix = "sourcetest"
+	// otherPrefix is a second stream prefix which shares a leading name with testPrefix
+	otherPrefix = "sourcetest_other"
+	// baseTimestamp is used so that all generated filenames share the same number of digits
+	baseTimestamp = 1700000000000000000
+)
+
+// NewSourceFunc returns a new, empty Source for a single test
+type NewSourceFunc func(t *testing.T) kiroku.Source
+
+// Run will run the Source returned by fn through the full Source contract. Each
+// sub-test is provided with a new Source from fn, which must not contain any keys.
+func Run(t *testing.T, fn NewSourceFunc) {
+	t.Run("Export_Import", func(t *testing.T) { testExportImport(t, fn(t)) })
+	t.Run("Export_overwrite", func(t *testing.T) { testExportOverwrite(t, fn(t)) })
+	t.Run("Import_missing", func(t *testing.T) { testImportMissing(t, fn(t)) })
+	t.Run("Get", func(t *testing.T) { testGet(t, fn(t)) })
+	t.Run("Get_missing", func(t *testing.T) { testGetMissing(t, fn(t)) })
+	t.Run("GetInfo", func(t *testing.T) { testGetInfo(t, fn(t)) })
+	t.Run("GetSize", func(t *testing.T) { testGetSize(t, fn(t)) })
+	t.Run("GetNextList", func(t *testing.T) { testGetNextList(t, fn(t)) })
+	t.Run("GetNextList_empty", func(t *testing.T) { testGetNextListEmpty(t, fn(t)) })
+	t.Run("GetNext", func(t *testing.T) { testGetNext(t, fn(t)) })
+	t.Run("LatestSnapshots", func(t *testing.T) { testLatestSnapshots(t, fn(t)) })
+	t.Run("List", func(t *testing.T) { testList(t, fn(t)) })
+	t.Run("Delete", func(t *testing.T) { testDelete(t, fn(t)) })
+}
+
+// testExportImport ensures exported values can be imported under the returned filename
+func testExportImport(t *testing.T, src kiroku.Source) {
+	ctx := context.Background()
+	filename := makeFilename(testPrefix, 1, kiroku.TypeChunk)
+	value := []byte("hello world")
+
+	newFilename := export(t, src, testPrefix, filename, value)
+	if len(newFilename) == 0 {
+		t.Fatalf("Export(%q) returned an empty filename", filename)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := src.Import(ctx, testPrefix, newFilename, buf); err != nil {
+		t.Fatalf("Import(%q) error = %v", newFilename, err)
+	}
+
+	if !bytes.Equal(buf.Bytes(), value) {
+		t.Fatalf("Import(%q) = %q, want %q", newFilename, buf.Bytes(), value)
+	}
+}
+
+// testExportOverwrite ensures exporting an existing key replaces the previous value
+func testExportOverwrite(t *testing.T, src kiroku.Source) {
+	filename := makeFilename(testPrefix, 1, kiroku.TypeChunk)
+	export(t, src, testPrefix, filename, []byte("hello world, this is a longer value"))
+	export(t, src, testPrefix, filename, []byte("hello world"))
+
+	if got := get(t, src, testPrefix, filename); !bytes.Equal(got, []byte("hello world")) {
+		t.Fatalf("Get(%q) = %q after overwrite, want %q", filename, got, "hello world")
+	}
+}
+
+// testImportMissing ensures importing a missing key returns an os.ErrNotExist error
+func testImportMissing(t *testing.T, src kiroku.Source) {
+	filename := makeFilename(testPrefix, 1, kiroku.TypeChunk)
+	err := src.Import(context.Background(), testPrefix, filename, io.Discard)
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Import(%q) error = %v, want %v", filename, err, os.ErrNotExist)
+	}
+}
+
+// testGet ensures Get provides the exported value
+func testGet(t *testing.T, src kiroku.Source) {
+	filename := makeFilename(testPrefix, 1, kiroku.TypeChunk)
+	export(t, src, testPrefix, filename, []byte("hello world"))
+
+	if got := get(t, src, testPrefix, filename); !bytes.Equal(got, []byte("hello world")) {
+		t.Fatalf("Get(%q) = %q, want %q", filename, got, "hello world")
+	}
+}
+
+// testGetMissing ensures Get returns os.ErrNotExist (unwrapped) for missing keys
+// Note: Consumers compare against os.ErrNotExist directly when retrieving latest snapshots
+func testGetMissing(t *testing.T, src kiroku.Source) {
+	filename := makeFilename(testPrefix, 1, kiroku.TypeChunk)
+	var called bool
+	err := src.Get(context.Background(), testPrefix, filename, func(io.Reader) error {
+		called = true
+		return nil
+	})
+
+	if err != os.ErrNotExist {
+		t.Fatalf("Get(%q) error = %v, want %v", filename, err, os.ErrNotExist)
+	}
+
+	if called {
+		t.Fatalf("Get(%q) called the provided func for a missing key", filename)
+	}
+}
+
+// testGetInfo ensures GetInfo returns the key and size of an exported value
+func testGetInfo(t *testing.T, src kiroku.Source) {
+	ctx := context.Background()
+	filename := makeFilename(testPrefix, 1, kiroku.TypeChunk)
+	export(t, src, testPrefix, filename, []byte("hello world"))
+
+	info, err := src.GetInfo(ctx, testPrefix, filename)
+	if err != nil {
+		t.Fatalf("GetInfo(%q) error = %v", filename, err)
+	}
+
+	if info.Key != filename {
+		t.Fatalf("GetInfo(%q).Key = %q, want %q", filename, info.Key, filename)
+	}
+
+	if info.Size != int64(len("hello world")) {
+		t.Fatalf("GetInfo(%q).Size = %d, want %d", filename, info.Size, len("hello world"))
+	}
+
+	missing := makeFilename(testPrefix, 2, kiroku.TypeChunk)
+	if _, err = src.GetInfo(ctx, testPrefix, missing); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("GetInfo(%q) error = %v, want %v", missing, err, os.ErrNotExist)
+	}
+}
+
+// testGetSize ensures a Sizer returns the size of an exported value
+// Note: This test is skipped for Sources which do not implement kiroku.Sizer
+func testGetSize(t *testing.T, src kiroku.Source) {
+	s, ok := src.(kiroku.Sizer)
+	if !ok {
+		t.Skip("Source does not implement kiroku.Sizer")
+	}
+
+	ctx := context.Background()
+	filename := makeFilename(testPrefix, 1, kiroku.TypeChunk)
+	export(t, src, testPrefix, filename, []byte("hello world"))
+
+	size, err := s.GetSize(ctx, testPrefix, filename)
+	if err != nil {
+		t.Fatalf("GetSize(%q) error = %v", filename, err)
+	}
+
+	if size != int64(len("hello world")) {
+		t.Fatalf("GetSize(%q) = %d, want %d", filename, size, len("hello world"))
+	}
+
+	missing := makeFilename(testPrefix, 2, kiroku.TypeChunk)
+	if _, err = s.GetSize(ctx, testPrefix, missing); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("GetSize(%q) error = %v, want %v", missing, err, os.ErrNotExist)
+	}
+}
+
+// testGetNextList ensures GetNextList returns keys strictly after the last filename,
+// in lexical order, limited to maxKeys and scoped to the provided prefix
+func testGetNextList(t *testing.T, src kiroku.Source) {
+	ctx := context.Background()
+	filenames := seed(t, src)
+
+	type testcase struct {
+		name         string
+		lastFilename string
+		maxKeys      int64
+		want         []string
+	}
+
+	tests := []testcase{
+		{
+			name:    "from start",
+			maxKeys: 100,
+			want:    filenames,
+		},
+		{
+			name:         "strictly after last filename",
+			lastFilename: filenames[1],
+			maxKeys:      100,
+			want:         filenames[2:],
+		},
+		{
+			name:         "after a filename which does not exist",
+			lastFilename: makeFilename(testPrefix, 2, kiroku.TypeTemporary),
+			maxKeys:      100,
+			want:         filenames[2:],
+		},
+		{
+			name:    "max keys",
+			maxKeys: 2,
+			want:    filenames[:2],
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := src.GetNextList(ctx, testPrefix, tt.lastFilename, tt.maxKeys)
+		if err != nil {
+			t.Fatalf("%s: GetNextList(%q, %d) error = %v", tt.name, tt.lastFilename, tt.maxKeys, err)
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("%s: GetNextList(%q, %d) = %v, want %v", tt.name, tt.lastFilename, tt.maxKeys, got, tt.want)
+		}
+	}
+
+	// Page through the entire prefix to ensure pagination ends with io.EOF
+	var (
+		paged []string
+		last  string
+	)
+
+	for {
+		got, err := src.GetNextList(ctx, testPrefix, last, 2)
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			t.Fatalf("GetNextList(%q, 2) error = %v", last, err)
+		}
+
+		if len(got) == 0 {
+			t.Fatalf("GetNextList(%q, 2) returned an empty list without io.EOF", last)
+		}
+
+		if len(paged) > len(filenames) {
+			t.Fatalf("GetNextList() paging did not end with io.EOF, received %v", paged)
+		}
+
+		paged = append(paged, got...)
+		last = got[len(got)-1]
+	}
+
+	if !reflect.DeepEqual(paged, filenames) {
+		t.Fatalf("GetNextList() paged = %v, want %v", paged, filenames)
+	}
+}
+
+// testGetNextListEmpty ensures GetNextList returns io.EOF for empty and unknown prefixes
+func testGetNextListEmpty(t *testing.T, src kiroku.Source) {
+	ctx := context.Background()
+	if got, err := src.GetNextList(ctx, testPrefix, "", 100); err != io.EOF || len(got) > 0 {
+		t.Fatalf("GetNextList() on an empty Source = %v, %v, want io.EOF", got, err)
+	}
+
+	filenames := seed(t, src)
+	last := filenames[len(filenames)-1]
+	if got, err := src.GetNextList(ctx, testPrefix, last, 100); err != io.EOF || len(got) > 0 {
+		t.Fatalf("GetNextList(%q) = %v, %v, want io.EOF", last, got, err)
+	}
+}
+
+// testGetNext ensures GetNext returns the next key strictly after the last filename
+func testGetNext(t *testing.T, src kiroku.Source) {
+	ctx := context.Background()
+	filenames := seed(t, src)
+
+	var last string
+	for _, want := range filenames {
+		got, err := src.GetNext(ctx, testPrefix, last)
+		if err != nil {
+			t.Fatalf("GetNext(%q) error = %v", last, err)
+		}
+
+		if got != want {
+			t.Fatalf("GetNext(%q) = %q, want %q", last, got, want)
+		}
+
+		last = got
+	}
+
+	if got, err := src.GetNext(ctx, testPrefix, last); err != io.EOF {
+		t.Fatalf("GetNext(%q) = %q, %v, want io.EOF", last, got, err)
+	}
+}
+
+// testLatestSnapshots ensures latest-snapshot pointers can be written, replaced and
+// read, and that they do not leak into the listings of a stream
+func testLatestSnapshots(t *testing.T, src kiroku.Source) {
+	ctx := context.Background()
+	pointer := testPrefix + ".txt"
+	first := makeFilename(testPrefix, 1, kiroku.TypeSnapshot)
+	second := makeFilename(testPrefix, 2, kiroku.TypeSnapshot)
+
+	if err := src.Get(ctx, kiroku.LatestSnapshotsPrefix, pointer, func(io.Reader) error { return nil }); err != os.ErrNotExist {
+		t.Fatalf("Get(%q, %q) error = %v, want %v", kiroku.LatestSnapshotsPrefix, pointer, err, os.ErrNotExist)
+	}
+
+	export(t, src, kiroku.LatestSnapshotsPrefix, pointer, []byte(first))
+	if got := get(t, src, kiroku.LatestSnapshotsPrefix, pointer); string(got) != first {
+		t.Fatalf("Get(%q, %q) = %q, want %q", kiroku.LatestSnapshotsPrefix, pointer, got, first)
+	}
+
+	export(t, src, kiroku.LatestSnapshotsPrefix, pointer, []byte(second))
+	if got := get(t, src, kiroku.LatestSnapshotsPrefix, pointer); string(got) != second {
+		t.Fatalf("Get(%q, %q) = %q after update, want %q", kiroku.LatestSnapshotsPrefix, pointer, got, second)
+	}
+
+	filenames := seed(t, src)
+	got, err := src.GetNextList(ctx, testPrefix, "", 100)
+	if err != nil {
+		t.Fatalf("GetNextList() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(got, filenames) {
+		t.Fatalf("GetNextList() = %v, want %v", got, filenames)
+	}
+
+	var next string
+	if next, err = src.GetNext(ctx, testPrefix, ""); err != nil {
+		t.Fatalf("GetNext() error = %v", err)
+	}
+
+	if next != filenames[0] {
+		t.Fatalf("GetNext() = %q, want %q", next, filenames[0])
+	}
+}
+
+// testList ensures a Lister lists every file for a prefix in ascending order
+// Note: This test is skipped for Sources which do not implement kiroku.Lister
+func testList(t *testing.T, src kiroku.Source) {
+	l, ok := src.(kiroku.Lister)
+	if !ok {
+		t.Skip("Source does not implement kiroku.Lister")
+	}
+
+	var got []string
+	collect := func(filename string) error {
+		got = append(got, filename)
+		return nil
+	}
+
+	if err := l.List(context.Background(), testPrefix, collect); err != nil {
+		t.Fatalf("List() error = %v on empty prefix", err)
+	}
+
+	if len(got) != 0 {
+		t.Fatalf("List() = %v on empty prefix, want none", got)
+	}
+
+	filenames := seed(t, src)
+	if err := l.List(context.Background(), testPrefix, collect); err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(got, filenames) {
+		t.Fatalf("List() = %v, want %v", got, filenames)
+	}
+
+	// Errors returned by the iterating func must be returned
+	errStop := errors.New("stop")
+	if err := l.List(context.Background(), testPrefix, func(string) error { return errStop }); err != errStop {
+		t.Fatalf("List() error = %v, want %v", err, errStop)
+	}
+}
+
+// testDelete ensures a Deleter removes files and ignores missing files
+// Note: This test is skipped for Sources which do not implement kiroku.Deleter
+func testDelete(t *testing.T, src kiroku.Source) {
+	d, ok := src.(kiroku.Deleter)
+	if !ok {
+		t.Skip("Source does not implement kiroku.Deleter")
+	}
+
+	ctx := context.Background()
+	filenames := seed(t, src)
+	if err := d.Delete(ctx, testPrefix, filenames[0]); err != nil {
+		t.Fatalf("Delete(%q) error = %v", filenames[0], err)
+	}
+
+	if err := src.Get(ctx, testPrefix, filenames[0], func(io.Reader) error { return nil }); err != os.ErrNotExist {
+		t.Fatalf("Get(%q) error = %v after delete, want %v", filenames[0], err, os.ErrNotExist)
+	}
+
+	if err := d.Delete(ctx, testPrefix, filenames[0]); err != nil {
+		t.Fatalf("Delete(%q) error = %v for missing file, want nil", filenames[0], err)
+	}
+
+	got, err := src.GetNextList(ctx, testPrefix, "", 100)
+	if err != nil {
+		t.Fatalf("GetNextList() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(got, filenames[1:]) {
+		t.Fatalf("GetNextList() = %v after delete, want %v", got, filenames[1:])
+	}
+}
+
+// seed will export a set of chunks and snapshots to the test prefix and a set of
+// chunks to a neighboring prefix. The test prefix filenames are returned in lexical order.
+func seed(t *testing.T, src kiroku.Source) (filenames []string) {
+	t.Helper()
+	// Exports are intentionally out of order to ensure the Source sorts them
+	for _, i := range []int64{3, 1, 4, 2, 5} {
+		filetype := kiroku.TypeChunk
+		if i == 3 {
+			filetype = kiroku.TypeSnapshot
+		}
+
+		filename := makeFilename(testPrefix, i, filetype)
+		export(t, src, testPrefix, filename, []byte(filename))
+		export(t, src, otherPrefix, makeFilename(otherPrefix, i, kiroku.TypeChunk), []byte(filename))
+	}
+
+	for i := int64(1); i <= 5; i++ {
+		filetype := kiroku.TypeChunk
+		if i == 3 {
+			filetype = kiroku.TypeSnapshot
+		}
+
+		filenames = append(filenames, makeFilename(testPrefix, i, filetype))
+	}
+
+	return
+}
+
+func export(t *testing.T, src kiroku.Source, prefix, filename string, value []byte) (newFilename string) {
+	t.Helper()
+	var err error
+	if newFilename, err = src.Export(context.Background(), prefix, filename, bytes.NewReader(value)); err != nil {
+		t.Fatalf("Export(%q, %q) error = %v", prefix, filename, err)
+	}
+
+	return
+}
+
+func get(t *testing.T, src kiroku.Source, prefix, filename string) (value []byte) {
+	t.Helper()
+	if err := src.Get(context.Background(), prefix, filename, func(r io.Reader) (err error) {
+		value, err = io.ReadAll(r)
+		return
+	}); err != nil {
+		t.Fatalf("Get(%q, %q) error = %v", prefix, filename, err)
+	}
+
+	return
+}
+
+func makeFilename(prefix string, i int64, filetype kiroku.Type) string {
+	var f kiroku.Filename
+	f.Name = prefix
+	f.CreatedAt = baseTimestamp + i
+	f.Filetype = filetype
+	return f.String()
+}
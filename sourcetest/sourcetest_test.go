@@ -0,0 +1,18 @@
+package sourcetest
+
+import (
+	"testing"
+
+	"github.com/mojura/kiroku"
+)
+
+func TestRun_IOSource(t *testing.T) {
+	Run(t, func(t *testing.T) kiroku.Source {
+		src, err := kiroku.NewIOSource(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return src
+	})
+}
@@ -0,0 +1,177 @@
+package kiroku
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// WatcherStateIdle denotes that the watcher is waiting for files
+	WatcherStateIdle WatcherState = iota
+	// WatcherStateProcessing denotes that the watcher is processing a file
+	WatcherStateProcessing
+	// WatcherStateBackoff denotes that the watcher is waiting to retry after an error
+	WatcherStateBackoff
+	// WatcherStateClosed denotes that the watcher has been closed
+	WatcherStateClosed
+	// WatcherStateHalted denotes that the watcher has stopped processing after an error was escalated
+	WatcherStateHalted
+)
+
+// WatcherState represents the current state of a local file watcher
+type WatcherState uint32
+
+// String will return the string representation of the watcher state
+func (w WatcherState) String() string {
+	switch w {
+	case WatcherStateIdle:
+		return "idle"
+	case WatcherStateProcessing:
+		return "processing"
+	case WatcherStateBackoff:
+		return "backoff"
+	case WatcherStateClosed:
+		return "closed"
+	case WatcherStateHalted:
+		return "halted"
+	default:
+		return "invalid"
+	}
+}
+
+// ConsumerStats represents the health of a Consumer
+type ConsumerStats struct {
+	// QueueDepth is the number of downloaded files waiting to be processed
+	QueueDepth int64 `json:"queueDepth"`
+	// QueueBytes is the size of the downloaded files waiting to be processed,
+	// including the files currently being downloaded
+	QueueBytes int64 `json:"queueBytes"`
+	// FreeBytes is the free space on the disk containing Dir
+	// Note: This is zero on platforms which cannot determine free disk space
+	FreeBytes int64 `json:"freeBytes"`
+
+	FilesDownloaded int64 `json:"filesDownloaded"`
+	BytesDownloaded int64 `json:"bytesDownloaded"`
+	FilesProcessed  int64 `json:"filesProcessed"`
+	BytesProcessed  int64 `json:"bytesProcessed"`
+
+	// LastProcessedTimestamp is the timestamp of the last file queued for processing
+	LastProcessedTimestamp int64 `json:"lastProcessedTimestamp"`
+	// NewestSourceTimestamp is the timestamp of the newest file seen within the Source
+	NewestSourceTimestamp int64 `json:"newestSourceTimestamp"`
+	// Lag is the difference between NewestSourceTimestamp and LastProcessedTimestamp
+	Lag time.Duration `json:"lag"`
+
+	ErrorCount int64 `json:"errorCount"`
+	// RetryCount is the number of times a previously failed file has been retried
+	RetryCount  int64     `json:"retryCount"`
+	LastError   string    `json:"lastError"`
+	LastErrorAt time.Time `json:"lastErrorAt"`
+
+	WatcherState WatcherState `json:"watcherState"`
+
+	// SequenceGaps is the number of times missing sequence numbers were detected
+	SequenceGaps int64 `json:"sequenceGaps"`
+	// MissingFiles is the number of files which were skipped within sequence gaps
+	MissingFiles int64 `json:"missingFiles"`
+	// Quarantined is the number of files which were quarantined after repeatedly failing to apply
+	Quarantined int64 `json:"quarantined"`
+}
+
+// ProducerStats represents the health of a Producer
+type ProducerStats struct {
+	// QueueDepth is the number of chunks and snapshots waiting to be exported
+	QueueDepth int64 `json:"queueDepth"`
+	// QueueBytes is the size of the chunks and snapshots waiting to be exported
+	QueueBytes int64 `json:"queueBytes"`
+
+	Transactions  int64 `json:"transactions"`
+	Snapshots     int64 `json:"snapshots"`
+	FilesExported int64 `json:"filesExported"`
+	BytesExported int64 `json:"bytesExported"`
+
+	// LastExportedTimestamp is the timestamp of the last exported file
+	LastExportedTimestamp int64 `json:"lastExportedTimestamp"`
+
+	ErrorCount int64 `json:"errorCount"`
+	// RetryCount is the number of times a previously failed export has been retried
+	RetryCount  int64     `json:"retryCount"`
+	LastError   string    `json:"lastError"`
+	LastErrorAt time.Time `json:"lastErrorAt"`
+
+	WatcherState WatcherState `json:"watcherState"`
+
+	// Backpressured is the number of transactions which reached the BackpressurePolicy limits
+	Backpressured int64 `json:"backpressured"`
+}
+
+// counter tracks the number of files and bytes handled
+type counter struct {
+	files int64
+	bytes int64
+}
+
+func (c *counter) add(size int64) {
+	atomic.AddInt64(&c.files, 1)
+	atomic.AddInt64(&c.bytes, size)
+}
+
+func (c *counter) get() (files, bytes int64) {
+	return atomic.LoadInt64(&c.files), atomic.LoadInt64(&c.bytes)
+}
+
+// errorStats tracks the errors encountered by Producers and Consumers
+type errorStats struct {
+	mux sync.RWMutex
+
+	count       int64
+	lastError   string
+	lastErrorAt time.Time
+}
+
+func (e *errorStats) add(msg string) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	e.count++
+	e.lastError = msg
+	e.lastErrorAt = time.Now()
+}
+
+func (e *errorStats) get() (count int64, lastError string, lastErrorAt time.Time) {
+	e.mux.RLock()
+	defer e.mux.RUnlock()
+	return e.count, e.lastError, e.lastErrorAt
+}
+
+// withErrorStats will wrap a Logger so Error entries are recorded
+func withErrorStats(e *errorStats, l Logger) Logger {
+	return &statsLogger{Logger: l, e: e}
+}
+
+// statsLogger records Error entries before passing them to the wrapped Logger
+type statsLogger struct {
+	Logger
+	e *errorStats
+}
+
+// Error will record the entry and pass it to the wrapped Logger
+func (s *statsLogger) Error(msg string, args ...any) {
+	if err, ok := getLogError(args); ok {
+		s.e.add(msg + ": " + err.Error())
+	} else {
+		s.e.add(msg)
+	}
+
+	s.Logger.Error(msg, args...)
+}
+
+func getFileSize(filepath string) (size int64) {
+	fi, err := os.Stat(filepath)
+	if err != nil {
+		return
+	}
+
+	return fi.Size()
+}
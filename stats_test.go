@@ -0,0 +1,216 @@
+package kiroku
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hatchify/errors"
+)
+
+func TestProducer_Stats(t *testing.T) {
+	var err error
+	if err = os.MkdirAll("./testing_source", 0744); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll("./testing_source")
+
+	opts := MakeOptions("./testing", "test")
+	if err = os.MkdirAll(opts.Dir, 0744); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(opts.Dir)
+
+	var src Source
+	if src, err = NewIOSource("./testing_source"); err != nil {
+		t.Fatal(err)
+	}
+
+	var p *Producer
+	if p, err = NewProducer(opts, src); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	for i := 0; i < 3; i++ {
+		if err = p.Transaction(func(txn *Transaction) error {
+			return txn.Write([]byte("hello world"))
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = p.Snapshot(func(ss *Snapshot) error {
+		return ss.Write([]byte("snapshot"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var stats ProducerStats
+	waitFor(t, func() bool {
+		if stats, err = p.Stats(); err != nil {
+			t.Fatal(err)
+		}
+
+		return stats.FilesExported == 4 && stats.QueueDepth == 0
+	})
+
+	if stats.Transactions != 3 {
+		t.Fatalf("invalid transactions, expected %d and received %d", 3, stats.Transactions)
+	}
+
+	if stats.Snapshots != 1 {
+		t.Fatalf("invalid snapshots, expected %d and received %d", 1, stats.Snapshots)
+	}
+
+	if stats.BytesExported == 0 {
+		t.Fatal("invalid bytes exported, expected a non-zero value")
+	}
+
+	if stats.LastExportedTimestamp == 0 {
+		t.Fatal("invalid last exported timestamp, expected a non-zero value")
+	}
+
+	if stats.ErrorCount != 0 {
+		t.Fatalf("invalid error count, expected %d and received %d", 0, stats.ErrorCount)
+	}
+
+	if err = p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = p.Stats(); err != errors.ErrIsClosed {
+		t.Fatalf("invalid error, expected %v and received %v", errors.ErrIsClosed, err)
+	}
+}
+
+func TestConsumer_Stats(t *testing.T) {
+	type testcase struct {
+		name string
+		// failures is the number of times UpdateFunc will fail
+		failures int
+	}
+
+	tests := []testcase{
+		{
+			name: "basic",
+		},
+		{
+			name:     "with retries",
+			failures: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			if err = os.MkdirAll("./testing_source", 0744); err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll("./testing_source")
+
+			var src *IOSource
+			if src, err = NewIOSource("./testing_source"); err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := context.Background()
+			var newest int64
+			for i := int64(0); i < 3; i++ {
+				filename := makeFilename("test", 1000+i, TypeChunk)
+				bs := newTestChunk(Block("hello world"))
+				if _, err = src.Export(ctx, "test", filename.String(), bytes.NewReader(bs)); err != nil {
+					t.Fatal(err)
+				}
+
+				newest = filename.CreatedAt
+			}
+
+			opts := MakeOptions("./testing", "test")
+			opts.ErrorDelay = time.Millisecond
+			opts.EndOfResultsDelay = time.Millisecond * 10
+			if err = os.MkdirAll(opts.Dir, 0744); err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(opts.Dir)
+
+			failures := tt.failures
+			var c *Consumer
+			if c, err = NewConsumer(opts, src, func(typ Type, r *Reader) error {
+				if failures > 0 {
+					failures--
+					return fmt.Errorf("failure")
+				}
+
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+
+			var stats ConsumerStats
+			waitFor(t, func() bool {
+				if stats, err = c.Stats(); err != nil {
+					t.Fatal(err)
+				}
+
+				return stats.FilesProcessed == 3
+			})
+
+			if stats.FilesDownloaded != 3 {
+				t.Fatalf("invalid files downloaded, expected %d and received %d", 3, stats.FilesDownloaded)
+			}
+
+			if stats.BytesProcessed != stats.BytesDownloaded || stats.BytesProcessed == 0 {
+				t.Fatalf("invalid bytes processed, expected %d and received %d", stats.BytesDownloaded, stats.BytesProcessed)
+			}
+
+			if stats.NewestSourceTimestamp != newest {
+				t.Fatalf("invalid newest source timestamp, expected %d and received %d", newest, stats.NewestSourceTimestamp)
+			}
+
+			if stats.Lag != 0 {
+				t.Fatalf("invalid lag, expected %v and received %v", time.Duration(0), stats.Lag)
+			}
+
+			if stats.ErrorCount != int64(tt.failures) {
+				t.Fatalf("invalid error count, expected %d and received %d", tt.failures, stats.ErrorCount)
+			}
+
+			if stats.RetryCount != int64(tt.failures) {
+				t.Fatalf("invalid retry count, expected %d and received %d", tt.failures, stats.RetryCount)
+			}
+
+			if tt.failures > 0 && len(stats.LastError) == 0 {
+				t.Fatal("invalid last error, expected a non-empty value")
+			}
+		})
+	}
+}
+
+func TestWatcherState_String(t *testing.T) {
+	type testcase struct {
+		state WatcherState
+		want  string
+	}
+
+	tests := []testcase{
+		{state: WatcherStateIdle, want: "idle"},
+		{state: WatcherStateProcessing, want: "processing"},
+		{state: WatcherStateBackoff, want: "backoff"},
+		{state: WatcherStateClosed, want: "closed"},
+		{state: WatcherStateHalted, want: "halted"},
+		{state: 99, want: "invalid"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := tt.state.String(); got != tt.want {
+				t.Fatalf("WatcherState.String() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
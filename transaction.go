@@ -16,4 +16,24 @@ func (t *Transaction) Write(value []byte) (err error) {
 	return t.w.Write(value)
 }
 
+// Put will add a BlockEnvelope which writes the value to the key
+func (t *Transaction) Put(key, value []byte) (err error) {
+	return t.w.WriteEnvelope(BlockEnvelope{Op: OpPut, Key: key, Value: value})
+}
+
+// Delete will add a BlockEnvelope which deletes the key
+func (t *Transaction) Delete(key []byte) (err error) {
+	return t.w.WriteEnvelope(BlockEnvelope{Op: OpDelete, Key: key})
+}
+
+// WriteEnvelope will add a BlockEnvelope, this can be used for custom opcodes
+func (t *Transaction) WriteEnvelope(e BlockEnvelope) (err error) {
+	return t.w.WriteEnvelope(e)
+}
+
+// Codec will return the Codec used by WriteValue
+func (t *Transaction) Codec() Codec {
+	return t.w.codec
+}
+
 type TransactionFn func(*Transaction) error
@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -14,6 +16,9 @@ func newWatcher(ctx context.Context, opts Options, onTrigger func(Filename) erro
 	w.ctx = ctx
 	w.opts = opts
 	w.onTrigger = onTrigger
+	w.log = newLogger(opts.Logger, opts.FullName())
+	w.idx = newFileIndex()
+	w.n = newNotifier()
 
 	// Initialize semaphores
 	w.s = make(semaphore, 1)
@@ -21,6 +26,12 @@ func newWatcher(ctx context.Context, opts Options, onTrigger func(Filename) erro
 	w.ts = ts
 	// Increment jobs waiter
 	w.jobs.Add(1)
+	if !opts.LocalNotify || !w.notify() {
+		// Note: Without inotify, files added by other processes are indexed by
+		// walking the directory every ErrorDelay
+		w.jobs.Add(1)
+		go w.rescan()
+	}
 
 	// Initialize watch job
 	go w.watch()
@@ -29,6 +40,8 @@ func newWatcher(ctx context.Context, opts Options, onTrigger func(Filename) erro
 }
 
 type watcher struct {
+	mux sync.Mutex
+
 	ctx context.Context
 
 	onTrigger func(Filename) error
@@ -38,7 +51,24 @@ type watcher struct {
 	// Types
 	ts []Type
 
+	// Index of the files pending processing
+	idx *fileIndex
+	// Whether or not the index has been seeded from the directory
+	seeded bool
+	// Notified each time a file is removed from the index
+	n *notifier
+
 	opts Options
+	log  logger
+
+	// Current WatcherState
+	state uint32
+	// Number of times a previously failed file has been retried
+	retries int64
+	// Last file which failed to process
+	lastFailed Filename
+	// Attempt count of the last failed file
+	attempt int64
 
 	// Goroutine job waiter
 	jobs sync.WaitGroup
@@ -48,19 +78,27 @@ func (w *watcher) watch() {
 	var (
 		ok  bool
 		err error
+		// Number of consecutive failed attempts
+		attempt int64
 	)
 
 	// Decrement jobs waitgroup when func is done
 	defer w.jobs.Done()
+	// Set state as closed when func is done
+	defer w.setState(WatcherStateClosed)
 	// Iterate until Producer is closed
 	for !isClosed(w.ctx) {
-		if ok, err = w.process(); err != nil {
-			err = fmt.Errorf("error processing: %v", err)
-			w.opts.OnError(err)
-			w.sleep(w.opts.ErrorDelay)
+		if ok, err = w.process(); err == nil {
+			attempt = 0
+		} else if attempt++; !w.onError(err, attempt) {
+			// Error has been escalated, halt until the watcher is closed
+			w.setState(WatcherStateHalted)
+			<-w.ctx.Done()
+			return
 		}
 
 		if !ok {
+			w.setState(WatcherStateIdle)
 			w.waitForNext()
 		}
 	}
@@ -83,7 +121,7 @@ func (w *watcher) process() (ok bool, err error) {
 	var filename Filename
 	// Get next file for the target prefix
 	if filename, ok, err = w.getNext(); err != nil {
-		err = fmt.Errorf("error getting next %+v filename: <%v>, sleeping for %v and trying again", w.ts, err, w.opts.EndOfResultsDelay)
+		err = fmt.Errorf("error getting next %+v filename: %v", w.ts, err)
 		return
 	}
 
@@ -91,16 +129,175 @@ func (w *watcher) process() (ok bool, err error) {
 		return
 	}
 
+	if _, err = os.Stat(path.Join(w.opts.Dir, filename.String())); os.IsNotExist(err) {
+		// File was removed outside of the watcher, remove from the index and continue
+		w.remove(filename)
+		return true, nil
+	} else if err != nil {
+		err = fmt.Errorf("error getting info for <%s>: %v", filename, err)
+		return
+	}
+
+	w.mux.Lock()
+	if filename == w.lastFailed {
+		// File previously failed, increment retries
+		w.retries++
+		w.attempt++
+	} else {
+		w.attempt = 1
+	}
+	attempt := w.attempt
+	w.mux.Unlock()
+
+	w.setState(WatcherStateProcessing)
 	// Call provided function
 	if err = w.onTrigger(filename); err != nil {
-		err = fmt.Errorf("error encountered during action for <%s>: <%v>, sleeping for %v and trying again", filename, err, w.opts.ErrorDelay)
+		w.mux.Lock()
+		w.lastFailed = filename
+		w.mux.Unlock()
+		err = &actionError{filename: filename, attempt: attempt, err: err}
 		return
 	}
 
+	w.remove(filename)
 	return
 }
 
+// remove will remove the file from the index and notify those waiting for files to be processed
+func (w *watcher) remove(filename Filename) {
+	w.idx.Remove(filename)
+	w.n.Notify()
+}
+
+// onError will wait before the error is retried, false is returned when the error
+// has been escalated and should no longer be retried
+func (w *watcher) onError(err error, attempt int64) (retry bool) {
+	var filename Filename
+	cause := err
+	if aerr, ok := err.(*actionError); ok {
+		// Use the attempts of the failed file
+		filename, attempt, cause = aerr.filename, aerr.attempt, aerr.err
+	}
+
+	if e, ok := w.opts.Retry.escalation(filename, attempt, cause); ok {
+		w.log.error("error escalated, halting", LogKeyFilename, filename.String(), LogKeyAttempt, attempt, "permanent", e.Permanent, LogKeyError, cause)
+		if w.opts.OnEscalate != nil {
+			w.opts.OnEscalate(e)
+		}
+
+		return false
+	}
+
+	delay := w.opts.Retry.delay(attempt, w.opts.ErrorDelay, randomJitter)
+	w.logError(err, delay)
+	w.setState(WatcherStateBackoff)
+	w.sleep(delay)
+	return true
+}
+
+func (w *watcher) logError(err error, delay time.Duration) {
+	aerr, ok := err.(*actionError)
+	if !ok {
+		// Error was not caused by a file action, log without file attributes
+		w.log.error("error processing", LogKeyError, err, "delay", delay)
+		return
+	}
+
+	w.log.error("error processing", LogKeyFilename, aerr.filename.String(), LogKeyType, aerr.filename.Filetype, LogKeyAttempt, aerr.attempt, LogKeyError, aerr.err, "delay", delay)
+}
+
+// State will return the current WatcherState
+func (w *watcher) State() WatcherState {
+	return WatcherState(atomic.LoadUint32(&w.state))
+}
+
+// Retries will return the number of times a previously failed file has been retried
+func (w *watcher) Retries() int64 {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	return w.retries
+}
+
+func (w *watcher) setState(state WatcherState) {
+	atomic.StoreUint32(&w.state, uint32(state))
+}
+
 func (w *watcher) getNext() (filename Filename, ok bool, err error) {
+	if err = w.seed(); err != nil {
+		return
+	}
+
+	filename, ok = w.idx.First()
+	return
+}
+
+// Len will return the number of files pending processing
+func (w *watcher) Len() (n int, err error) {
+	if err = w.seed(); err != nil {
+		return
+	}
+
+	n = w.idx.Len()
+	return
+}
+
+// Size will return the total size of the files pending processing
+func (w *watcher) Size() (n int64, err error) {
+	if err = w.seed(); err != nil {
+		return
+	}
+
+	n = w.idx.Size()
+	return
+}
+
+// seed will populate the index with the matching files within the directory
+// Note: The directory is only walked once, the index is then kept current by
+// triggers and inotify events when LocalNotify is set (or periodic rescans otherwise)
+func (w *watcher) seed() (err error) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	if w.seeded {
+		return
+	}
+
+	if _, err = w.index(); err != nil {
+		return
+	}
+
+	w.seeded = true
+	return
+}
+
+// rescan will index the files added to the directory by other processes every ErrorDelay
+// Note: This is used when the directory is not watched with inotify
+func (w *watcher) rescan() {
+	defer w.jobs.Done()
+	for {
+		if w.sleep(w.opts.ErrorDelay); isClosed(w.ctx) {
+			return
+		}
+
+		w.reindex()
+	}
+}
+
+// reindex will add the files within the directory which are missing from the index
+func (w *watcher) reindex() {
+	added, err := w.index()
+	if err != nil {
+		w.log.warn("error rescanning directory", LogKeyError, err)
+		return
+	}
+
+	if added {
+		w.s.send()
+	}
+}
+
+// index will add the matching files within the directory to the index
+// Note: Files which were removed after being listed are removed from the index when processed
+func (w *watcher) index() (added bool, err error) {
 	cleanDir := filepath.Clean(w.opts.Dir)
 	fn := func(iteratingName string, info os.FileInfo) (err error) {
 		if info.IsDir() {
@@ -113,35 +310,66 @@ func (w *watcher) getNext() (filename Filename, ok bool, err error) {
 			return
 		}
 
-		truncated := filepath.Base(iteratingName)
-		// Check to see if current file is a match for the current name and prefix
-		if filename, err = ParseFilename(truncated); err != nil {
-			err = nil
-			return
+		if w.add(filepath.Base(iteratingName), info.Size()) {
+			added = true
 		}
 
-		if filename.Name != w.opts.FullName() {
-			return
-		}
+		return
+	}
 
-		for _, t := range w.ts {
-			if filename.Filetype == t {
-				ok = true
-				return errBreak
-			}
-		}
+	// Iterate through files within directory
+	err = walk(w.opts.Dir, fn)
+	return
+}
 
+// add will add the file to the index if it matches the name and types of the watcher
+func (w *watcher) add(name string, size int64) (ok bool) {
+	filename, err := ParseFilename(name)
+	if err != nil {
 		return
 	}
 
-	// Iterate through files within directory
-	if err = walk(w.opts.Dir, fn); err == errBreak {
-		err = nil
+	if filename.Name != w.opts.FullName() {
+		return
+	}
+
+	for _, t := range w.ts {
+		if filename.Filetype == t {
+			return w.idx.Add(filename, size)
+		}
 	}
 
 	return
 }
 
+// notify will keep the index current with the files added to the directory by other processes
+// Note: False is returned when the directory cannot be watched
+func (w *watcher) notify() (ok bool) {
+	filenames, err := watchDir(w.ctx, w.opts.Dir)
+	if err != nil {
+		w.log.warn("error watching directory, files added by other processes will be indexed every ErrorDelay", LogKeyError, err)
+		return
+	}
+
+	go w.onNotify(filenames)
+	return true
+}
+
+// onNotify will add the files written to the directory to the index
+func (w *watcher) onNotify(filenames <-chan string) {
+	for filename := range filenames {
+		if filename == watchOverflow {
+			// Notifications were dropped, list the directory to find the missing files
+			w.reindex()
+			continue
+		}
+
+		if w.add(filename, getFileSize(path.Join(w.opts.Dir, filename))) {
+			w.s.send()
+		}
+	}
+}
+
 func (w *watcher) waitForNext() {
 	select {
 	// Wait for semaphore signal
@@ -164,6 +392,24 @@ func (w *watcher) waitToComplete() {
 	w.jobs.Wait()
 }
 
-func (w *watcher) trigger() {
+func (w *watcher) trigger(filename Filename) {
+	name := filename.String()
+	w.add(name, getFileSize(path.Join(w.opts.Dir, name)))
 	w.s.send()
 }
+
+// actionError is returned when the watcher action fails for a file
+type actionError struct {
+	filename Filename
+	attempt  int64
+	err      error
+}
+
+func (a *actionError) Error() string {
+	return fmt.Sprintf("error encountered during action for <%s>: %v", a.filename, a.err)
+}
+
+// Unwrap will return the underlying error
+func (a *actionError) Unwrap() error {
+	return a.err
+}
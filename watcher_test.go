@@ -2,8 +2,12 @@ package kiroku
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"path"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 func Test_watcher_getNext(t *testing.T) {
@@ -218,3 +222,274 @@ func Test_watcher_process(t *testing.T) {
 		})
 	}
 }
+
+func Test_watcher_trigger(t *testing.T) {
+	opts := MakeOptions("./testing", "testing")
+	opts.fill()
+	if err := os.Mkdir(opts.Dir, 0744); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(opts.Dir)
+
+	var processed []Filename
+	w := &watcher{
+		opts:      opts,
+		ts:        []Type{TypeChunk},
+		idx:       newFileIndex(),
+		s:         make(semaphore, 1),
+		onTrigger: func(f Filename) error { processed = append(processed, f); return nil },
+	}
+
+	// Seed the index with the empty directory
+	if n, err := w.Len(); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatalf("invalid length, expected %d and received %d", 0, n)
+	}
+
+	filenames := []Filename{
+		makeFilename("testing", 2, TypeChunk),
+		makeFilename("testing", 1, TypeChunk),
+		// Unrelated types are not indexed
+		makeFilename("testing", 3, TypeSnapshot),
+	}
+
+	for _, filename := range filenames {
+		f, err := os.Create(path.Join(opts.Dir, filename.String()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = f.Close()
+		w.trigger(filename)
+	}
+
+	if n, err := w.Len(); err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Fatalf("invalid length, expected %d and received %d", 2, n)
+	}
+
+	// Remove the first file outside of the watcher
+	if err := os.Remove(path.Join(opts.Dir, filenames[1].String())); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.processAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(processed) != 1 || processed[0] != filenames[0] {
+		t.Fatalf("invalid processed files, expected %v and received %v", filenames[:1], processed)
+	}
+
+	if n, err := w.Len(); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatalf("invalid length, expected %d and received %d", 0, n)
+	}
+}
+
+func Test_watcher_rescan(t *testing.T) {
+	opts := MakeOptions(t.TempDir(), "testing")
+	opts.ErrorDelay = time.Millisecond * 10
+	opts.fill()
+
+	processed := make(chan Filename, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	w := newWatcher(ctx, opts, func(f Filename) error {
+		processed <- f
+		return os.Remove(path.Join(opts.Dir, f.String()))
+	}, TypeChunk)
+	defer w.waitToComplete()
+	defer cancel()
+
+	waitFor(t, func() bool { return w.State() == WatcherStateIdle })
+
+	// Write a file without triggering the watcher, as another process would
+	filename := makeFilename("testing", 1, TypeChunk)
+	f, err := os.Create(path.Join(opts.Dir, filename.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	select {
+	case got := <-processed:
+		if got != filename {
+			t.Fatalf("invalid processed file, expected <%v> and received <%v>", filename, got)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("expected file added by another process to be processed")
+	}
+}
+
+func Test_watcher_onNotify_overflow(t *testing.T) {
+	opts := MakeOptions(t.TempDir(), "testing")
+	opts.fill()
+	w := &watcher{
+		opts: opts,
+		ts:   []Type{TypeChunk},
+		idx:  newFileIndex(),
+		s:    make(semaphore, 1),
+		log:  newLogger(opts.Logger, opts.FullName()),
+	}
+
+	// Seed the index with the empty directory
+	if err := w.seed(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Write a file whose notification was dropped
+	filename := makeFilename("testing", 1, TypeChunk)
+	f, err := os.Create(path.Join(opts.Dir, filename.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	filenames := make(chan string, 1)
+	filenames <- watchOverflow
+	close(filenames)
+	w.onNotify(filenames)
+
+	if got, ok := w.idx.First(); !ok || got != filename {
+		t.Fatalf("invalid indexed file, expected <%v> and received <%v>", filename, got)
+	}
+}
+
+func BenchmarkWatcher_getNext(b *testing.B) {
+	for _, n := range []int{100, 1000, 10000} {
+		opts := prepareWatcherBenchmark(b, n)
+		b.Run(fmt.Sprintf("index_%d", n), func(b *testing.B) {
+			w := &watcher{opts: opts, ts: []Type{TypeChunk}, idx: newFileIndex()}
+			// The directory is only walked once when seeding the index
+			if err := w.seed(); err != nil {
+				b.Fatal(err)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, ok, err := w.getNext(); err != nil || !ok {
+					b.Fatalf("invalid result, ok <%v> err <%v>", ok, err)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("walk_%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, ok, err := walkNext(opts.Dir, opts.FullName(), TypeChunk); err != nil || !ok {
+					b.Fatalf("invalid result, ok <%v> err <%v>", ok, err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkWatcher_Len(b *testing.B) {
+	for _, n := range []int{100, 1000, 10000} {
+		opts := prepareWatcherBenchmark(b, n)
+		b.Run(fmt.Sprintf("index_%d", n), func(b *testing.B) {
+			w := &watcher{opts: opts, ts: []Type{TypeChunk}, idx: newFileIndex()}
+			// The directory is only walked once when seeding the index
+			if err := w.seed(); err != nil {
+				b.Fatal(err)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := w.Len(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("walk_%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := walkCount(opts.Dir, opts.FullName(), TypeChunk); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func prepareWatcherBenchmark(b *testing.B, n int) (opts Options) {
+	opts = MakeOptions(fmt.Sprintf("./testing_bench_%d", n), "testing")
+	opts.fill()
+	if err := os.MkdirAll(opts.Dir, 0744); err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { os.RemoveAll(opts.Dir) })
+
+	for i := 0; i < n; i++ {
+		f, err := os.Create(path.Join(opts.Dir, makeFilename("testing", int64(i), TypeChunk).String()))
+		if err != nil {
+			b.Fatal(err)
+		}
+		_ = f.Close()
+	}
+
+	return
+}
+
+// walkNext is the directory walk previously used by watcher.getNext, it is
+// kept as a baseline for benchmarks
+func walkNext(dir, name string, ts ...Type) (filename Filename, ok bool, err error) {
+	cleanDir := filepath.Clean(dir)
+	fn := func(iteratingName string, info os.FileInfo) (err error) {
+		if info.IsDir() || filepath.Dir(iteratingName) != cleanDir {
+			return
+		}
+
+		if filename, err = ParseFilename(filepath.Base(iteratingName)); err != nil {
+			return nil
+		}
+
+		if filename.Name != name {
+			return
+		}
+
+		for _, t := range ts {
+			if filename.Filetype == t {
+				ok = true
+				return errBreak
+			}
+		}
+
+		return
+	}
+
+	err = walk(dir, fn)
+	return
+}
+
+// walkCount is the directory walk previously used to determine queue lengths,
+// it is kept as a baseline for benchmarks
+func walkCount(dir, name string, ts ...Type) (n int64, err error) {
+	cleanDir := filepath.Clean(dir)
+	err = walk(dir, func(filename string, info os.FileInfo) (err error) {
+		if info.IsDir() || filepath.Dir(filename) != cleanDir {
+			return
+		}
+
+		var parsed Filename
+		if parsed, err = ParseFilename(filepath.Base(filename)); err != nil {
+			return nil
+		}
+
+		if parsed.Name != name {
+			return
+		}
+
+		for _, t := range ts {
+			if parsed.Filetype == t {
+				n++
+				return
+			}
+		}
+
+		return
+	})
+
+	return
+}
@@ -1,29 +1,65 @@
 package kiroku
 
 import (
+	"encoding/binary"
+	"io"
 	"os"
 	"path"
 	"sync"
 
 	"github.com/hatchify/errors"
-	"github.com/mojura/enkodo"
 )
 
 var ErrEmptyBlock = errors.New("invalid block, cannot be empty")
 
-func newWriter(dir string, filename Filename) (wp *Writer, err error) {
-	var w Writer
-	w.filename = filename
+// ErrNonEmptyTarget is returned when a Writer is initialized for a target which has already been written to
+const ErrNonEmptyTarget = errors.Error("invalid writer target, chunks cannot be appended to once written")
+
+func newWriter(dir string, filename Filename, c Compression, codec Codec, token uint64) (wp *Writer, err error) {
+	var f *os.File
 	// Set filename as a combination of the provided directory, name, and a .kir extension
-	w.filepath = path.Join(dir, w.filename.String())
+	filepath := path.Join(dir, filename.String())
 	// Open target file
 	// Note: This will create the file if it does not exist
-	if w.f, err = createAppendFile(w.filepath); err != nil {
+	if f, err = createAppendFile(filepath); err != nil {
+		return
+	}
+
+	var fi os.FileInfo
+	if fi, err = f.Stat(); err != nil {
+		f.Close()
+		return
+	}
+
+	if wp, err = newWriterWithTarget(f, fi.Size(), filename, c, codec, token); err != nil {
+		f.Close()
+		return
+	}
+
+	wp.filepath = filepath
+	return
+}
+
+// newWriterWithTarget will initialize a Writer which writes to the provided target
+// Note: Size is the current size of the target, ErrNonEmptyTarget is returned when the target is not empty
+// Note: The fencing token is only written when it's set (E.g. the Producer holds a lease)
+func newWriterWithTarget(f io.WriteCloser, size int64, filename Filename, c Compression, codec Codec, token uint64) (wp *Writer, err error) {
+	var w Writer
+	w.f = f
+	w.filename = filename
+	w.h = newHeader(FlagBlockChecksums|FlagBlockIndex|FlagBlockKinds, c)
+	if token > 0 {
+		w.h.Flags |= FlagFencingToken
+		w.h.FencingToken = token
+	}
+	if w.codec = codec; codec != nil {
+		w.h.Codec = codec.ID()
+	}
+
+	if err = w.writeHeader(size); err != nil {
 		return
 	}
 
-	// Initialize enkodo writer
-	w.w = enkodo.NewWriter(w.f)
 	wp = &w
 	return
 }
@@ -33,9 +69,22 @@ type Writer struct {
 	mux sync.RWMutex
 
 	// Target file
-	f *os.File
-	// Enkodo writer
-	w *enkodo.Writer
+	f io.WriteCloser
+	// Chunk header
+	h Header
+	// Codec used by WriteValue, nil for raw blocks
+	codec Codec
+	// Frame buffer
+	buf []byte
+	// Block kind buffer
+	kbuf []byte
+	// Compression buffer
+	cbuf []byte
+	// Block index, containing the offset of each block
+	index []byte
+
+	// Offset of the next block
+	offset int64
 
 	// Location of file
 	filename Filename
@@ -52,6 +101,19 @@ func (w *Writer) Write(value Block) (err error) {
 		return ErrEmptyBlock
 	}
 
+	return w.write(blockKindRaw, value)
+}
+
+// WriteEnvelope will add a BlockEnvelope
+func (w *Writer) WriteEnvelope(e BlockEnvelope) (err error) {
+	if err = e.Validate(); err != nil {
+		return
+	}
+
+	return w.write(blockKindEnvelope, e.appendBytes(nil))
+}
+
+func (w *Writer) write(kind blockKind, value []byte) (err error) {
 	w.mux.Lock()
 	defer w.mux.Unlock()
 
@@ -59,11 +121,27 @@ func (w *Writer) Write(value Block) (err error) {
 		return errors.ErrIsClosed
 	}
 
-	// Encode block to writer
-	if err = w.w.Encode(value); err != nil {
+	// Prefix block with it's kind
+	w.kbuf = append(append(w.kbuf[:0], uint8(kind)), value...)
+	if w.h.Compression != CompressionNone && len(w.kbuf) > maxBlockSize {
+		// Compressed blocks are bounded when decompressed, reject blocks which could not be read
+		return ErrBlockTooLarge
+	}
+
+	// Compress block using the header compression
+	// Note: CompressionNone will return the value as-is
+	if w.cbuf, err = w.h.Compression.compress(w.cbuf, w.kbuf); err != nil {
 		return
 	}
 
+	// Write block frame to file
+	w.buf = appendFrame(w.buf[:0], w.cbuf, w.h.Flags)
+	if _, err = w.f.Write(w.buf); err != nil {
+		return
+	}
+
+	w.index = binary.BigEndian.AppendUint64(w.index, uint64(w.offset))
+	w.offset += int64(len(w.buf))
 	w.blockCount++
 	return
 }
@@ -80,13 +158,23 @@ func (w *Writer) Close() (err error) {
 	w.closed = true
 
 	var errs errors.ErrorList
-	if w.w != nil {
-		errs.Push(w.w.Close())
-	}
-
 	if w.f != nil {
+		// Write trailer so readers can differentiate a complete chunk from a truncated one
+		_, err = w.f.Write(appendTrailer(w.buf[:0], w.index, int64(w.blockCount), w.h.Flags))
+		errs.Push(err)
 		errs.Push(w.f.Close())
 	}
 
 	return errs.Err()
 }
+
+func (w *Writer) writeHeader(size int64) (err error) {
+	if size > 0 {
+		// Target has been written to, appending would write blocks after it's trailer
+		return ErrNonEmptyTarget
+	}
+
+	_, err = w.f.Write(w.h.Bytes())
+	w.offset = w.h.size()
+	return
+}
@@ -2,6 +2,7 @@ package kiroku
 
 import (
 	"os"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -39,7 +40,7 @@ func Test_newWriter(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			w, err := newWriter(tt.args.dir, tt.args.filename)
+			w, err := newWriter(tt.args.dir, tt.args.filename, CompressionNone, nil, 0)
 			if err == nil {
 				defer os.Remove(w.filepath)
 			}
@@ -52,6 +53,28 @@ func Test_newWriter(t *testing.T) {
 	}
 }
 
+func Test_newWriter_existing(t *testing.T) {
+	dir := t.TempDir()
+	filename := makeFilename("foo", time.Now().UnixNano(), TypeChunk)
+	w, err := newWriter(dir, filename, CompressionNone, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = w.Write(Block("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Blocks cannot be appended after the trailer of a written chunk
+	if _, err = newWriter(dir, filename, CompressionNone, nil, 0); err != ErrNonEmptyTarget {
+		t.Fatalf("invalid error, expected <%v> and received <%v>", ErrNonEmptyTarget, err)
+	}
+}
+
 func TestWriter_Write(t *testing.T) {
 	type fields struct {
 		filename  Filename
@@ -117,7 +140,7 @@ func TestWriter_Write(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			w, err := newWriter("./", tt.fields.filename)
+			w, err := newWriter("./", tt.fields.filename, CompressionNone, nil, 0)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -168,7 +191,7 @@ func TestWriter_Close(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			w, err := newWriter("./", tt.fields.filename)
+			w, err := newWriter("./", tt.fields.filename, CompressionNone, nil, 0)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -181,3 +204,62 @@ func TestWriter_Close(t *testing.T) {
 		})
 	}
 }
+
+func TestWriter_Read(t *testing.T) {
+	type testcase struct {
+		name        string
+		compression Compression
+	}
+
+	tests := []testcase{
+		{name: "uncompressed", compression: CompressionNone},
+		{name: "gzip", compression: CompressionGzip},
+		{name: "snappy", compression: CompressionSnappy},
+		{name: "zstd", compression: CompressionZstd},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := newWriter("./", makeFilename("temp", time.Now().UnixNano(), TypeTemporary), tt.compression, nil, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.Remove(w.filepath)
+
+			want := []Block{Block("hello"), Block("world")}
+			for _, b := range want {
+				if err = w.Write(b); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err = w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			wantHeader := newHeader(FlagBlockChecksums|FlagBlockIndex|FlagBlockKinds, tt.compression)
+			var got []Block
+			if err = Read(w.filepath, func(r *Reader) (err error) {
+				var h Header
+				if h, err = r.Header(); err != nil {
+					return
+				}
+
+				if h != wantHeader {
+					t.Fatalf("invalid header, expected %+v and received %+v", wantHeader, h)
+				}
+
+				return r.ForEach(0, func(b Block) (err error) {
+					got = append(got, b)
+					return
+				})
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("invalid blocks, expected %v and received %v", want, got)
+			}
+		})
+	}
+}